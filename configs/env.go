@@ -11,9 +11,20 @@ import (
 var configuration *Config
 
 type Config struct {
-	Database database
-	JWT      jwt
-	AWS      aws
+	Database     database
+	JWT          jwt
+	AWS          aws
+	App          app
+	Report       report
+	Storage      storage
+	Photos       photos
+	Payment      payment
+	Payout       payout
+	Idempotency  idempotency
+	Receipt      receipt
+	Invoice      invoice
+	Notification notification
+	Push         push
 }
 
 type database struct {
@@ -31,6 +42,130 @@ type jwt struct {
 type aws struct {
 	Region   string `mapstructure:"AWS_REGION" default:"us-east-1"`
 	S3Bucket string `mapstructure:"AWS_S3_BUCKET" default:"indicar-bk"`
+	// Custom endpoint for S3-compatible providers such as MinIO
+	S3Endpoint     string `mapstructure:"AWS_S3_ENDPOINT"`
+	S3UsePathStyle bool   `mapstructure:"AWS_S3_USE_PATH_STYLE" default:"false"`
+	// Endpoint written into pre-signed URLs, when clients reach the storage at
+	// another address than the API; defaults to AWS_S3_ENDPOINT
+	S3PublicEndpoint string `mapstructure:"AWS_S3_PUBLIC_ENDPOINT"`
+	// Static credentials; when empty the default AWS credential chain is used
+	AccessKeyID     string `mapstructure:"AWS_ACCESS_KEY_ID"`
+	SecretAccessKey string `mapstructure:"AWS_SECRET_ACCESS_KEY"`
+}
+
+type app struct {
+	BaseURL string `mapstructure:"APP_BASE_URL" default:"http://localhost:8080"`
+}
+
+type report struct {
+	// Base64-encoded 32-byte Ed25519 seed; report files are not signed when empty
+	SigningKey string `mapstructure:"REPORT_SIGNING_KEY"`
+}
+
+type storage struct {
+	// One of "s3", "local" or "memory"
+	Driver   string `mapstructure:"STORAGE_DRIVER" default:"s3"`
+	LocalDir string `mapstructure:"STORAGE_LOCAL_DIR" default:"./data/storage"`
+	// Signs the URLs of the local driver; required when it is used
+	SigningSecret string `mapstructure:"STORAGE_SIGNING_SECRET"`
+}
+
+type photos struct {
+	// Comma-separated photo categories required for each inspection type
+	RequiredStandard      string `mapstructure:"PHOTOS_REQUIRED_STANDARD" default:"front,rear,left,right,odometer"`
+	RequiredComplete      string `mapstructure:"PHOTOS_REQUIRED_COMPLETE" default:"front,rear,left,right,engine,odometer,chassis_number,interior"`
+	RequiredPrecautionary string `mapstructure:"PHOTOS_REQUIRED_PRECAUTIONARY" default:"front,rear,left,right,engine,odometer,chassis_number"`
+}
+
+type payment struct {
+	// One of "fake"
+	Provider             string `mapstructure:"PAYMENT_PROVIDER" default:"fake"`
+	EvaluationPriceCents int    `mapstructure:"PAYMENT_EVALUATION_PRICE_CENTS" default:"19900"`
+	Currency             string `mapstructure:"PAYMENT_CURRENCY" default:"BRL"`
+	// Receiving account written into Pix BR Codes
+	PixKey               string `mapstructure:"PAYMENT_PIX_KEY"`
+	PixMerchantName      string `mapstructure:"PAYMENT_PIX_MERCHANT_NAME" default:"INDICAR"`
+	PixMerchantCity      string `mapstructure:"PAYMENT_PIX_MERCHANT_CITY" default:"SAO PAULO"`
+	PixExpirationMinutes int    `mapstructure:"PAYMENT_PIX_EXPIRATION_MINUTES" default:"30"`
+	// Share of the price kept when a paid evaluation is canceled after it started
+	LateCancellationFeePercent int `mapstructure:"PAYMENT_LATE_CANCELLATION_FEE_PERCENT" default:"50"`
+	// Serve /fake-psp, which lets anyone mark Pix charges of the fake provider as paid
+	FakePSP bool `mapstructure:"PAYMENT_FAKE_PSP" default:"false"`
+	// File where the fake provider keeps its charges across restarts
+	FakeStateFile string `mapstructure:"PAYMENT_FAKE_STATE_FILE" default:"./data/fake-psp.json"`
+	// Secret shared with the fake provider to sign its webhook calls; each
+	// provider has its own, so that one cannot sign events of another
+	FakeWebhookSecret string `mapstructure:"PAYMENT_FAKE_WEBHOOK_SECRET"`
+}
+
+type payout struct {
+	// Share of the evaluation price kept by the platform
+	CommissionPercent int `mapstructure:"PAYOUT_COMMISSION_PERCENT" default:"20"`
+	// One of "daily", "weekly" or "monthly"
+	Schedule string `mapstructure:"PAYOUT_SCHEDULE" default:"weekly"`
+	// Day of the week (0 is Sunday) for weekly payouts, or of the month for monthly ones
+	Day int `mapstructure:"PAYOUT_DAY" default:"1"`
+	// Balances below this amount are carried over to the next batch
+	MinAmountCents int `mapstructure:"PAYOUT_MIN_AMOUNT_CENTS" default:"5000"`
+	// Account the transfers are paid from, written into the CNAB 240 files
+	// exported for the bank; the company is the one of the receipts
+	BankCode         string `mapstructure:"PAYOUT_BANK_CODE"`
+	BankName         string `mapstructure:"PAYOUT_BANK_NAME"`
+	BankAgreement    string `mapstructure:"PAYOUT_BANK_AGREEMENT"`
+	BankAgency       string `mapstructure:"PAYOUT_BANK_AGENCY"`
+	BankAgencyDigit  string `mapstructure:"PAYOUT_BANK_AGENCY_DIGIT"`
+	BankAccount      string `mapstructure:"PAYOUT_BANK_ACCOUNT"`
+	BankAccountDigit string `mapstructure:"PAYOUT_BANK_ACCOUNT_DIGIT"`
+}
+
+type idempotency struct {
+	// How long responses are kept for retries with the same Idempotency-Key
+	KeyTTLHours int `mapstructure:"IDEMPOTENCY_KEY_TTL_HOURS" default:"24"`
+}
+
+type receipt struct {
+	// Company that receives the payments, as written on receipts
+	CompanyName     string `mapstructure:"RECEIPT_COMPANY_NAME" default:"INDICAR"`
+	CompanyDocument string `mapstructure:"RECEIPT_COMPANY_DOCUMENT"`
+	// ISS rate included in evaluation prices
+	ISSPercent float64 `mapstructure:"RECEIPT_ISS_PERCENT" default:"5"`
+}
+
+type invoice struct {
+	// One of "local"
+	Issuer string `mapstructure:"INVOICE_ISSUER" default:"local"`
+	// Municipal service code of evaluations (item of the LC 116 list)
+	ServiceCode string `mapstructure:"INVOICE_SERVICE_CODE"`
+	// Issuing is retried up to this many attempts before the invoice is dead
+	MaxAttempts int `mapstructure:"INVOICE_MAX_ATTEMPTS" default:"8"`
+	// Delay before the first retry, doubled after each failed attempt up to the maximum
+	RetryBaseSeconds int `mapstructure:"INVOICE_RETRY_BASE_SECONDS" default:"60"`
+	RetryMaxSeconds  int `mapstructure:"INVOICE_RETRY_MAX_SECONDS" default:"21600"`
+}
+
+type notification struct {
+	// Deliveries are retried up to this many attempts before the notification is dead
+	MaxAttempts int `mapstructure:"NOTIFICATION_MAX_ATTEMPTS" default:"5"`
+	// Delay before the first retry, doubled after each failed attempt up to the maximum
+	RetryBaseSeconds int `mapstructure:"NOTIFICATION_RETRY_BASE_SECONDS" default:"30"`
+	RetryMaxSeconds  int `mapstructure:"NOTIFICATION_RETRY_MAX_SECONDS" default:"3600"`
+}
+
+type push struct {
+	// Google service account key (JSON) allowed to send FCM messages; Android push is disabled when empty
+	FCMCredentialsFile string `mapstructure:"PUSH_FCM_CREDENTIALS_FILE"`
+	FCMBaseURL         string `mapstructure:"PUSH_FCM_BASE_URL" default:"https://fcm.googleapis.com"`
+	// APNs signing key (.p8); iOS push is disabled when empty
+	APNsKeyFile string `mapstructure:"PUSH_APNS_KEY_FILE"`
+	APNsKeyID   string `mapstructure:"PUSH_APNS_KEY_ID"`
+	APNsTeamID  string `mapstructure:"PUSH_APNS_TEAM_ID"`
+	// Bundle ID of the iOS app
+	APNsTopic   string `mapstructure:"PUSH_APNS_TOPIC"`
+	APNsSandbox bool   `mapstructure:"PUSH_APNS_SANDBOX" default:"false"`
+	// Overrides the production or sandbox APNs URL, e.g. to use the fake server
+	APNsBaseURL string `mapstructure:"PUSH_APNS_BASE_URL"`
+	// Serve the fake FCM/APNs server under /fake-push
+	FakeServer bool `mapstructure:"PUSH_FAKE_SERVER" default:"false"`
 }
 
 func getMappedEnvs(configStruct reflect.Type) []string {
@@ -116,6 +251,50 @@ func Load() error {
 		return err
 	}
 
+	if err := viper.Unmarshal(&configuration.App); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Report); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Storage); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Photos); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Payment); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Payout); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Idempotency); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Receipt); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Invoice); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Notification); err != nil {
+		return err
+	}
+
+	if err := viper.Unmarshal(&configuration.Push); err != nil {
+		return err
+	}
+
 	return nil
 }
 
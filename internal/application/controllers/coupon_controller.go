@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"errors"
+	"indicar-api/internal/application/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CouponController struct {
+	couponService *services.CouponService
+}
+
+func NewCouponController(couponService *services.CouponService) *CouponController {
+	return &CouponController{
+		couponService: couponService,
+	}
+}
+
+// @Summary List coupons
+// @Description List promotional codes (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param active query bool false "Active coupons only, or inactive only"
+// @Param city_id query int false "City ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} services.Page[entities.Coupon]
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/coupons [get]
+func (c *CouponController) List(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.ListCouponsInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	coupons, err := c.couponService.List(userID, input)
+	if err != nil {
+		respondCouponError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, coupons)
+}
+
+// @Summary Create coupon
+// @Description Add a promotional code (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param input body services.CouponInput true "Coupon"
+// @Success 201 {object} entities.Coupon
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /admin/coupons [post]
+func (c *CouponController) Create(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.CouponInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	coupon, err := c.couponService.Create(userID, input)
+	if err != nil {
+		respondCouponError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, coupon)
+}
+
+// @Summary Update coupon
+// @Description Replace a promotional code; redemptions already made are kept (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Coupon ID"
+// @Param input body services.CouponInput true "Coupon"
+// @Success 200 {object} entities.Coupon
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /admin/coupons/{id} [put]
+func (c *CouponController) Update(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	couponID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid coupon ID"})
+		return
+	}
+
+	var input services.CouponInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	coupon, err := c.couponService.Update(userID, couponID, input)
+	if err != nil {
+		respondCouponError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, coupon)
+}
+
+// @Summary List coupon redemptions
+// @Description List the evaluations that used a coupon (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Coupon ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} services.Page[entities.CouponRedemption]
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/coupons/{id}/redemptions [get]
+func (c *CouponController) ListRedemptions(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	couponID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid coupon ID"})
+		return
+	}
+
+	var input services.PageInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redemptions, err := c.couponService.ListRedemptions(userID, couponID, input)
+	if err != nil {
+		respondCouponError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, redemptions)
+}
+
+func respondCouponError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAdminRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCouponNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCouponExists):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCoupon):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
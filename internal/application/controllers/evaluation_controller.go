@@ -1,13 +1,19 @@
 package controllers
 
 import (
+	"errors"
 	"indicar-api/internal/application/services"
+	"indicar-api/internal/infrastructure/media"
+	"indicar-api/internal/infrastructure/storage"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+const maxPhotoUploadSize = 10 * 1024 * 1024 // 10MB
+
 type EvaluationController struct {
 	evaluationService      *services.EvaluationService
 	evaluationPhotoService *services.EvaluationPhotoService
@@ -46,6 +52,11 @@ func (c *EvaluationController) Create(ctx *gin.Context) {
 	}
 
 	evaluation, err := c.evaluationService.Create(userID, input)
+	if errors.Is(err, services.ErrPaymentUnsupported) || errors.Is(err, services.ErrInvalidQuote) ||
+		errors.Is(err, services.ErrInvalidCoupon) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -55,13 +66,16 @@ func (c *EvaluationController) Create(ctx *gin.Context) {
 }
 
 // @Summary Get evaluation by ID
-// @Description Get a specific evaluation by its ID
+// @Description Get a specific evaluation by its ID, optionally embedding related resources
 // @Tags evaluations
 // @Produce json
 // @Security Bearer
 // @Param id path int true "Evaluation ID"
-// @Success 200 {object} entities.Evaluation
+// @Param include query string false "Comma-separated related resources to embed (report, photos); only for the requester, the assigned evaluator or an admin"
+// @Success 200 {object} services.EvaluationDetails
 // @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /evaluations/{id} [get]
 func (c *EvaluationController) GetByID(ctx *gin.Context) {
@@ -77,7 +91,45 @@ func (c *EvaluationController) GetByID(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, evaluation)
+	include := ctx.Query("include")
+	if include == "" {
+		ctx.JSON(http.StatusOK, evaluation)
+		return
+	}
+
+	resources := strings.Split(include, ",")
+	for i, resource := range resources {
+		resources[i] = strings.TrimSpace(resource)
+		if resources[i] != "report" && resources[i] != "photos" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid include: " + resource})
+			return
+		}
+	}
+
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if err := c.evaluationService.CheckDetailsAccess(evaluation, userID); err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	details := services.EvaluationDetails{Evaluation: evaluation}
+	for _, resource := range resources {
+		if resource == "report" {
+			details.Report, err = c.evaluationService.GetReportSummary(id)
+		} else {
+			details.Photos, err = c.evaluationPhotoService.ListPhotos(id)
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, details)
 }
 
 // @Summary List evaluations
@@ -101,7 +153,7 @@ func (c *EvaluationController) List(ctx *gin.Context) {
 }
 
 // @Summary Update evaluation
-// @Description Update an existing evaluation
+// @Description Assign, move or annotate an evaluation. Evaluators can only assign themselves; starting and completing is up to the assigned evaluator or an admin, and the requester can cancel it
 // @Tags evaluations
 // @Accept json
 // @Produce json
@@ -110,9 +162,18 @@ func (c *EvaluationController) List(ctx *gin.Context) {
 // @Param input body services.UpdateEvaluationInput true "Evaluation update data"
 // @Success 200 {object} entities.Evaluation
 // @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /evaluations/{id} [patch]
 func (c *EvaluationController) Update(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
@@ -125,9 +186,16 @@ func (c *EvaluationController) Update(ctx *gin.Context) {
 		return
 	}
 
-	evaluation, err := c.evaluationService.Update(id, input)
+	evaluation, err := c.evaluationService.Update(id, userID, input)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, services.ErrEvaluationNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEvaluationAccessDenied):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
@@ -135,15 +203,18 @@ func (c *EvaluationController) Update(ctx *gin.Context) {
 }
 
 // @Summary Upload evaluation photo
-// @Description Upload a photo for an evaluation
+// @Description Upload a photo for an evaluation (not allowed once the evaluation is completed)
 // @Tags evaluations
 // @Accept multipart/form-data
 // @Produce json
 // @Security Bearer
 // @Param id path int true "Evaluation ID"
-// @Param photo formData file true "Photo file (max 10MB)"
+// @Param photo formData file true "Photo file: JPEG, PNG, GIF, WebP or HEIC (max 10MB)"
+// @Param category query string false "Photo category" Enums(front, rear, left, right, engine, odometer, chassis_number, interior, damage_detail)
+// @Param caption query string false "Photo caption (max 255 characters)"
 // @Success 201 {object} entities.EvaluationPhoto
 // @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /evaluations/{id}/photos [post]
 func (c *EvaluationController) UploadPhoto(ctx *gin.Context) {
@@ -153,56 +224,103 @@ func (c *EvaluationController) UploadPhoto(ctx *gin.Context) {
 		return
 	}
 
-	file, err := ctx.FormFile("photo")
+	var query services.UploadPhotoQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	part, err := streamFormFile(ctx, "photo", maxPhotoUploadSize)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "photo file is required"})
 		return
 	}
+	defer part.Close()
 
-	// Validate file size (max 10MB)
-	if file.Size > 10*1024*1024 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "file size exceeds 10MB limit"})
+	// The real type is detected from the content by the service; the declared
+	// type only has to agree with it
+	contentType := part.Header.Get("Content-Type")
+
+	input := services.UploadPhotoInput{
+		File:        part,
+		ContentType: contentType,
+		Category:    query.Category,
+	}
+	if caption := strings.TrimSpace(query.Caption); caption != "" {
+		input.Caption = &caption
+	}
+
+	photo, err := c.evaluationPhotoService.UploadPhoto(evaluationID, input)
+	if err != nil {
+		respondPhotoError(ctx, err, http.StatusInternalServerError)
 		return
 	}
 
-	// Validate file type (only images allowed)
-	contentType := file.Header.Get("Content-Type")
-	allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp"}
-	isValidType := false
-	for _, allowedType := range allowedTypes {
-		if contentType == allowedType {
-			isValidType = true
-			break
-		}
+	ctx.JSON(http.StatusCreated, photo)
+}
+
+// @Summary Create photo upload URL
+// @Description Get a pre-signed POST to upload a photo directly to storage; confirm it afterwards with /photos/confirm (not allowed once the evaluation is completed)
+// @Tags evaluations
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param input body services.CreatePhotoUploadInput true "Photo content type and size (max 10MB)"
+// @Success 201 {object} services.PhotoUploadURL
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/photos/upload-url [post]
+func (c *EvaluationController) CreatePhotoUploadURL(ctx *gin.Context) {
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return
 	}
-	if !isValidType {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "only image files are allowed (JPEG, PNG, GIF, WebP)"})
+
+	var input services.CreatePhotoUploadInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	f, err := file.Open()
+	upload, err := c.evaluationPhotoService.CreateUploadURL(evaluationID, input)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error reading file"})
+		respondPhotoError(ctx, err, http.StatusBadRequest)
 		return
 	}
-	defer f.Close()
 
-	fileBytes := make([]byte, file.Size)
-	_, err = f.Read(fileBytes)
+	ctx.JSON(http.StatusCreated, upload)
+}
+
+// @Summary Confirm photo upload
+// @Description Register a photo uploaded directly to storage through a pre-signed POST (not allowed once the evaluation is completed)
+// @Tags evaluations
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param input body services.ConfirmPhotoUploadInput true "Uploaded object key"
+// @Success 201 {object} entities.EvaluationPhoto
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/photos/confirm [post]
+func (c *EvaluationController) ConfirmPhotoUpload(ctx *gin.Context) {
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error reading file"})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
 		return
 	}
 
-	input := services.UploadPhotoInput{
-		File:        fileBytes,
-		ContentType: contentType,
-		SizeBytes:   int(file.Size),
+	var input services.ConfirmPhotoUploadInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	photo, err := c.evaluationPhotoService.UploadPhoto(evaluationID, input)
+	photo, err := c.evaluationPhotoService.ConfirmUpload(evaluationID, input)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondPhotoError(ctx, err, http.StatusBadRequest)
 		return
 	}
 
@@ -234,3 +352,235 @@ func (c *EvaluationController) ListPhotos(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, photos)
 }
+
+// @Summary Update evaluation photo
+// @Description Set the category or caption of a photo; empty values clear them (requester, assigned evaluator or admin; not allowed once the evaluation is completed)
+// @Tags evaluations
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param photoId path int true "Photo ID"
+// @Param input body services.UpdatePhotoInput true "Category and caption"
+// @Success 200 {object} entities.EvaluationPhoto
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/photos/{photoId} [patch]
+func (c *EvaluationController) UpdatePhoto(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return
+	}
+
+	photoID, err := strconv.Atoi(ctx.Param("photoId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid photo ID"})
+		return
+	}
+
+	var input services.UpdatePhotoInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	photo, err := c.evaluationPhotoService.UpdatePhoto(evaluationID, photoID, userID, input)
+	if err != nil {
+		respondPhotoError(ctx, err, http.StatusBadRequest)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, photo)
+}
+
+// @Summary Reorder evaluation photos
+// @Description Set the gallery order of the photos of an evaluation (requester, assigned evaluator or admin; not allowed once the evaluation is completed)
+// @Tags evaluations
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param input body services.ReorderPhotosInput true "Every photo ID in the new order"
+// @Success 200 {array} entities.EvaluationPhoto
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/photos/order [put]
+func (c *EvaluationController) ReorderPhotos(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return
+	}
+
+	var input services.ReorderPhotosInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	photos, err := c.evaluationPhotoService.ReorderPhotos(evaluationID, userID, input)
+	if err != nil {
+		respondPhotoError(ctx, err, http.StatusBadRequest)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, photos)
+}
+
+// @Summary Get photo completeness
+// @Description List the photo categories required for the evaluation's inspection type that are still missing
+// @Tags evaluations
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Success 200 {object} services.PhotoCompleteness
+// @Failure 404 {object} map[string]interface{}
+// @Router /evaluations/{id}/photos/completeness [get]
+func (c *EvaluationController) GetPhotoCompleteness(ctx *gin.Context) {
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return
+	}
+
+	completeness, err := c.evaluationPhotoService.GetCompleteness(evaluationID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "evaluation not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, completeness)
+}
+
+// @Summary Delete evaluation photo
+// @Description Delete a photo and its stored files (requester, assigned evaluator or admin; not allowed once the evaluation is completed)
+// @Tags evaluations
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param photoId path int true "Photo ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/photos/{photoId} [delete]
+func (c *EvaluationController) DeletePhoto(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return
+	}
+
+	photoID, err := strconv.Atoi(ctx.Param("photoId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid photo ID"})
+		return
+	}
+
+	if err := c.evaluationPhotoService.DeletePhoto(evaluationID, photoID, userID); err != nil {
+		respondPhotoError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Replace evaluation photo
+// @Description Replace the file of a photo, keeping its category, caption and position (requester, assigned evaluator or admin; not allowed once the evaluation is completed)
+// @Tags evaluations
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param photoId path int true "Photo ID"
+// @Param photo formData file true "Photo file: JPEG, PNG, GIF, WebP or HEIC (max 10MB)"
+// @Success 200 {object} entities.EvaluationPhoto
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/photos/{photoId} [put]
+func (c *EvaluationController) ReplacePhoto(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return
+	}
+
+	photoID, err := strconv.Atoi(ctx.Param("photoId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid photo ID"})
+		return
+	}
+
+	part, err := streamFormFile(ctx, "photo", maxPhotoUploadSize)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "photo file is required"})
+		return
+	}
+	defer part.Close()
+
+	input := services.UploadPhotoInput{
+		File:        part,
+		ContentType: part.Header.Get("Content-Type"),
+	}
+
+	photo, err := c.evaluationPhotoService.ReplacePhoto(evaluationID, photoID, userID, input)
+	if err != nil {
+		respondPhotoError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, photo)
+}
+
+// respondPhotoError maps the errors of photo changes to HTTP statuses, using
+// fallback for the others
+func respondPhotoError(ctx *gin.Context, err error, fallback int) {
+	switch {
+	case errors.Is(err, services.ErrPhotoNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPhotoAccessDenied):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEvaluationCompleted), errors.Is(err, services.ErrDuplicatePhoto):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrTooLarge):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "file size exceeds 10MB limit"})
+	case errors.Is(err, media.ErrInvalidFile):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(fallback, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"indicar-api/internal/infrastructure/storage"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileController serves files kept by the local storage driver
+type FileController struct {
+	store *storage.LocalStore
+}
+
+func NewFileController(store *storage.LocalStore) *FileController {
+	return &FileController{
+		store: store,
+	}
+}
+
+// @Summary Download stored file
+// @Description Serve a file from local storage through a pre-signed URL
+// @Tags files
+// @Produce octet-stream
+// @Param key path string true "Object key"
+// @Param expires query int true "Expiration (unix timestamp)"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /files/{key} [get]
+func (c *FileController) Serve(ctx *gin.Context) {
+	key := strings.TrimPrefix(ctx.Param("key"), "/")
+
+	if err := c.store.VerifySignature(key, ctx.Query("expires"), ctx.Query("signature")); err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, info, err := c.store.Get(key)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	defer body.Close()
+
+	ctx.DataFromReader(http.StatusOK, info.Size, info.ContentType, body, nil)
+}
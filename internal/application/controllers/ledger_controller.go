@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/internal/application/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerController struct {
+	ledgerService *services.LedgerService
+	payoutService *services.PayoutService
+}
+
+func NewLedgerController(ledgerService *services.LedgerService, payoutService *services.PayoutService) *LedgerController {
+	return &LedgerController{
+		ledgerService: ledgerService,
+		payoutService: payoutService,
+	}
+}
+
+// @Summary Get earnings
+// @Description Get the balance owed to the current evaluator and the statement of their earnings, refunds, penalties and payouts
+// @Tags earnings
+// @Produce json
+// @Security Bearer
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} services.Earnings
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /me/earnings [get]
+func (c *LedgerController) GetEarnings(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.PageInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	earnings, err := c.ledgerService.GetEarnings(userID, input)
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, earnings)
+}
+
+// @Summary Update payout details
+// @Description Set the Pix key the current evaluator's payouts are sent to
+// @Tags earnings
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param input body services.PayoutDetailsInput true "Payout details"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /me/payout-details [put]
+func (c *LedgerController) UpdatePayoutDetails(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.PayoutDetailsInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ledgerService.UpdatePayoutDetails(userID, input); err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "payout details updated"})
+}
+
+// @Summary Penalize evaluator
+// @Description Debit an amount from an evaluator's balance (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluator user ID"
+// @Param input body services.PenaltyInput true "Penalty"
+// @Success 201 {object} entities.LedgerTransaction
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/evaluators/{id}/penalties [post]
+func (c *LedgerController) CreatePenalty(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	evaluatorID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluator ID"})
+		return
+	}
+
+	var input services.PenaltyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaction, err := c.ledgerService.RecordPenalty(userID, evaluatorID, input)
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, transaction)
+}
+
+// @Summary List payout batches
+// @Description List payout batches, most recent first (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} services.Page[entities.PayoutBatch]
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/payout-batches [get]
+func (c *LedgerController) ListBatches(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.PageInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batches, err := c.payoutService.ListBatches(userID, input)
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, batches)
+}
+
+// @Summary Create payout batch
+// @Description Pay out now every evaluator balance above the minimum, without waiting for the schedule (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Success 201 {object} entities.PayoutBatch
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/payout-batches [post]
+func (c *LedgerController) CreateBatch(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	batch, err := c.payoutService.CreateBatch(userID)
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, batch)
+}
+
+// @Summary Get payout batch
+// @Description Get a payout batch with its payouts (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Payout batch ID"
+// @Success 200 {object} entities.PayoutBatch
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/payout-batches/{id} [get]
+func (c *LedgerController) GetBatch(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	batchID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid payout batch ID"})
+		return
+	}
+
+	batch, err := c.payoutService.GetBatch(userID, batchID)
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, batch)
+}
+
+// @Summary Export payout batch
+// @Description Download the transfers of a payout batch as a CNAB 240 remittance file paying each evaluator by Pix and mark it as exported (admin only)
+// @Tags admin
+// @Produce text/plain
+// @Security Bearer
+// @Param id path int true "Payout batch ID"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/payout-batches/{id}/export [get]
+func (c *LedgerController) ExportBatch(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	batchID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid payout batch ID"})
+		return
+	}
+
+	content, err := c.payoutService.ExportBatch(userID, batchID)
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="repasses-%d.rem"`, batchID))
+	ctx.Data(http.StatusOK, "text/plain; charset=us-ascii", content)
+}
+
+func respondLedgerError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAdminRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEvaluatorNotFound),
+		errors.Is(err, services.ErrPayoutBatchNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPayoutBankNotConfigured):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Room left for multipart boundaries and headers on top of the file limit
+const multipartOverhead = 1024 * 1024
+
+var errFileRequired = errors.New("file is required")
+
+// streamFormFile returns the file part named field of a multipart request
+// without buffering the upload in memory or on disk. The body is capped at
+// maxSize plus some overhead; the part itself must still be read through a
+// size-limited reader.
+func streamFormFile(ctx *gin.Context, field string, maxSize int64) (*multipart.Part, error) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize+multipartOverhead)
+
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errFileRequired
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == field && part.FileName() != "" {
+			return part, nil
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"errors"
+	"indicar-api/internal/application/services"
+	"indicar-api/internal/infrastructure/payment"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentController struct {
+	paymentService *services.PaymentService
+}
+
+func NewPaymentController(paymentService *services.PaymentService) *PaymentController {
+	return &PaymentController{
+		paymentService: paymentService,
+	}
+}
+
+// @Summary Get evaluation payment
+// @Description Get the payment of an evaluation, with its status refreshed from the provider
+// @Tags payments
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Success 200 {object} entities.Payment
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /evaluations/{id}/payment [get]
+func (c *PaymentController) Get(ctx *gin.Context) {
+	userID, evaluationID, ok := paymentRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	record, err := c.paymentService.GetByEvaluation(evaluationID, userID)
+	if err != nil {
+		respondPaymentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+// @Summary Get Pix QR code
+// @Description Get a PNG QR code of the BR Code of a pending Pix payment
+// @Tags payments
+// @Produce png
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Success 200 {file} binary
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/payment/pix-qr [get]
+func (c *PaymentController) GetPixQRCode(ctx *gin.Context) {
+	userID, evaluationID, ok := paymentRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	png, err := c.paymentService.GetPixQRCode(evaluationID, userID)
+	if err != nil {
+		respondPaymentError(ctx, err)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "image/png", png)
+}
+
+// @Summary Retry evaluation payment
+// @Description Create a new charge for an evaluation not yet completed or canceled whose payment failed, was canceled or expired (requester only)
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param input body services.RetryPaymentInput false "Payment method (defaults to the previous one)"
+// @Success 201 {object} entities.Payment
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/payment [post]
+func (c *PaymentController) Retry(ctx *gin.Context) {
+	userID, evaluationID, ok := paymentRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	var input services.RetryPaymentInput
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	record, err := c.paymentService.Retry(evaluationID, userID, input)
+	if err != nil {
+		respondPaymentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, record)
+}
+
+// @Summary Capture evaluation payment
+// @Description Settle an authorized payment (admin only)
+// @Tags payments
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Success 200 {object} entities.Payment
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/payment/capture [post]
+func (c *PaymentController) Capture(ctx *gin.Context) {
+	userID, evaluationID, ok := paymentRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	record, err := c.paymentService.Capture(evaluationID, userID)
+	if err != nil {
+		respondPaymentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+// @Summary Refund evaluation payment
+// @Description Refund all or part of a paid payment, with a reason code (admin only)
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Param input body services.RefundPaymentInput false "Amount to refund (defaults to the whole remaining amount) and reason"
+// @Success 201 {object} entities.Refund
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /evaluations/{id}/payment/refund [post]
+func (c *PaymentController) Refund(ctx *gin.Context) {
+	userID, evaluationID, ok := paymentRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	var input services.RefundPaymentInput
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	refund, err := c.paymentService.Refund(evaluationID, userID, input)
+	if err != nil {
+		respondPaymentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, refund)
+}
+
+// @Summary List evaluation payment refunds
+// @Description List the refunds of an evaluation's payment, including failed attempts
+// @Tags payments
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Success 200 {array} entities.Refund
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /evaluations/{id}/payment/refunds [get]
+func (c *PaymentController) ListRefunds(ctx *gin.Context) {
+	userID, evaluationID, ok := paymentRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	refunds, err := c.paymentService.ListRefunds(evaluationID, userID)
+	if err != nil {
+		respondPaymentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, refunds)
+}
+
+// @Summary Simulate Pix payment
+// @Description Pay a Pix charge of the fake payment provider (development only)
+// @Tags payments
+// @Produce json
+// @Param chargeId path string true "Provider charge ID"
+// @Success 200 {object} entities.Payment
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /fake-psp/pix/{chargeId}/pay [post]
+func (c *PaymentController) SimulatePixPayment(ctx *gin.Context) {
+	record, err := c.paymentService.SimulatePixPayment(ctx.Param("chargeId"))
+	if err != nil {
+		respondPaymentError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+func paymentRequestIDs(ctx *gin.Context) (int, int, bool) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return 0, 0, false
+	}
+
+	return userID, evaluationID, true
+}
+
+// respondPaymentError maps payment and provider errors to HTTP statuses
+func respondPaymentError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentAccessDenied), errors.Is(err, services.ErrAdminRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentNotRetryable), errors.Is(err, services.ErrEvaluationNotPayable),
+		errors.Is(err, services.ErrPixCodeUnavailable),
+		errors.Is(err, services.ErrPaymentTransition), errors.Is(err, services.ErrPaymentNotRefundable),
+		errors.Is(err, payment.ErrInvalidState):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, payment.ErrInvalidAmount), errors.Is(err, services.ErrPaymentUnsupported):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
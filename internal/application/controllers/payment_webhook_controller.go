@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"indicar-api/internal/application/services"
+	"indicar-api/internal/infrastructure/payment"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxWebhookSize = 1 << 20 // 1MB
+
+type PaymentWebhookController struct {
+	paymentWebhookService *services.PaymentWebhookService
+}
+
+func NewPaymentWebhookController(paymentWebhookService *services.PaymentWebhookService) *PaymentWebhookController {
+	return &PaymentWebhookController{
+		paymentWebhookService: paymentWebhookService,
+	}
+}
+
+// @Summary Receive payment webhook
+// @Description Receive a signed notification from a payment provider. Events are deduplicated by their ID; failures answer 500 so that the provider retries.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "Payment provider"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /webhooks/payments/{provider} [post]
+func (c *PaymentWebhookController) Receive(ctx *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxWebhookSize+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	if len(body) > maxWebhookSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "webhook body too large"})
+		return
+	}
+
+	event, duplicate, err := c.paymentWebhookService.Receive(ctx.Param("provider"), ctx.Request.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebhookProviderUnknown):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, payment.ErrInvalidSignature):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, payment.ErrInvalidEvent):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"id":        event.ID,
+		"status":    event.Status,
+		"duplicate": duplicate,
+	})
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"indicar-api/internal/application/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PhotoMatchController struct {
+	photoMatchService *services.PhotoMatchService
+}
+
+func NewPhotoMatchController(photoMatchService *services.PhotoMatchService) *PhotoMatchController {
+	return &PhotoMatchController{
+		photoMatchService: photoMatchService,
+	}
+}
+
+// @Summary List suspicious photo matches
+// @Description List photos identical or similar to photos of other evaluations (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param status query string false "Review status (default pending)" Enums(pending, confirmed, dismissed)
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} services.Page[entities.PhotoMatch]
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/photo-matches [get]
+func (c *PhotoMatchController) List(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.ListPhotoMatchesInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	matches, err := c.photoMatchService.List(userID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrAdminRequired) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, matches)
+}
+
+// @Summary Review photo match
+// @Description Confirm or dismiss a suspicious photo match (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Photo match ID"
+// @Param input body services.ReviewPhotoMatchInput true "Review decision"
+// @Success 200 {object} entities.PhotoMatch
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/photo-matches/{id} [patch]
+func (c *PhotoMatchController) Review(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	matchID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid photo match ID"})
+		return
+	}
+
+	var input services.ReviewPhotoMatchInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	match, err := c.photoMatchService.Review(userID, matchID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAdminRequired):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPhotoMatchNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, match)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"errors"
+	"indicar-api/internal/application/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PricingController struct {
+	pricingService *services.PricingService
+}
+
+func NewPricingController(pricingService *services.PricingService) *PricingController {
+	return &PricingController{
+		pricingService: pricingService,
+	}
+}
+
+// @Summary Quote evaluation price
+// @Description Compute the price of an evaluation from the pricing rules, with the adjustments applied
+// @Tags evaluations
+// @Produce json
+// @Security Bearer
+// @Param city_id query int true "City ID"
+// @Param vehicle_category query string false "Vehicle category (default car)" Enums(car, motorcycle, pickup, suv, van, truck)
+// @Param vehicle_year query int false "Vehicle year"
+// @Param urgent query bool false "Urgent evaluation"
+// @Param scheduled_at query string false "Scheduled time (RFC 3339); defaults to now"
+// @Param coupon_code query string false "Coupon code"
+// @Success 200 {object} entities.PriceQuote
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /evaluations/quote [get]
+func (c *PricingController) Quote(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.PriceQuoteInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quote, err := c.pricingService.Quote(userID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuote) || errors.Is(err, services.ErrInvalidCoupon) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, quote)
+}
+
+// @Summary List pricing rules
+// @Description List the base prices and modifiers used to price evaluations (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param kind query string false "Rule kind" Enums(base, vehicle_category, vehicle_year, urgency, time_slot)
+// @Param city_id query int false "City ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} services.Page[entities.PricingRule]
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/pricing-rules [get]
+func (c *PricingController) ListRules(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.ListPricingRulesInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, err := c.pricingService.ListRules(userID, input)
+	if err != nil {
+		respondPricingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rules)
+}
+
+// @Summary Create pricing rule
+// @Description Add a base price or a price modifier (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param input body services.PricingRuleInput true "Pricing rule"
+// @Success 201 {object} entities.PricingRule
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/pricing-rules [post]
+func (c *PricingController) CreateRule(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.PricingRuleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := c.pricingService.CreateRule(userID, input)
+	if err != nil {
+		respondPricingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, rule)
+}
+
+// @Summary Update pricing rule
+// @Description Replace a pricing rule; evaluations already requested keep their price (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Pricing rule ID"
+// @Param input body services.PricingRuleInput true "Pricing rule"
+// @Success 200 {object} entities.PricingRule
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/pricing-rules/{id} [put]
+func (c *PricingController) UpdateRule(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ruleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid pricing rule ID"})
+		return
+	}
+
+	var input services.PricingRuleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := c.pricingService.UpdateRule(userID, ruleID, input)
+	if err != nil {
+		respondPricingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rule)
+}
+
+// @Summary Delete pricing rule
+// @Description Remove a pricing rule (admin only)
+// @Tags admin
+// @Security Bearer
+// @Param id path int true "Pricing rule ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/pricing-rules/{id} [delete]
+func (c *PricingController) DeleteRule(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ruleID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid pricing rule ID"})
+		return
+	}
+
+	if err := c.pricingService.DeleteRule(userID, ruleID); err != nil {
+		respondPricingError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func respondPricingError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAdminRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPricingRuleNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPricingRule):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/internal/application/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReceiptController struct {
+	receiptService *services.ReceiptService
+}
+
+func NewReceiptController(receiptService *services.ReceiptService) *ReceiptController {
+	return &ReceiptController{
+		receiptService: receiptService,
+	}
+}
+
+// @Summary Download payment receipt
+// @Description Download the PDF receipt of a captured payment, with its NFS-e number once issued (requester or admin only)
+// @Tags payments
+// @Produce application/pdf
+// @Security Bearer
+// @Param id path int true "Payment ID"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /payments/{id}/receipt [get]
+func (c *ReceiptController) Get(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	paymentID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment ID"})
+		return
+	}
+
+	receipt, content, err := c.receiptService.GetPDF(paymentID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReceiptAccessDenied):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrReceiptNotFound),
+			errors.Is(err, services.ErrPaymentNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrReceiptUnavailable):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	defer content.Close()
+
+	ctx.DataFromReader(http.StatusOK, -1, "application/pdf", content, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="recibo-%d.pdf"`, receipt.ID),
+	})
+}
@@ -1,20 +1,27 @@
 package controllers
 
 import (
+	"errors"
 	"indicar-api/internal/application/services"
+	"indicar-api/internal/infrastructure/media"
+	"indicar-api/internal/infrastructure/storage"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+const maxReportUploadSize = 50 * 1024 * 1024 // 50MB
+
 type ReportController struct {
-	reportService *services.ReportService
+	reportService      *services.ReportService
+	reportShareService *services.ReportShareService
 }
 
-func NewReportController(reportService *services.ReportService) *ReportController {
+func NewReportController(reportService *services.ReportService, reportShareService *services.ReportShareService) *ReportController {
 	return &ReportController{
-		reportService: reportService,
+		reportService:      reportService,
+		reportShareService: reportShareService,
 	}
 }
 
@@ -81,23 +88,103 @@ func (c *ReportController) CreateOrUpdate(ctx *gin.Context) {
 }
 
 // @Summary Get report by ID
-// @Description Get a specific report by its ID
+// @Description Get a specific report by its ID (report's evaluator, evaluation requester or admin)
 // @Tags reports
 // @Produce json
 // @Security Bearer
 // @Param id path int true "Report ID"
 // @Success 200 {object} entities.Report
 // @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /reports/{id} [get]
 func (c *ReportController) GetByID(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid report ID"})
 		return
 	}
 
-	report, err := c.reportService.GetByID(id)
+	report, err := c.reportService.GetByID(id, userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// @Summary List reports
+// @Description List the reports visible to the current user, with optional filters and pagination
+// @Tags reports
+// @Produce json
+// @Security Bearer
+// @Param status query string false "Filter by status (draft, finalized)"
+// @Param evaluator query string false "Filter by evaluator: 'me' or an evaluator ID"
+// @Param page query int false "Page number (default 1)"
+// @Param page_size query int false "Page size (default 20, max 100)"
+// @Success 200 {object} services.Page[entities.Report]
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /reports [get]
+func (c *ReportController) List(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input services.ListReportsInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reports, err := c.reportService.List(userID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEvaluatorFilter) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reports)
+}
+
+// @Summary Get report by evaluation
+// @Description Get the report of a specific evaluation
+// @Tags reports
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Evaluation ID"
+// @Success 200 {object} entities.Report
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /evaluations/{id}/report [get]
+func (c *ReportController) GetByEvaluation(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	evaluationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid evaluation ID"})
+		return
+	}
+
+	report, err := c.reportService.GetByEvaluationID(evaluationID, userID)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -107,11 +194,12 @@ func (c *ReportController) GetByID(ctx *gin.Context) {
 }
 
 // @Summary Get report file URL
-// @Description Get a pre-signed URL for downloading the report file
+// @Description Get a pre-signed URL for downloading the report file (latest version unless one is given)
 // @Tags reports
 // @Produce json
 // @Security Bearer
 // @Param id path int true "Report ID"
+// @Param version query int false "File version"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]interface{}
 // @Failure 401 {object} map[string]interface{}
@@ -130,7 +218,16 @@ func (c *ReportController) GetFileURL(ctx *gin.Context) {
 		return
 	}
 
-	fileURL, err := c.reportService.GetReportFileURL(reportID, userID)
+	version := 0
+	if v := ctx.Query("version"); v != "" {
+		version, err = strconv.Atoi(v)
+		if err != nil || version < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+	}
+
+	fileURL, err := c.reportService.GetReportFileURL(reportID, userID, version)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -139,8 +236,41 @@ func (c *ReportController) GetFileURL(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"url": fileURL})
 }
 
+// @Summary List report file versions
+// @Description List every uploaded version of the report file, newest first
+// @Tags reports
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Report ID"
+// @Success 200 {array} entities.ReportFile
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /reports/{id}/files [get]
+func (c *ReportController) ListFiles(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	reportID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid report ID"})
+		return
+	}
+
+	files, err := c.reportService.ListReportFiles(reportID, userID)
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, files)
+}
+
 // @Summary Upload report file
-// @Description Upload a PDF file for a report
+// @Description Upload a new version of the report PDF (not allowed once the report is finalized)
 // @Tags reports
 // @Accept multipart/form-data
 // @Produce json
@@ -165,51 +295,237 @@ func (c *ReportController) UploadFile(ctx *gin.Context) {
 		return
 	}
 
-	file, err := ctx.FormFile("file")
+	part, err := streamFormFile(ctx, "file", maxReportUploadSize)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
 		return
 	}
+	defer part.Close()
+
+	// The PDF structure is checked by the service; the declared type only has
+	// to agree with it
+	contentType := part.Header.Get("Content-Type")
+
+	input := services.UploadReportFileInput{
+		File:        part,
+		ContentType: contentType,
+		Filename:    part.FileName(),
+	}
+
+	reportFile, err := c.reportService.UploadReportFile(reportID, userID, input)
+	if err != nil {
+		if errors.Is(err, storage.ErrTooLarge) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "file size exceeds 50MB limit"})
+			return
+		}
+		if errors.Is(err, media.ErrInvalidFile) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, reportFile)
+}
+
+// @Summary Create report share link
+// @Description Create a revocable, expiring and optionally password-protected public link to a report
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Report ID"
+// @Param input body services.CreateReportShareInput true "Share link options"
+// @Success 201 {object} entities.ReportShare
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /reports/{id}/shares [post]
+func (c *ReportController) CreateShare(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
 
-	// Validate file size (max 50MB)
-	if file.Size > 50*1024*1024 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "file size exceeds 50MB limit"})
+	reportID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid report ID"})
 		return
 	}
 
-	// Validate file type (only PDF)
-	contentType := file.Header.Get("Content-Type")
-	if contentType != "application/pdf" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "only PDF files are allowed"})
+	var input services.CreateReportShareInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := c.reportShareService.Create(reportID, userID, input)
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, share)
+}
+
+// @Summary List report share links
+// @Description List the share links created for a report
+// @Tags reports
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Report ID"
+// @Success 200 {array} entities.ReportShare
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /reports/{id}/shares [get]
+func (c *ReportController) ListShares(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	f, err := file.Open()
+	reportID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error reading file"})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid report ID"})
 		return
 	}
-	defer f.Close()
 
-	fileBytes := make([]byte, file.Size)
-	_, err = f.Read(fileBytes)
+	shares, err := c.reportShareService.List(reportID, userID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error reading file"})
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
 
-	input := services.UploadReportFileInput{
-		File:        fileBytes,
-		ContentType: contentType,
-		SizeBytes:   int(file.Size),
-		Filename:    file.Filename,
+	ctx.JSON(http.StatusOK, shares)
+}
+
+// @Summary Revoke report share link
+// @Description Revoke a share link so it can no longer be used
+// @Tags reports
+// @Security Bearer
+// @Param id path int true "Report ID"
+// @Param shareId path int true "Share ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /reports/{id}/shares/{shareId} [delete]
+func (c *ReportController) RevokeShare(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
 	}
 
-	reportFile, err := c.reportService.UploadReportFile(reportID, userID, input)
+	reportID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid report ID"})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, reportFile)
+	shareID, err := strconv.Atoi(ctx.Param("shareId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid share ID"})
+		return
+	}
+
+	if err := c.reportShareService.Revoke(reportID, shareID, userID); err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Access shared report
+// @Description Public access to a report through a share link token
+// @Tags shared
+// @Produce json
+// @Param token path string true "Share token"
+// @Param X-Share-Password header string false "Share password, when the link is protected"
+// @Success 200 {object} services.SharedReportResponse
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Router /shared/reports/{token} [get]
+func (c *ReportController) AccessShare(ctx *gin.Context) {
+	token := ctx.Param("token")
+	password := ctx.GetHeader("X-Share-Password")
+
+	response, err := c.reportShareService.Access(token, password, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrShareNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrShareExpired):
+			ctx.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSharePasswordRequired):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary Get report verification QR code
+// @Description Get a PNG QR code linking to the public verification page. The API does not change uploaded PDFs: the evaluator places the QR code in the document before uploading it
+// @Tags reports
+// @Produce png
+// @Security Bearer
+// @Param id path int true "Report ID"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /reports/{id}/verification-qr [get]
+func (c *ReportController) GetVerificationQRCode(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	reportID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid report ID"})
+		return
+	}
+
+	png, err := c.reportService.GetVerificationQRCode(reportID, userID)
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Data(http.StatusOK, "image/png", png)
+}
+
+// @Summary Verify report
+// @Description Public verification of a report: returns the evaluation summary and the expected SHA-256 of the report PDF
+// @Tags verify
+// @Produce json
+// @Param code path string true "Verification code"
+// @Success 200 {object} services.ReportVerification
+// @Failure 404 {object} map[string]interface{}
+// @Router /verify/{code} [get]
+func (c *ReportController) Verify(ctx *gin.Context) {
+	verification, err := c.reportService.Verify(ctx.Param("code"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verification)
 }
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"indicar-api/internal/application/services"
 	"net/http"
 	"strconv"
@@ -69,6 +70,10 @@ func (c *UserController) UpdateMe(ctx *gin.Context) {
 	}
 
 	user, err := c.userService.UpdateUser(userID, input)
+	if errors.Is(err, services.ErrInvalidDocument) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/internal/domain/entities"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCouponNotFound = errors.New("coupon not found")
+	ErrInvalidCoupon  = errors.New("coupon cannot be applied")
+	ErrCouponExists   = errors.New("a coupon with this code already exists")
+)
+
+// CouponService manages promotional codes and their redemptions
+type CouponService struct {
+	db *gorm.DB
+}
+
+func NewCouponService(db *gorm.DB) *CouponService {
+	return &CouponService{db: db}
+}
+
+type ListCouponsInput struct {
+	PageInput
+	Active *bool `form:"active"`
+	CityID *int  `form:"city_id"`
+}
+
+type CouponInput struct {
+	Code                  string     `json:"code" binding:"required,min=3,max=40,alphanum"`
+	Description           *string    `json:"description" binding:"omitempty,max=255"`
+	DiscountType          string     `json:"discount_type" binding:"required,oneof=percent fixed"`
+	DiscountValue         int        `json:"discount_value" binding:"required,min=1"`
+	MaxDiscountCents      *int       `json:"max_discount_cents" binding:"omitempty,min=1"`
+	Active                *bool      `json:"active"`
+	ValidFrom             *time.Time `json:"valid_from"`
+	ValidUntil            *time.Time `json:"valid_until"`
+	CityID                *int       `json:"city_id"`
+	FirstOrderOnly        bool       `json:"first_order_only"`
+	MaxRedemptions        *int       `json:"max_redemptions" binding:"omitempty,min=1"`
+	MaxRedemptionsPerUser *int       `json:"max_redemptions_per_user" binding:"omitempty,min=1"`
+}
+
+// Apply checks that a coupon can be used by a user for an evaluation in a
+// city and returns the discount it gives on subtotalCents. It is used for
+// quotes; Redeem checks the usage caps again, atomically, at checkout.
+func (s *CouponService) Apply(userID int, code string, cityID int, subtotalCents int) (*entities.Coupon, int, error) {
+	var coupon entities.Coupon
+	if err := s.db.Where("code = ?", normalizeCouponCode(code)).First(&coupon).Error; err != nil {
+		return nil, 0, fmt.Errorf("%w: unknown code", ErrInvalidCoupon)
+	}
+
+	if err := s.checkCoupon(s.db, &coupon, userID, cityID, 0); err != nil {
+		return nil, 0, err
+	}
+
+	return &coupon, couponDiscount(&coupon, subtotalCents), nil
+}
+
+// Redeem uses a coupon for an evaluation, inside the transaction creating it.
+// The coupon row is locked while its caps are checked, so concurrent
+// checkouts cannot go over them.
+func (s *CouponService) Redeem(tx *gorm.DB, userID int, evaluation *entities.Evaluation, couponID int, discountCents int) (*entities.CouponRedemption, error) {
+	var coupon entities.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, couponID).Error; err != nil {
+		return nil, fmt.Errorf("%w: unknown code", ErrInvalidCoupon)
+	}
+
+	if err := s.checkCoupon(tx, &coupon, userID, evaluation.CityID, evaluation.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&coupon).
+		Update("redemption_count", gorm.Expr("redemption_count + 1")).Error; err != nil {
+		return nil, err
+	}
+
+	redemption := &entities.CouponRedemption{
+		CouponID:      coupon.ID,
+		UserID:        userID,
+		EvaluationID:  evaluation.ID,
+		DiscountCents: discountCents,
+		Status:        entities.CouponRedemptionStatusRedeemed,
+	}
+	if err := tx.Create(redemption).Error; err != nil {
+		return nil, err
+	}
+
+	return redemption, nil
+}
+
+// AttachPayment ties the redemption of an evaluation to its payment
+func (s *CouponService) AttachPayment(tx *gorm.DB, evaluationID int, paymentID int) error {
+	return tx.Model(&entities.CouponRedemption{}).
+		Where("evaluation_id = ?", evaluationID).
+		Update("payment_id", paymentID).Error
+}
+
+// ReleaseForEvaluation gives back the coupon used by a canceled evaluation,
+// unless its payment was already captured. Fully discounted evaluations,
+// recorded as paid without charging anything, always give it back.
+func (s *CouponService) ReleaseForEvaluation(tx *gorm.DB, evaluationID int) error {
+	var redemption entities.CouponRedemption
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("evaluation_id = ? AND status = ?", evaluationID, entities.CouponRedemptionStatusRedeemed).
+		First(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var captured int64
+	if err := tx.Model(&entities.Payment{}).
+		Where("evaluation_id = ? AND amount_cents > 0 AND status IN ?", evaluationID, []entities.PaymentStatus{
+			entities.PaymentStatusPaid,
+			entities.PaymentStatusPartiallyRefunded,
+			entities.PaymentStatusRefunded,
+			entities.PaymentStatusDisputed,
+			entities.PaymentStatusChargedBack,
+		}).
+		Count(&captured).Error; err != nil {
+		return err
+	}
+	if captured > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if err := tx.Model(&redemption).Updates(map[string]interface{}{
+		"status":      entities.CouponRedemptionStatusReleased,
+		"released_at": now,
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&entities.Coupon{}).
+		Where("id = ? AND redemption_count > 0", redemption.CouponID).
+		Update("redemption_count", gorm.Expr("redemption_count - 1")).Error
+}
+
+// List returns the coupons, most recent first (admin only)
+func (s *CouponService) List(userID int, input ListCouponsInput) (*Page[entities.Coupon], error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	page := input.PageInput.normalized()
+	query := s.db.Model(&entities.Coupon{})
+	if input.Active != nil {
+		query = query.Where("active = ?", *input.Active)
+	}
+	if input.CityID != nil {
+		query = query.Where("city_id = ?", *input.CityID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	coupons := make([]entities.Coupon, 0)
+	if err := query.Order("id DESC").
+		Offset(page.offset()).
+		Limit(page.PageSize).
+		Find(&coupons).Error; err != nil {
+		return nil, err
+	}
+
+	return &Page[entities.Coupon]{
+		Items:    coupons,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		Total:    total,
+	}, nil
+}
+
+// Create adds a coupon (admin only). Codes are stored in upper case.
+func (s *CouponService) Create(userID int, input CouponInput) (*entities.Coupon, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	coupon := &entities.Coupon{}
+	if err := s.fillCoupon(coupon, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCodeAvailable(coupon.Code, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(coupon).Error; err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// Update replaces a coupon (admin only). Redemptions already made are kept,
+// even if they would not be allowed by the new restrictions.
+func (s *CouponService) Update(userID int, couponID int, input CouponInput) (*entities.Coupon, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	var coupon entities.Coupon
+	if err := s.db.First(&coupon, couponID).Error; err != nil {
+		return nil, ErrCouponNotFound
+	}
+
+	if err := s.fillCoupon(&coupon, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCodeAvailable(coupon.Code, coupon.ID); err != nil {
+		return nil, err
+	}
+
+	// The redemption count is only changed by redemptions
+	if err := s.db.Select("*").
+		Omit("redemption_count", "created_at", "updated_at").
+		Updates(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// ListRedemptions returns the uses of a coupon, most recent first (admin only)
+func (s *CouponService) ListRedemptions(userID int, couponID int, input PageInput) (*Page[entities.CouponRedemption], error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	page := input.normalized()
+	query := s.db.Model(&entities.CouponRedemption{}).Where("coupon_id = ?", couponID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	redemptions := make([]entities.CouponRedemption, 0)
+	if err := query.Order("id DESC").
+		Offset(page.offset()).
+		Limit(page.PageSize).
+		Find(&redemptions).Error; err != nil {
+		return nil, err
+	}
+
+	return &Page[entities.CouponRedemption]{
+		Items:    redemptions,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		Total:    total,
+	}, nil
+}
+
+// checkCoupon applies a coupon's restrictions. evaluationID is the evaluation
+// being created, which does not count as a previous order.
+func (s *CouponService) checkCoupon(db *gorm.DB, coupon *entities.Coupon, userID int, cityID int, evaluationID int) error {
+	now := time.Now()
+	switch {
+	case !coupon.Active:
+		return fmt.Errorf("%w: coupon is not active", ErrInvalidCoupon)
+	case coupon.ValidFrom != nil && now.Before(*coupon.ValidFrom):
+		return fmt.Errorf("%w: coupon is not valid yet", ErrInvalidCoupon)
+	case coupon.ValidUntil != nil && now.After(*coupon.ValidUntil):
+		return fmt.Errorf("%w: coupon has expired", ErrInvalidCoupon)
+	case coupon.CityID != nil && *coupon.CityID != cityID:
+		return fmt.Errorf("%w: coupon is not valid in this city", ErrInvalidCoupon)
+	case coupon.MaxRedemptions != nil && coupon.RedemptionCount >= *coupon.MaxRedemptions:
+		return fmt.Errorf("%w: coupon usage limit reached", ErrInvalidCoupon)
+	}
+
+	if coupon.MaxRedemptionsPerUser != nil {
+		var used int64
+		if err := db.Model(&entities.CouponRedemption{}).
+			Where("coupon_id = ? AND user_id = ? AND status = ?", coupon.ID, userID, entities.CouponRedemptionStatusRedeemed).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(*coupon.MaxRedemptionsPerUser) {
+			return fmt.Errorf("%w: coupon usage limit reached for this user", ErrInvalidCoupon)
+		}
+	}
+
+	if coupon.FirstOrderOnly {
+		var previous int64
+		if err := db.Model(&entities.Evaluation{}).
+			Where("requester_id = ? AND id <> ? AND status <> ?", userID, evaluationID, entities.EvaluationStatusCanceled).
+			Count(&previous).Error; err != nil {
+			return err
+		}
+		if previous > 0 {
+			return fmt.Errorf("%w: coupon is only valid for the first evaluation", ErrInvalidCoupon)
+		}
+	}
+
+	return nil
+}
+
+func (s *CouponService) checkCodeAvailable(code string, couponID int) error {
+	var count int64
+	if err := s.db.Model(&entities.Coupon{}).
+		Where("code = ? AND id <> ?", code, couponID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCouponExists
+	}
+	return nil
+}
+
+// fillCoupon validates input and copies it into coupon
+func (s *CouponService) fillCoupon(coupon *entities.Coupon, input CouponInput) error {
+	discountType := entities.CouponDiscountType(input.DiscountType)
+
+	switch {
+	case discountType == entities.CouponDiscountTypePercent && input.DiscountValue > 100:
+		return fmt.Errorf("%w: percent discounts cannot exceed 100", ErrInvalidCoupon)
+	case discountType == entities.CouponDiscountTypeFixed && input.MaxDiscountCents != nil:
+		return fmt.Errorf("%w: max_discount_cents only applies to percent discounts", ErrInvalidCoupon)
+	case input.ValidFrom != nil && input.ValidUntil != nil && !input.ValidFrom.Before(*input.ValidUntil):
+		return fmt.Errorf("%w: valid_from must be before valid_until", ErrInvalidCoupon)
+	}
+
+	if input.CityID != nil {
+		var city entities.City
+		if err := s.db.Select("id").First(&city, *input.CityID).Error; err != nil {
+			return fmt.Errorf("%w: city not found", ErrInvalidCoupon)
+		}
+	}
+
+	coupon.Code = normalizeCouponCode(input.Code)
+	coupon.Description = input.Description
+	coupon.DiscountType = discountType
+	coupon.DiscountValue = input.DiscountValue
+	coupon.MaxDiscountCents = input.MaxDiscountCents
+	coupon.Active = input.Active == nil || *input.Active
+	coupon.ValidFrom = input.ValidFrom
+	coupon.ValidUntil = input.ValidUntil
+	coupon.CityID = input.CityID
+	coupon.FirstOrderOnly = input.FirstOrderOnly
+	coupon.MaxRedemptions = input.MaxRedemptions
+	coupon.MaxRedemptionsPerUser = input.MaxRedemptionsPerUser
+
+	return nil
+}
+
+// couponDiscount computes the discount on a subtotal, which it never exceeds
+func couponDiscount(coupon *entities.Coupon, subtotalCents int) int {
+	discount := coupon.DiscountValue
+	if coupon.DiscountType == entities.CouponDiscountTypePercent {
+		discount = int(math.Round(float64(subtotalCents) * float64(coupon.DiscountValue) / 100))
+		if coupon.MaxDiscountCents != nil {
+			discount = min(discount, *coupon.MaxDiscountCents)
+		}
+	}
+	return min(discount, max(subtotalCents, 0))
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"indicar-api/internal/domain/entities"
+	"testing"
+	"time"
+)
+
+func TestCouponDiscount(t *testing.T) {
+	maxDiscount := 3000
+	tests := []struct {
+		name     string
+		coupon   entities.Coupon
+		subtotal int
+		want     int
+	}{
+		{"percent", entities.Coupon{DiscountType: entities.CouponDiscountTypePercent, DiscountValue: 10}, 19900, 1990},
+		{"percent rounded", entities.Coupon{DiscountType: entities.CouponDiscountTypePercent, DiscountValue: 15}, 19990, 2999},
+		{"percent capped", entities.Coupon{DiscountType: entities.CouponDiscountTypePercent, DiscountValue: 50, MaxDiscountCents: &maxDiscount}, 19900, 3000},
+		{"fixed", entities.Coupon{DiscountType: entities.CouponDiscountTypeFixed, DiscountValue: 5000}, 19900, 5000},
+		{"fixed above the subtotal", entities.Coupon{DiscountType: entities.CouponDiscountTypeFixed, DiscountValue: 5000}, 4000, 4000},
+		{"negative subtotal", entities.Coupon{DiscountType: entities.CouponDiscountTypeFixed, DiscountValue: 5000}, -100, 0},
+	}
+	for _, test := range tests {
+		if got := couponDiscount(&test.coupon, test.subtotal); got != test.want {
+			t.Errorf("%s: couponDiscount = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckCoupon(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	cityID, otherCityID := 1, 2
+	limit := 10
+
+	tests := []struct {
+		name   string
+		coupon entities.Coupon
+		valid  bool
+	}{
+		{"active", entities.Coupon{Active: true, ValidFrom: &past, ValidUntil: &future, CityID: &cityID}, true},
+		{"inactive", entities.Coupon{Active: false}, false},
+		{"not valid yet", entities.Coupon{Active: true, ValidFrom: &future}, false},
+		{"expired", entities.Coupon{Active: true, ValidUntil: &past}, false},
+		{"other city", entities.Coupon{Active: true, CityID: &otherCityID}, false},
+		{"below the limit", entities.Coupon{Active: true, MaxRedemptions: &limit, RedemptionCount: 9}, true},
+		{"limit reached", entities.Coupon{Active: true, MaxRedemptions: &limit, RedemptionCount: 10}, false},
+	}
+
+	// Coupons without per-user or first-order restrictions are checked
+	// without the database
+	service := &CouponService{}
+	for _, test := range tests {
+		err := service.checkCoupon(nil, &test.coupon, 1, cityID, 1)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidCoupon) {
+			t.Errorf("%s: err = %v, want ErrInvalidCoupon", test.name, err)
+		}
+	}
+}
+
+func TestNormalizeCouponCode(t *testing.T) {
+	if got := normalizeCouponCode("  bemvindo10 "); got != "BEMVINDO10" {
+		t.Errorf("normalizeCouponCode = %q, want BEMVINDO10", got)
+	}
+}
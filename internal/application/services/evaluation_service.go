@@ -1,34 +1,83 @@
 package services
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"indicar-api/configs"
 	"indicar-api/internal/domain/entities"
-	"indicar-api/internal/infrastructure/aws"
+	"indicar-api/internal/infrastructure/media"
+	"indicar-api/internal/infrastructure/storage"
+	"io"
+	"log"
+	"math"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EvaluationService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	paymentService *PaymentService
+	pricingService *PricingService
+	couponService  *CouponService
+	ledgerService  *LedgerService
 }
 
-func NewEvaluationService(db *gorm.DB) *EvaluationService {
+func NewEvaluationService(db *gorm.DB, paymentService *PaymentService, pricingService *PricingService, couponService *CouponService, ledgerService *LedgerService) *EvaluationService {
 	return &EvaluationService{
-		db: db,
+		db:             db,
+		paymentService: paymentService,
+		pricingService: pricingService,
+		couponService:  couponService,
+		ledgerService:  ledgerService,
 	}
 }
 
 type CreateEvaluationInput struct {
-	CityID       int     `json:"city_id" binding:"required"`
-	VehicleMake  string  `json:"vehicle_make" binding:"required"`
-	VehicleModel string  `json:"vehicle_model" binding:"required"`
-	VehicleYear  *int    `json:"vehicle_year"`
-	VehiclePlate *string `json:"vehicle_plate"`
-	Notes        *string `json:"notes"`
+	CityID         int     `json:"city_id" binding:"required"`
+	InspectionType string  `json:"inspection_type" binding:"omitempty,oneof=standard complete precautionary"`
+	PaymentMethod  string  `json:"payment_method" binding:"omitempty,oneof=card pix"`
+	VehicleMake    string  `json:"vehicle_make" binding:"required"`
+	VehicleModel   string  `json:"vehicle_model" binding:"required"`
+	VehicleYear    *int    `json:"vehicle_year"`
+	VehiclePlate   *string `json:"vehicle_plate"`
+	Notes          *string `json:"notes"`
+
+	// Pricing conditions, see PriceQuoteInput
+	VehicleCategory string     `json:"vehicle_category" binding:"omitempty,oneof=car motorcycle pickup suv van truck"`
+	Urgent          bool       `json:"urgent"`
+	ScheduledAt     *time.Time `json:"scheduled_at"`
+	CouponCode      string     `json:"coupon_code" binding:"omitempty,max=40"`
+}
+
+type ReportSummary struct {
+	ID                int                   `json:"id"`
+	Status            entities.ReportStatus `json:"status"`
+	Summary           *string               `json:"summary,omitempty"`
+	VerificationCode  *string               `json:"verification_code,omitempty"`
+	LatestFileVersion *int                  `json:"latest_file_version,omitempty"`
+	UpdatedAt         time.Time             `json:"updated_at"`
 }
 
+// EvaluationDetails is an evaluation with the related resources requested
+// through the include query parameter.
+type EvaluationDetails struct {
+	*entities.Evaluation
+	Report *ReportSummary             `json:"report,omitempty"`
+	Photos []entities.EvaluationPhoto `json:"photos,omitempty"`
+}
+
+var (
+	ErrEvaluationNotFound      = errors.New("evaluation not found")
+	ErrEvaluationAccessDenied  = errors.New("unauthorized: this user cannot make this change to the evaluation")
+	ErrEvaluationDetailsDenied = errors.New("unauthorized: only the requester, the assigned evaluator or an admin can see the evaluation's report and photos")
+)
+
 type UpdateEvaluationInput struct {
 	EvaluatorID *int    `json:"evaluator_id"`
 	Status      *string `json:"status"`
@@ -44,16 +93,86 @@ func (s *EvaluationService) Create(userID int, input CreateEvaluationInput) (*en
 		VehicleYear:  input.VehicleYear,
 		VehiclePlate: input.VehiclePlate,
 		Notes:        input.Notes,
+		Urgent:       input.Urgent,
+		ScheduledAt:  input.ScheduledAt,
 		Status:       entities.EvaluationStatusCreated,
 	}
 
-	if err := s.db.Create(evaluation).Error; err != nil {
+	evaluation.InspectionType = entities.InspectionTypeStandard
+	if input.InspectionType != "" {
+		evaluation.InspectionType = entities.InspectionType(input.InspectionType)
+	}
+
+	evaluation.VehicleCategory = entities.VehicleCategoryCar
+	if input.VehicleCategory != "" {
+		evaluation.VehicleCategory = entities.VehicleCategory(input.VehicleCategory)
+	}
+
+	quote, err := s.pricingService.Quote(userID, PriceQuoteInput{
+		CityID:          input.CityID,
+		VehicleCategory: input.VehicleCategory,
+		VehicleYear:     input.VehicleYear,
+		Urgent:          input.Urgent,
+		ScheduledAt:     input.ScheduledAt,
+		CouponCode:      input.CouponCode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	evaluation.PriceCents = &quote.TotalCents
+	evaluation.PriceQuote = quote
+
+	// The evaluation is only created along with its coupon redemption
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(evaluation).Error; err != nil {
+			return err
+		}
+
+		for _, adjustment := range quote.Adjustments {
+			if adjustment.CouponID == nil {
+				continue
+			}
+			if _, err := s.couponService.Redeem(tx, userID, evaluation, *adjustment.CouponID, -adjustment.AmountCents); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider is called once the coupon lock is released. An evaluation
+	// whose charge cannot be created is removed, giving back its coupon.
+	method := paymentMethodOrDefault(input.PaymentMethod, entities.PaymentMethodCard)
+	record, err := s.paymentService.CreateForEvaluation(evaluation, method)
+	if err != nil {
+		if discardErr := s.discard(evaluation.ID); discardErr != nil {
+			log.Printf("failed to discard evaluation %d without a charge: %v", evaluation.ID, discardErr)
+		}
 		return nil, err
 	}
 
+	if quote.CouponCode != nil {
+		// The evaluation is already charged: a failure here only loses the link
+		if err := s.couponService.AttachPayment(s.db, evaluation.ID, record.ID); err != nil {
+			log.Printf("failed to attach payment %d to the coupon of evaluation %d: %v", record.ID, evaluation.ID, err)
+		}
+	}
+
 	return evaluation, nil
 }
 
+// discard deletes an evaluation that could not be charged
+func (s *EvaluationService) discard(evaluationID int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.couponService.ReleaseForEvaluation(tx, evaluationID); err != nil {
+			return err
+		}
+		return tx.Delete(&entities.Evaluation{}, evaluationID).Error
+	})
+}
+
 func (s *EvaluationService) GetByID(id int) (*entities.Evaluation, error) {
 	var evaluation entities.Evaluation
 	if err := s.db.First(&evaluation, id).Error; err != nil {
@@ -62,6 +181,51 @@ func (s *EvaluationService) GetByID(id int) (*entities.Evaluation, error) {
 	return &evaluation, nil
 }
 
+// CheckDetailsAccess tells whether the user can see the report and photos of
+// an evaluation: its requester, its assigned evaluator or an admin.
+func (s *EvaluationService) CheckDetailsAccess(evaluation *entities.Evaluation, userID int) error {
+	if evaluation.RequesterID == userID ||
+		(evaluation.EvaluatorID != nil && *evaluation.EvaluatorID == userID) ||
+		isAdmin(s.db, userID) {
+		return nil
+	}
+	return ErrEvaluationDetailsDenied
+}
+
+// GetReportSummary returns a summary of the evaluation's report, or nil when no
+// report was created yet.
+func (s *EvaluationService) GetReportSummary(evaluationID int) (*ReportSummary, error) {
+	var reports []entities.Report
+	if err := s.db.Where("evaluation_id = ?", evaluationID).Limit(1).Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	report := reports[0]
+
+	summary := &ReportSummary{
+		ID:               report.ID,
+		Status:           report.Status,
+		Summary:          report.Summary,
+		VerificationCode: report.VerificationCode,
+		UpdatedAt:        report.UpdatedAt,
+	}
+
+	var latestVersion int
+	if err := s.db.Model(&entities.ReportFile{}).
+		Where("report_id = ?", report.ID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latestVersion).Error; err != nil {
+		return nil, err
+	}
+	if latestVersion > 0 {
+		summary.LatestFileVersion = &latestVersion
+	}
+
+	return summary, nil
+}
+
 func (s *EvaluationService) List(status string) ([]entities.Evaluation, error) {
 	var evaluations []entities.Evaluation
 	query := s.db.Order("created_at DESC")
@@ -77,13 +241,29 @@ func (s *EvaluationService) List(status string) ([]entities.Evaluation, error) {
 	return evaluations, nil
 }
 
-func (s *EvaluationService) Update(id int, input UpdateEvaluationInput) (*entities.Evaluation, error) {
+// Update assigns, moves or annotates an evaluation. Evaluators can only
+// assign themselves, and only the assigned evaluator or an admin can start
+// and complete it; the requester can also cancel it and edit its notes.
+func (s *EvaluationService) Update(id int, userID int, input UpdateEvaluationInput) (*entities.Evaluation, error) {
 	evaluation, err := s.GetByID(id)
 	if err != nil {
-		return nil, err
+		return nil, ErrEvaluationNotFound
 	}
 
+	var user entities.User
+	if err := s.db.Select("id", "role").First(&user, userID).Error; err != nil {
+		return nil, ErrEvaluationAccessDenied
+	}
+	admin := user.Role == entities.UserRoleAdmin
+	requester := evaluation.RequesterID == userID
+
+	previousStatus := evaluation.Status
+
 	if input.EvaluatorID != nil {
+		selfAssigned := *input.EvaluatorID == userID && user.Role == entities.UserRoleEvaluator
+		if !admin && !selfAssigned {
+			return nil, ErrEvaluationAccessDenied
+		}
 		if evaluation.Status != entities.EvaluationStatusCreated {
 			return nil, errors.New("evaluator can only be assigned to evaluations in 'created' status")
 		}
@@ -91,8 +271,15 @@ func (s *EvaluationService) Update(id int, input UpdateEvaluationInput) (*entiti
 		evaluation.Status = entities.EvaluationStatusAccepted
 	}
 
+	assigned := evaluation.EvaluatorID != nil && *evaluation.EvaluatorID == userID
+
 	if input.Status != nil {
 		newStatus := entities.EvaluationStatus(*input.Status)
+		allowed := admin || (assigned && newStatus != entities.EvaluationStatusAccepted) ||
+			(requester && newStatus == entities.EvaluationStatusCanceled)
+		if !allowed {
+			return nil, ErrEvaluationAccessDenied
+		}
 		if !isValidStatusTransition(evaluation.Status, newStatus) {
 			return nil, errors.New("invalid status transition")
 		}
@@ -100,13 +287,40 @@ func (s *EvaluationService) Update(id int, input UpdateEvaluationInput) (*entiti
 	}
 
 	if input.Notes != nil {
+		if !admin && !assigned && !requester {
+			return nil, ErrEvaluationAccessDenied
+		}
 		evaluation.Notes = input.Notes
 	}
 
-	if err := s.db.Save(evaluation).Error; err != nil {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(evaluation).Error; err != nil {
+			return err
+		}
+
+		switch evaluation.Status {
+		case entities.EvaluationStatusCanceled:
+			// Coupons of evaluations canceled before payment can be used again
+			return s.couponService.ReleaseForEvaluation(tx, evaluation.ID)
+		case entities.EvaluationStatusCompleted:
+			return s.ledgerService.RecordEarning(tx, evaluation)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	// Paid evaluations are refunded by the cancellation policy. The
+	// cancellation stands if the refund fails: it is kept as failed, for an
+	// admin to refund by hand.
+	if evaluation.Status == entities.EvaluationStatusCanceled && previousStatus != entities.EvaluationStatusCanceled {
+		started := previousStatus == entities.EvaluationStatusInProgress
+		if _, err := s.paymentService.RefundForCancellation(evaluation.ID, started); err != nil {
+			log.Printf("failed to refund canceled evaluation %d: %v", evaluation.ID, err)
+		}
+	}
+
 	return evaluation, nil
 }
 
@@ -125,93 +339,996 @@ func isValidStatusTransition(current, new entities.EvaluationStatus) bool {
 	}
 }
 
-type EvaluationPhotoService struct {
-	db        *gorm.DB
-	s3Service *aws.S3Service
+const (
+	maxPhotoSize         = 10 * 1024 * 1024 // 10MB
+	photoUploadURLExpiry = 15 * time.Minute
+	photoURLExpiry       = time.Hour
+
+	// Photos taken further than this from the evaluation's city get a warning
+	maxCaptureDistanceKm = 50.0
+
+	// Photos of other evaluations whose perceptual hashes differ by at most
+	// this many bits are flagged as reused
+	maxSimilarPhotoDistance = 6
+	// Similar hashes share at least one of this many bands
+	photoHashBandCount = maxSimilarPhotoDistance + 1
+)
+
+// photoExtensions maps the accepted photo content types to file extensions
+var photoExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/jpg":  "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+	"image/heic": "heic",
 }
 
-func NewEvaluationPhotoService(db *gorm.DB) (*EvaluationPhotoService, error) {
-	s3Service, err := aws.NewS3Service()
-	if err != nil {
-		return nil, err
-	}
+var (
+	ErrPhotoNotFound       = errors.New("photo not found")
+	ErrDuplicatePhoto      = errors.New("this photo was already uploaded to the evaluation")
+	ErrEvaluationCompleted = errors.New("evaluation is completed: its photos can no longer be changed")
+	ErrPhotoAccessDenied   = errors.New("unauthorized: only the requester, the assigned evaluator or an admin can change the evaluation's photos")
+)
 
+type EvaluationPhotoService struct {
+	db       *gorm.DB
+	store    storage.BlobStore
+	variants *PhotoVariantService
+}
+
+func NewEvaluationPhotoService(db *gorm.DB, store storage.BlobStore, variants *PhotoVariantService) *EvaluationPhotoService {
 	return &EvaluationPhotoService{
-		db:        db,
-		s3Service: s3Service,
-	}, nil
+		db:       db,
+		store:    store,
+		variants: variants,
+	}
 }
 
 type UploadPhotoInput struct {
-	File        []byte
+	File        io.Reader
 	ContentType string
-	SizeBytes   int
+	Category    string
+	Caption     *string
+}
+
+// UploadPhotoQuery holds the fields sent in the query string of multipart uploads
+type UploadPhotoQuery struct {
+	Category string `form:"category"`
+	Caption  string `form:"caption" binding:"omitempty,max=255"`
+}
+
+type CreatePhotoUploadInput struct {
+	ContentType string `json:"content_type" binding:"required"`
+	SizeBytes   int64  `json:"size_bytes" binding:"required,min=1"`
+}
+
+type PhotoUploadURL struct {
+	Key       string            `json:"key"`
+	URL       string            `json:"url"`
+	Fields    map[string]string `json:"fields"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type ConfirmPhotoUploadInput struct {
+	Key      string  `json:"key" binding:"required"`
+	Category string  `json:"category"`
+	Caption  *string `json:"caption" binding:"omitempty,max=255"`
+}
+
+type UpdatePhotoInput struct {
+	Category *string `json:"category"`
+	Caption  *string `json:"caption" binding:"omitempty,max=255"`
+}
+
+type ReorderPhotosInput struct {
+	// Every photo of the evaluation, in the new order
+	PhotoIDs []int `json:"photo_ids" binding:"required,min=1"`
+}
+
+// PhotoCompleteness tells which of the photos required by the evaluation's
+// inspection type were already taken
+type PhotoCompleteness struct {
+	InspectionType entities.InspectionType  `json:"inspection_type"`
+	Required       []entities.PhotoCategory `json:"required"`
+	Missing        []entities.PhotoCategory `json:"missing"`
+	Complete       bool                     `json:"complete"`
+}
+
+var photoCategories = map[entities.PhotoCategory]bool{
+	entities.PhotoCategoryFront:         true,
+	entities.PhotoCategoryRear:          true,
+	entities.PhotoCategoryLeft:          true,
+	entities.PhotoCategoryRight:         true,
+	entities.PhotoCategoryEngine:        true,
+	entities.PhotoCategoryOdometer:      true,
+	entities.PhotoCategoryChassisNumber: true,
+	entities.PhotoCategoryInterior:      true,
+	entities.PhotoCategoryDamageDetail:  true,
 }
 
 func (s *EvaluationPhotoService) UploadPhoto(evaluationID int, input UploadPhotoInput) (*entities.EvaluationPhoto, error) {
+	evaluation, err := s.getEditableEvaluation(evaluationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Photos are small enough to be processed in memory: the metadata is
+	// stripped before anything is written to storage
+	data, err := io.ReadAll(storage.LimitReader(input.File, maxPhotoSize))
+	if err != nil {
+		if errors.Is(err, storage.ErrTooLarge) {
+			return nil, fmt.Errorf("file too large: %w", storage.ErrTooLarge)
+		}
+		return nil, err
+	}
+
+	category, err := parsePhotoCategory(input.Category)
+	if err != nil {
+		return nil, err
+	}
+
+	photo, content, err := s.preparePhoto(evaluation, data, input.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	photo.Category = category
+	photo.Caption = input.Caption
+
+	if err := s.checkDuplicate(photo, 0); err != nil {
+		return nil, err
+	}
+
+	s3Key := newPhotoKey(evaluationID, photoExtensions[*photo.ContentType])
+	photo.S3Key = s3Key
+
+	if err := s.store.Put(s3Key, bytes.NewReader(content), *photo.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if err := s.createPhoto(photo); err != nil {
+		// If database creation fails, clean up the stored file
+		s.store.Delete(s3Key)
+		return nil, err
+	}
+
+	s.variants.Enqueue(photo.ID)
+	s.flagReusedPhoto(photo)
+
+	return photo, nil
+}
+
+// CreateUploadURL returns a pre-signed POST the client uses to upload a photo
+// straight to storage. The upload is registered by ConfirmUpload.
+func (s *EvaluationPhotoService) CreateUploadURL(evaluationID int, input CreatePhotoUploadInput) (*PhotoUploadURL, error) {
+	presigner, ok := s.store.(storage.PostPresigner)
+	if !ok {
+		return nil, errors.New("direct uploads are not supported by the configured storage")
+	}
+
+	if _, err := s.getEditableEvaluation(evaluationID); err != nil {
+		return nil, err
+	}
+
+	ext, ok := photoExtensions[input.ContentType]
+	if !ok {
+		return nil, errors.New("only image files are allowed (JPEG, PNG, GIF, WebP, HEIC)")
+	}
+
+	if err := storage.ValidateFileSize(input.SizeBytes, maxPhotoSize); err != nil {
+		return nil, fmt.Errorf("file too large: %w", err)
+	}
+
+	s3Key := newPhotoKey(evaluationID, ext)
+
+	post, err := presigner.PresignPost(s3Key, input.ContentType, maxPhotoSize, photoUploadURLExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
+	}
+
+	return &PhotoUploadURL{
+		Key:       s3Key,
+		URL:       post.URL,
+		Fields:    post.Fields,
+		ExpiresAt: time.Now().Add(photoUploadURLExpiry),
+	}, nil
+}
+
+// ConfirmUpload registers a photo uploaded through CreateUploadURL once the
+// object is found in storage and passes validation. Invalid objects are deleted.
+func (s *EvaluationPhotoService) ConfirmUpload(evaluationID int, input ConfirmPhotoUploadInput) (*entities.EvaluationPhoto, error) {
 	var evaluation entities.Evaluation
 	if err := s.db.First(&evaluation, evaluationID).Error; err != nil {
 		return nil, err
 	}
 
-	// Validate file type (only images allowed)
-	allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp"}
-	if err := s.s3Service.ValidateFileType("photo.jpg", allowedTypes); err != nil {
-		return nil, fmt.Errorf("invalid file type: %w", err)
+	category, err := parsePhotoCategory(input.Category)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("evaluations/%d/photos/", evaluationID)
+	if !strings.HasPrefix(input.Key, prefix) || strings.Contains(input.Key, "..") {
+		return nil, errors.New("key does not belong to this evaluation")
+	}
+
+	// Confirming twice returns the photo registered the first time
+	var existing entities.EvaluationPhoto
+	if err := s.db.Where("s3_bucket = ? AND s3_key = ?", s.store.Bucket(), input.Key).First(&existing).Error; err == nil {
+		return &existing, nil
+	}
+
+	if evaluation.Status == entities.EvaluationStatusCompleted {
+		s.store.Delete(input.Key)
+		return nil, ErrEvaluationCompleted
+	}
+
+	info, err := s.store.Head(input.Key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, errors.New("uploaded file not found")
+		}
+		return nil, err
 	}
 
-	// Validate file size (max 10MB for photos)
-	maxSize := int64(10 * 1024 * 1024) // 10MB
-	if err := s.s3Service.ValidateFileSize(int64(input.SizeBytes), maxSize); err != nil {
+	if err := storage.ValidateFileSize(info.Size, maxPhotoSize); err != nil {
+		s.store.Delete(input.Key)
 		return nil, fmt.Errorf("file too large: %w", err)
 	}
 
-	// Generate appropriate file extension based on content type
-	var ext string
-	switch input.ContentType {
-	case "image/jpeg", "image/jpg":
-		ext = "jpg"
-	case "image/png":
-		ext = "png"
-	case "image/gif":
-		ext = "gif"
-	case "image/webp":
-		ext = "webp"
-	default:
-		ext = "jpg" // default fallback
+	data, err := s.readStoredPhoto(input.Key)
+	if err != nil {
+		return nil, err
 	}
 
-	s3Key := fmt.Sprintf("evaluations/%d/photos/%d.%s", evaluationID, time.Now().UnixNano(), ext)
+	photo, content, err := s.preparePhoto(&evaluation, data, info.ContentType)
+	if err != nil {
+		s.store.Delete(input.Key)
+		return nil, err
+	}
+	photo.S3Key = input.Key
+	photo.Category = category
+	photo.Caption = input.Caption
 
-	if err := s.s3Service.UploadFile(s3Key, input.File, input.ContentType); err != nil {
-		return nil, fmt.Errorf("failed to upload file to S3: %w", err)
+	if err := s.checkDuplicate(photo, 0); err != nil {
+		s.store.Delete(input.Key)
+		return nil, err
 	}
 
-	photo := &entities.EvaluationPhoto{
-		EvaluationID: evaluationID,
-		S3Bucket:     s.s3Service.Bucket,
-		S3Key:        s3Key,
-		ContentType:  &input.ContentType,
-		SizeBytes:    &input.SizeBytes,
+	// The client uploaded the original: replace it with the stripped copy
+	if !bytes.Equal(content, data) {
+		if err := s.store.Put(input.Key, bytes.NewReader(content), *photo.ContentType); err != nil {
+			return nil, fmt.Errorf("failed to store stripped photo: %w", err)
+		}
 	}
 
-	if err := s.db.Create(photo).Error; err != nil {
-		// If database creation fails, clean up S3 file
-		s.s3Service.DeleteFile(s3Key)
+	if err := s.createPhoto(photo); err != nil {
 		return nil, err
 	}
 
+	s.variants.Enqueue(photo.ID)
+	s.flagReusedPhoto(photo)
+
 	return photo, nil
 }
 
+func (s *EvaluationPhotoService) readStoredPhoto(key string) ([]byte, error) {
+	object, _, err := s.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(storage.LimitReader(object, maxPhotoSize))
+}
+
+// preparePhoto validates a photo from its content, strips its metadata and
+// builds its record from what the metadata contained. It returns the content
+// to store.
+func (s *EvaluationPhotoService) preparePhoto(evaluation *entities.Evaluation, data []byte, declaredType string) (*entities.EvaluationPhoto, []byte, error) {
+	imageInfo, err := media.InspectImage(data, declaredType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sanitized, err := media.Sanitize(data, imageInfo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", media.ErrInvalidFile, err)
+	}
+
+	digest := sha256.Sum256(sanitized.Data)
+	checksum := hex.EncodeToString(digest[:])
+
+	sizeBytes := len(sanitized.Data)
+	photo := &entities.EvaluationPhoto{
+		EvaluationID:   evaluation.ID,
+		S3Bucket:       s.store.Bucket(),
+		ContentType:    &sanitized.ContentType,
+		SizeBytes:      &sizeBytes,
+		Width:          &sanitized.Width,
+		Height:         &sanitized.Height,
+		SHA256:         &checksum,
+		CapturedAt:     sanitized.Metadata.CapturedAt,
+		Latitude:       sanitized.Metadata.Latitude,
+		Longitude:      sanitized.Metadata.Longitude,
+		VariantsStatus: entities.PhotoVariantsStatusPending,
+	}
+
+	if sanitized.Metadata.Orientation > 1 {
+		photo.Orientation = &sanitized.Metadata.Orientation
+	}
+
+	// HEIC photos cannot be decoded and are only matched by their checksum
+	if img, err := media.Decode(bytes.NewReader(sanitized.Data)); err == nil {
+		hash := media.PerceptualHash(img)
+		photo.PerceptualHash = &hash
+	}
+
+	if photo.Latitude != nil && photo.Longitude != nil {
+		var city entities.City
+		if err := s.db.First(&city, evaluation.CityID).Error; err == nil && city.Latitude != nil && city.Longitude != nil {
+			distance := math.Round(distanceKm(*city.Latitude, *city.Longitude, *photo.Latitude, *photo.Longitude)*100) / 100
+			photo.CaptureDistanceKm = &distance
+		}
+	}
+	setPhotoWarnings(photo)
+
+	return photo, sanitized.Data, nil
+}
+
+// createPhoto stores a new photo at the end of the gallery along with the
+// bands of its perceptual hash. The evaluation is locked so that concurrent
+// uploads get distinct positions and none lands after it is completed, and a
+// photo identical to another of the evaluation uploaded meanwhile is rejected
+// by the unique index.
+func (s *EvaluationPhotoService) createPhoto(photo *entities.EvaluationPhoto) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockEditableEvaluation(tx, photo.EvaluationID); err != nil {
+			return err
+		}
+
+		var lastPosition int
+		if err := tx.Model(&entities.EvaluationPhoto{}).
+			Where("evaluation_id = ?", photo.EvaluationID).
+			Select("COALESCE(MAX(position), 0)").
+			Scan(&lastPosition).Error; err != nil {
+			return err
+		}
+		photo.Position = lastPosition + 1
+
+		if err := tx.Create(photo).Error; err != nil {
+			return err
+		}
+		return replaceHashBands(tx, photo)
+	})
+	if err != nil && isDuplicateKey(s.db, err) {
+		if duplicateErr := s.checkDuplicate(photo, 0); duplicateErr != nil {
+			return duplicateErr
+		}
+	}
+	return err
+}
+
+// lockEditableEvaluation locks the row of an evaluation until the end of the
+// transaction, serializing changes to its photos, and checks it is not
+// completed
+func lockEditableEvaluation(tx *gorm.DB, evaluationID int) (*entities.Evaluation, error) {
+	var evaluation entities.Evaluation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "status", "requester_id", "evaluator_id").
+		First(&evaluation, evaluationID).Error; err != nil {
+		return nil, err
+	}
+	if evaluation.Status == entities.EvaluationStatusCompleted {
+		return nil, ErrEvaluationCompleted
+	}
+	return &evaluation, nil
+}
+
+// replaceHashBands indexes the perceptual hash of a photo, replacing the
+// bands of its previous file
+func replaceHashBands(tx *gorm.DB, photo *entities.EvaluationPhoto) error {
+	if err := tx.Where("photo_id = ?", photo.ID).Delete(&entities.PhotoHashBand{}).Error; err != nil {
+		return err
+	}
+	if photo.PerceptualHash == nil {
+		return nil
+	}
+
+	bands := photoHashBands(*photo.PerceptualHash)
+	for i := range bands {
+		bands[i].PhotoID = photo.ID
+	}
+	return tx.Create(&bands).Error
+}
+
+// photoHashBands splits a perceptual hash in photoHashBandCount bands of
+// consecutive bits
+func photoHashBands(hash uint64) []entities.PhotoHashBand {
+	bands := make([]entities.PhotoHashBand, photoHashBandCount)
+	shift := 0
+	for i := range bands {
+		width := 64 / photoHashBandCount
+		if i < 64%photoHashBandCount {
+			width++
+		}
+		bands[i] = entities.PhotoHashBand{
+			Band:  i,
+			Value: int(hash >> shift & (1<<width - 1)),
+		}
+		shift += width
+	}
+	return bands
+}
+
+// isDuplicateKey tells whether err is the violation of a unique index
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	translator, ok := db.Dialector.(gorm.ErrorTranslator)
+	return ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey)
+}
+
+// checkDuplicate rejects a photo identical to another photo of the same
+// evaluation, other than the one being replaced
+func (s *EvaluationPhotoService) checkDuplicate(photo *entities.EvaluationPhoto, replacedPhotoID int) error {
+	var count int64
+	if err := s.db.Model(&entities.EvaluationPhoto{}).
+		Where("evaluation_id = ? AND sha256 = ? AND id <> ?", photo.EvaluationID, photo.SHA256, replacedPhotoID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return ErrDuplicatePhoto
+	}
+	return nil
+}
+
+// flagReusedPhoto records matches between a new photo and the photos of other
+// evaluations, either identical or perceptually similar. Failures are only
+// logged so that the upload still succeeds.
+func (s *EvaluationPhotoService) flagReusedPhoto(photo *entities.EvaluationPhoto) {
+	candidates, err := s.findReusedPhotoCandidates(photo)
+	if err != nil {
+		log.Printf("failed to look for photos matching photo %d: %v", photo.ID, err)
+		return
+	}
+
+	for _, candidate := range candidates {
+		match := entities.PhotoMatch{
+			PhotoID:        photo.ID,
+			MatchedPhotoID: candidate.ID,
+			MatchType:      entities.PhotoMatchTypeSimilar,
+			Status:         entities.PhotoMatchStatusPending,
+		}
+
+		if candidate.SHA256 != nil && *candidate.SHA256 == *photo.SHA256 {
+			match.MatchType = entities.PhotoMatchTypeExact
+		} else if candidate.PerceptualHash != nil {
+			match.Distance = media.HashDistance(*photo.PerceptualHash, *candidate.PerceptualHash)
+		}
+
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&match).Error; err != nil {
+			log.Printf("failed to flag photo %d matching photo %d: %v", photo.ID, candidate.ID, err)
+		}
+	}
+}
+
+// BackfillHashes computes the checksum and perceptual hash of the photos
+// uploaded before they were recorded, indexes the perceptual hashes not
+// indexed yet and flags the reused photos found. It returns how many photos
+// were processed.
+func (s *EvaluationPhotoService) BackfillHashes() (int, error) {
+	var photoIDs []int
+	if err := s.db.Model(&entities.EvaluationPhoto{}).
+		Where("sha256 IS NULL OR (perceptual_hash IS NOT NULL AND NOT EXISTS (?))",
+			s.db.Model(&entities.PhotoHashBand{}).
+				Select("1").
+				Where("photo_hash_bands.photo_id = evaluation_photos.id")).
+		Order("id").
+		Pluck("id", &photoIDs).Error; err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, photoID := range photoIDs {
+		if err := s.backfillHashes(photoID); err != nil {
+			log.Printf("failed to backfill the hashes of photo %d: %v", photoID, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+func (s *EvaluationPhotoService) backfillHashes(photoID int) error {
+	var photo entities.EvaluationPhoto
+	if err := s.db.First(&photo, photoID).Error; err != nil {
+		return err
+	}
+
+	if photo.SHA256 == nil {
+		data, err := s.readStoredPhoto(photo.S3Key)
+		if err != nil {
+			return err
+		}
+
+		digest := sha256.Sum256(data)
+		checksum := hex.EncodeToString(digest[:])
+		photo.SHA256 = &checksum
+		if img, err := media.Decode(bytes.NewReader(data)); err == nil {
+			hash := media.PerceptualHash(img)
+			photo.PerceptualHash = &hash
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&photo).Select("sha256", "perceptual_hash").Updates(&photo).Error; err != nil {
+			return err
+		}
+		return replaceHashBands(tx, &photo)
+	})
+	if err != nil {
+		// Left without a checksum, for an admin to remove the copy
+		if isDuplicateKey(s.db, err) {
+			return ErrDuplicatePhoto
+		}
+		return err
+	}
+
+	s.flagReusedPhoto(&photo)
+	return nil
+}
+
+// findReusedPhotoCandidates returns the photos of other evaluations with the
+// same checksum, and those sharing a band of the perceptual hash that are
+// similar enough
+func (s *EvaluationPhotoService) findReusedPhotoCandidates(photo *entities.EvaluationPhoto) ([]entities.EvaluationPhoto, error) {
+	var candidates []entities.EvaluationPhoto
+	if err := s.db.Model(&entities.EvaluationPhoto{}).
+		Where("evaluation_id <> ? AND sha256 = ?", photo.EvaluationID, photo.SHA256).
+		Select("id", "sha256", "perceptual_hash").
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	if photo.PerceptualHash == nil {
+		return candidates, nil
+	}
+
+	bands := make([][]interface{}, 0, photoHashBandCount)
+	for _, band := range photoHashBands(*photo.PerceptualHash) {
+		bands = append(bands, []interface{}{band.Band, band.Value})
+	}
+
+	var similar []entities.EvaluationPhoto
+	if err := s.db.Model(&entities.EvaluationPhoto{}).
+		Where("id IN (?)", s.db.Model(&entities.PhotoHashBand{}).
+			Select("photo_id").
+			Where("(band, value) IN ?", bands)).
+		Where("evaluation_id <> ? AND BIT_COUNT(perceptual_hash ^ ?) <= ?", photo.EvaluationID, *photo.PerceptualHash, maxSimilarPhotoDistance).
+		Select("id", "sha256", "perceptual_hash").
+		Find(&similar).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[int]bool, len(candidates))
+	for _, candidate := range candidates {
+		found[candidate.ID] = true
+	}
+	for _, candidate := range similar {
+		if !found[candidate.ID] {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates, nil
+}
+
+func setPhotoWarnings(photo *entities.EvaluationPhoto) {
+	if photo.CaptureDistanceKm != nil && *photo.CaptureDistanceKm > maxCaptureDistanceKm {
+		photo.Warnings = append(photo.Warnings, fmt.Sprintf("photo was taken %.0f km away from the evaluation's city", *photo.CaptureDistanceKm))
+	}
+}
+
+// distanceKm is the great-circle distance between two coordinates
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
 func (s *EvaluationPhotoService) ListPhotos(evaluationID int) ([]entities.EvaluationPhoto, error) {
 	var photos []entities.EvaluationPhoto
 
 	if err := s.db.Where("evaluation_id = ?", evaluationID).
-		Order("created_at DESC").
+		Order("position, id").
 		Find(&photos).Error; err != nil {
 		return nil, err
 	}
 
+	for i := range photos {
+		if err := s.presignPhoto(&photos[i]); err != nil {
+			return nil, err
+		}
+		setPhotoWarnings(&photos[i])
+	}
+
 	return photos, nil
 }
+
+// presignPhoto fills the download URLs of a photo and of its variants, when
+// they have been generated
+func (s *EvaluationPhotoService) presignPhoto(photo *entities.EvaluationPhoto) error {
+	url, err := s.store.Presign(photo.S3Key, photoURLExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to generate photo URL: %w", err)
+	}
+	photo.URL = url
+
+	if photo.ThumbnailKey != nil {
+		if photo.ThumbnailURL, err = s.store.Presign(*photo.ThumbnailKey, photoURLExpiry); err != nil {
+			return fmt.Errorf("failed to generate thumbnail URL: %w", err)
+		}
+	}
+
+	if photo.MediumKey != nil {
+		if photo.MediumURL, err = s.store.Presign(*photo.MediumKey, photoURLExpiry); err != nil {
+			return fmt.Errorf("failed to generate medium URL: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *EvaluationPhotoService) UpdatePhoto(evaluationID int, photoID int, userID int, input UpdatePhotoInput) (*entities.EvaluationPhoto, error) {
+	photo, err := s.getEditablePhoto(evaluationID, photoID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if input.Category != nil {
+		// An empty category clears it
+		category, err := parsePhotoCategory(*input.Category)
+		if err != nil {
+			return nil, err
+		}
+		updates["category"] = category
+	}
+	if input.Caption != nil {
+		caption := strings.TrimSpace(*input.Caption)
+		if caption == "" {
+			updates["caption"] = nil
+		} else {
+			updates["caption"] = caption
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(photo).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.First(photo, photo.ID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.presignPhoto(photo); err != nil {
+		return nil, err
+	}
+	setPhotoWarnings(photo)
+
+	return photo, nil
+}
+
+// DeletePhoto removes a photo record along with its stored original and variants
+func (s *EvaluationPhotoService) DeletePhoto(evaluationID int, photoID int, userID int) error {
+	photo, err := s.getEditablePhoto(evaluationID, photoID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(photo).Error; err != nil {
+		return err
+	}
+
+	s.deletePhotoObjects(photo)
+	return nil
+}
+
+// ReplacePhoto swaps the file of a photo, keeping its ID, category, caption
+// and position. Variants are generated again for the new file.
+func (s *EvaluationPhotoService) ReplacePhoto(evaluationID int, photoID int, userID int, input UploadPhotoInput) (*entities.EvaluationPhoto, error) {
+	photo, err := s.getEditablePhoto(evaluationID, photoID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var evaluation entities.Evaluation
+	if err := s.db.First(&evaluation, evaluationID).Error; err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(storage.LimitReader(input.File, maxPhotoSize))
+	if err != nil {
+		if errors.Is(err, storage.ErrTooLarge) {
+			return nil, fmt.Errorf("file too large: %w", storage.ErrTooLarge)
+		}
+		return nil, err
+	}
+
+	replacement, content, err := s.preparePhoto(&evaluation, data, input.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkDuplicate(replacement, photo.ID); err != nil {
+		return nil, err
+	}
+
+	// A new key keeps cached URLs of the previous file from serving stale content
+	s3Key := newPhotoKey(evaluationID, photoExtensions[*replacement.ContentType])
+	if err := s.store.Put(s3Key, bytes.NewReader(content), *replacement.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	// The record, its hash bands and its matches change together; the previous
+	// files are only removed once the new ones are committed
+	previous := *photo
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(photo).Select(
+			"s3_bucket", "s3_key", "content_type", "size_bytes", "width", "height",
+			"sha256", "perceptual_hash",
+			"captured_at", "latitude", "longitude", "orientation", "capture_distance_km",
+			"thumbnail_key", "medium_key", "variants_status",
+		).Updates(&entities.EvaluationPhoto{
+			S3Bucket:          replacement.S3Bucket,
+			S3Key:             s3Key,
+			ContentType:       replacement.ContentType,
+			SizeBytes:         replacement.SizeBytes,
+			Width:             replacement.Width,
+			Height:            replacement.Height,
+			SHA256:            replacement.SHA256,
+			PerceptualHash:    replacement.PerceptualHash,
+			CapturedAt:        replacement.CapturedAt,
+			Latitude:          replacement.Latitude,
+			Longitude:         replacement.Longitude,
+			Orientation:       replacement.Orientation,
+			CaptureDistanceKm: replacement.CaptureDistanceKm,
+			VariantsStatus:    entities.PhotoVariantsStatusPending,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := replaceHashBands(tx, &entities.EvaluationPhoto{ID: photo.ID, PerceptualHash: replacement.PerceptualHash}); err != nil {
+			return err
+		}
+
+		// Matches found for the previous file no longer apply
+		return tx.Where("(photo_id = ? OR matched_photo_id = ?) AND status = ?", photo.ID, photo.ID, entities.PhotoMatchStatusPending).
+			Delete(&entities.PhotoMatch{}).Error
+	})
+	if err != nil {
+		s.store.Delete(s3Key)
+		if isDuplicateKey(s.db, err) {
+			if duplicateErr := s.checkDuplicate(replacement, photo.ID); duplicateErr != nil {
+				return nil, duplicateErr
+			}
+		}
+		return nil, err
+	}
+
+	s.deletePhotoObjects(&previous)
+	s.variants.Enqueue(photo.ID)
+
+	replacement.ID = photo.ID
+	s.flagReusedPhoto(replacement)
+
+	if err := s.db.First(photo, photo.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := s.presignPhoto(photo); err != nil {
+		return nil, err
+	}
+	setPhotoWarnings(photo)
+
+	return photo, nil
+}
+
+// getEditableEvaluation loads an evaluation that is not completed yet
+func (s *EvaluationPhotoService) getEditableEvaluation(evaluationID int) (*entities.Evaluation, error) {
+	var evaluation entities.Evaluation
+	if err := s.db.First(&evaluation, evaluationID).Error; err != nil {
+		return nil, err
+	}
+
+	if evaluation.Status == entities.EvaluationStatusCompleted {
+		return nil, ErrEvaluationCompleted
+	}
+
+	return &evaluation, nil
+}
+
+// getEditablePhoto loads a photo the user can change, of an evaluation that
+// is not completed yet
+func (s *EvaluationPhotoService) getEditablePhoto(evaluationID int, photoID int, userID int) (*entities.EvaluationPhoto, error) {
+	var photo entities.EvaluationPhoto
+	if err := s.db.Preload("Evaluation").
+		Where("id = ? AND evaluation_id = ?", photoID, evaluationID).
+		First(&photo).Error; err != nil {
+		return nil, ErrPhotoNotFound
+	}
+
+	if err := s.checkChangeAccess(&photo.Evaluation, userID); err != nil {
+		return nil, err
+	}
+
+	if photo.Evaluation.Status == entities.EvaluationStatusCompleted {
+		return nil, ErrEvaluationCompleted
+	}
+
+	return &photo, nil
+}
+
+// checkChangeAccess tells whether the user can change or remove existing
+// photos of an evaluation: its requester, its assigned evaluator or an admin
+func (s *EvaluationPhotoService) checkChangeAccess(evaluation *entities.Evaluation, userID int) error {
+	if evaluation.RequesterID == userID ||
+		(evaluation.EvaluatorID != nil && *evaluation.EvaluatorID == userID) ||
+		isAdmin(s.db, userID) {
+		return nil
+	}
+	return ErrPhotoAccessDenied
+}
+
+// deletePhotoObjects removes the stored files of a photo. Failures are only
+// logged: the record is already gone and orphaned objects are harmless.
+func (s *EvaluationPhotoService) deletePhotoObjects(photo *entities.EvaluationPhoto) {
+	keys := []string{photo.S3Key}
+	if photo.ThumbnailKey != nil {
+		keys = append(keys, *photo.ThumbnailKey)
+	}
+	if photo.MediumKey != nil {
+		keys = append(keys, *photo.MediumKey)
+	}
+
+	for _, key := range keys {
+		if err := s.store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("failed to delete photo object %s: %v", key, err)
+		}
+	}
+}
+
+// ReorderPhotos sets the gallery order of an evaluation's photos. The input
+// must list every photo of the evaluation exactly once.
+func (s *EvaluationPhotoService) ReorderPhotos(evaluationID int, userID int, input ReorderPhotosInput) ([]entities.EvaluationPhoto, error) {
+	// Under the evaluation lock no photo can be added while the list is checked
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		evaluation, err := lockEditableEvaluation(tx, evaluationID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkChangeAccess(evaluation, userID); err != nil {
+			return err
+		}
+
+		var photoIDs []int
+		if err := tx.Model(&entities.EvaluationPhoto{}).
+			Where("evaluation_id = ?", evaluationID).
+			Pluck("id", &photoIDs).Error; err != nil {
+			return err
+		}
+
+		existing := make(map[int]bool, len(photoIDs))
+		for _, id := range photoIDs {
+			existing[id] = true
+		}
+
+		if len(input.PhotoIDs) != len(photoIDs) {
+			return errors.New("photo_ids must list every photo of the evaluation")
+		}
+		seen := make(map[int]bool, len(input.PhotoIDs))
+		for _, id := range input.PhotoIDs {
+			if !existing[id] || seen[id] {
+				return fmt.Errorf("invalid or repeated photo ID %d", id)
+			}
+			seen[id] = true
+		}
+
+		for i, id := range input.PhotoIDs {
+			if err := tx.Model(&entities.EvaluationPhoto{}).
+				Where("id = ?", id).
+				Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ListPhotos(evaluationID)
+}
+
+// GetCompleteness compares the categories of an evaluation's photos with the
+// ones required for its inspection type
+func (s *EvaluationPhotoService) GetCompleteness(evaluationID int) (*PhotoCompleteness, error) {
+	var evaluation entities.Evaluation
+	if err := s.db.First(&evaluation, evaluationID).Error; err != nil {
+		return nil, err
+	}
+
+	var categories []entities.PhotoCategory
+	if err := s.db.Model(&entities.EvaluationPhoto{}).
+		Where("evaluation_id = ? AND category IS NOT NULL", evaluationID).
+		Distinct().
+		Pluck("category", &categories).Error; err != nil {
+		return nil, err
+	}
+
+	taken := make(map[entities.PhotoCategory]bool, len(categories))
+	for _, category := range categories {
+		taken[category] = true
+	}
+
+	completeness := &PhotoCompleteness{
+		InspectionType: evaluation.InspectionType,
+		Required:       requiredPhotoCategories(evaluation.InspectionType),
+		Missing:        []entities.PhotoCategory{},
+	}
+	for _, category := range completeness.Required {
+		if !taken[category] {
+			completeness.Missing = append(completeness.Missing, category)
+		}
+	}
+	completeness.Complete = len(completeness.Missing) == 0
+
+	return completeness, nil
+}
+
+// requiredPhotoCategories reads the configured set for an inspection type,
+// ignoring unknown categories
+func requiredPhotoCategories(inspectionType entities.InspectionType) []entities.PhotoCategory {
+	photosConfig := configs.Get().Photos
+
+	var configured string
+	switch inspectionType {
+	case entities.InspectionTypeComplete:
+		configured = photosConfig.RequiredComplete
+	case entities.InspectionTypePrecautionary:
+		configured = photosConfig.RequiredPrecautionary
+	default:
+		configured = photosConfig.RequiredStandard
+	}
+
+	required := []entities.PhotoCategory{}
+	for _, value := range strings.Split(configured, ",") {
+		category := entities.PhotoCategory(strings.TrimSpace(value))
+		if photoCategories[category] {
+			required = append(required, category)
+		}
+	}
+	return required
+}
+
+func parsePhotoCategory(value string) (*entities.PhotoCategory, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	category := entities.PhotoCategory(value)
+	if !photoCategories[category] {
+		return nil, fmt.Errorf("invalid photo category: %s", value)
+	}
+	return &category, nil
+}
+
+func newPhotoKey(evaluationID int, ext string) string {
+	return fmt.Sprintf("evaluations/%d/photos/%d.%s", evaluationID, time.Now().UnixNano(), ext)
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPhotoHashBandsCoverTheHash(t *testing.T) {
+	hash := uint64(0xF0E1D2C3B4A59687)
+
+	bands := photoHashBands(hash)
+	if len(bands) != photoHashBandCount {
+		t.Fatalf("%d bands, want %d", len(bands), photoHashBandCount)
+	}
+
+	var rebuilt uint64
+	shift := 0
+	for i, band := range bands {
+		width := 64 / photoHashBandCount
+		if i < 64%photoHashBandCount {
+			width++
+		}
+		rebuilt |= uint64(band.Value) << shift
+		shift += width
+	}
+	if rebuilt != hash || shift != 64 {
+		t.Errorf("bands rebuild %016X over %d bits, want %016X over 64", rebuilt, shift, hash)
+	}
+}
+
+func TestSimilarPhotoHashesShareABand(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		hash := random.Uint64()
+		similar := hash
+		for _, bit := range random.Perm(64)[:maxSimilarPhotoDistance] {
+			similar ^= 1 << bit
+		}
+
+		bands := photoHashBands(hash)
+		shared := false
+		for i, band := range photoHashBands(similar) {
+			shared = shared || bands[i].Value == band.Value
+		}
+		if !shared {
+			t.Fatalf("%016X and %016X differ in %d bits but share no band", hash, similar, maxSimilarPhotoDistance)
+		}
+	}
+}
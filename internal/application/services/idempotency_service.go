@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const idempotencyPurgeInterval = time.Hour
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyService stores the responses of requests sent with an
+// Idempotency-Key header, per key and user, until the configured window
+// expires
+type IdempotencyService struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyService(db *gorm.DB) *IdempotencyService {
+	return &IdempotencyService{db: db}
+}
+
+// Start runs the background worker deleting expired keys
+func (s *IdempotencyService) Start() {
+	go s.run()
+}
+
+func (s *IdempotencyService) run() {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		result := s.db.Where("expires_at <= ?", time.Now()).Delete(&entities.IdempotencyKey{})
+		if result.Error != nil {
+			log.Printf("failed to purge idempotency keys: %v", result.Error)
+		}
+		<-ticker.C
+	}
+}
+
+// Begin claims a key for a request. The stored key is returned completed
+// when the request was already handled, for its response to be replayed, and
+// in progress when the request is new. Expired keys are claimed again.
+func (s *IdempotencyService) Begin(userID int, key string, fingerprint string) (*entities.IdempotencyKey, error) {
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(configs.Get().Idempotency.KeyTTLHours) * time.Hour)
+
+	record := &entities.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		Status:      entities.IdempotencyKeyStatusInProgress,
+		ExpiresAt:   expiresAt,
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return record, nil
+	}
+
+	lookup := &entities.IdempotencyKey{UserID: userID, Key: key}
+
+	result = s.db.Model(&entities.IdempotencyKey{}).
+		Where(lookup).
+		Where("expires_at <= ?", now).
+		Updates(map[string]interface{}{
+			"fingerprint":           fingerprint,
+			"status":                entities.IdempotencyKeyStatusInProgress,
+			"response_status":       0,
+			"response_content_type": "",
+			"response_body":         nil,
+			"expires_at":            expiresAt,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	claimed := result.RowsAffected > 0
+
+	var existing entities.IdempotencyKey
+	if err := s.db.Where(lookup).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released by a failed request in the meantime
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		return nil, err
+	}
+	if claimed {
+		return &existing, nil
+	}
+
+	if existing.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.Status != entities.IdempotencyKeyStatusCompleted {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return &existing, nil
+}
+
+// Complete stores the response to the request of a key
+func (s *IdempotencyService) Complete(record *entities.IdempotencyKey, status int, contentType string, body []byte) error {
+	record.Status = entities.IdempotencyKeyStatusCompleted
+	record.ResponseStatus = status
+	record.ResponseContentType = contentType
+	record.ResponseBody = body
+
+	return s.db.Model(record).
+		Select("status", "response_status", "response_content_type", "response_body").
+		Updates(record).Error
+}
+
+// Release frees a key whose request failed, so that it can be retried
+func (s *IdempotencyService) Release(record *entities.IdempotencyKey) error {
+	return s.db.Delete(record).Error
+}
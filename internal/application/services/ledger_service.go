@@ -0,0 +1,446 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnbalancedLedgerTransaction = errors.New("ledger transaction entries do not add up to zero")
+	ErrEvaluatorNotFound           = errors.New("evaluator not found")
+)
+
+// LedgerService records evaluator earnings in a double-entry ledger: every
+// transaction credits and debits accounts by amounts that add up to zero, and
+// an evaluator's balance is the sum of their payable account.
+type LedgerService struct {
+	db *gorm.DB
+}
+
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{db: db}
+}
+
+type PenaltyInput struct {
+	AmountCents  int    `json:"amount_cents" binding:"required,min=1"`
+	Reason       string `json:"reason" binding:"required,max=200"`
+	EvaluationID *int   `json:"evaluation_id"`
+}
+
+type PayoutDetailsInput struct {
+	PixKey string `json:"pix_key" binding:"required,max=77"`
+}
+
+// StatementLine is an entry of an evaluator's payable account
+type StatementLine struct {
+	ID           int                            `json:"id"`
+	Kind         entities.LedgerTransactionKind `json:"kind"`
+	Description  string                         `json:"description"`
+	EvaluationID *int                           `json:"evaluation_id,omitempty"`
+	PayoutID     *int                           `json:"payout_id,omitempty"`
+	AmountCents  int                            `json:"amount_cents"`
+	CreatedAt    time.Time                      `json:"created_at"`
+}
+
+type Earnings struct {
+	BalanceCents int `json:"balance_cents"`
+	// Earnings of disputed evaluations, not paid out until the disputes are settled
+	HeldCents int                  `json:"held_cents"`
+	Currency  string               `json:"currency"`
+	PixKey    *string              `json:"pix_key,omitempty"`
+	Statement *Page[StatementLine] `json:"statement"`
+}
+
+// RecordEarning credits the evaluator of a completed evaluation once its
+// payment is captured; it is called again on capture for evaluations
+// completed before. Their share is computed on the price before coupons,
+// whose discounts are funded by the platform, less what was refunded before
+// completion. Earnings of disputed evaluations are held, and evaluations
+// whose payment was not captured or was charged back earn nothing. Recording
+// an evaluation twice has no effect.
+func (s *LedgerService) RecordEarning(tx *gorm.DB, evaluation *entities.Evaluation) error {
+	if evaluation.EvaluatorID == nil {
+		return nil
+	}
+
+	var record entities.Payment
+	if err := tx.Where("evaluation_id = ?", evaluation.ID).Limit(1).Find(&record).Error; err != nil {
+		return err
+	}
+	// Only money actually captured is shared: evaluations whose payment is
+	// still pending or authorized are credited when it is captured
+	switch record.Status {
+	case entities.PaymentStatusPaid, entities.PaymentStatusPartiallyRefunded, entities.PaymentStatusDisputed:
+	default:
+		return nil
+	}
+
+	return s.record(tx, &entities.LedgerTransaction{
+		Kind:         entities.LedgerTransactionKindEarning,
+		Reference:    fmt.Sprintf("evaluation:%d:earning", evaluation.ID),
+		Description:  fmt.Sprintf("Avaliação #%d concluída", evaluation.ID),
+		EvaluationID: &evaluation.ID,
+	}, earningEntries(evaluation, &record, configs.Get().Payout.CommissionPercent))
+}
+
+// earningEntries splits what was captured for an evaluation between its
+// evaluator and the platform, which funds the coupon discounts
+func earningEntries(evaluation *entities.Evaluation, record *entities.Payment, commissionPercent int) []entities.LedgerEntry {
+	// Evaluations are charged their quoted price, or for evaluations from
+	// before pricing rules, the amount of their payment
+	price := record.AmountCents
+	if evaluation.PriceCents != nil {
+		price = *evaluation.PriceCents
+	}
+	charged := price - min(record.RefundedCents, price)
+
+	discount := 0
+	if evaluation.PriceQuote != nil {
+		for _, adjustment := range evaluation.PriceQuote.Adjustments {
+			if adjustment.CouponID != nil {
+				discount -= adjustment.AmountCents
+			}
+		}
+	}
+	if charged < price {
+		discount = discount * charged / price
+	}
+
+	gross := charged + discount
+	commission := int(math.Round(float64(gross) * float64(commissionPercent) / 100))
+	evaluatorID := *evaluation.EvaluatorID
+
+	account := entities.LedgerAccountEvaluatorPayable
+	if record.Status == entities.PaymentStatusDisputed {
+		account = entities.LedgerAccountEvaluatorHeld
+	}
+
+	return []entities.LedgerEntry{
+		{Account: entities.LedgerAccountCustomerPayments, AmountCents: -charged},
+		{Account: entities.LedgerAccountPromotions, AmountCents: -discount},
+		{Account: account, UserID: &evaluatorID, AmountCents: gross - commission},
+		{Account: entities.LedgerAccountPlatformCommission, AmountCents: commission},
+	}
+}
+
+// RecordRefund debits the evaluator of an evaluation in proportion to the
+// amount refunded to the customer; the platform gives back the rest.
+// Evaluations not credited yet are not affected: their earning is computed
+// on what is left once completed.
+func (s *LedgerService) RecordRefund(tx *gorm.DB, evaluationID int, refundID int, amountCents int) error {
+	var earning entities.LedgerTransaction
+	err := tx.Preload("Entries").
+		Where("reference = ?", fmt.Sprintf("evaluation:%d:earning", evaluationID)).
+		First(&earning).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entries := refundEntries(earning.Entries, amountCents)
+	if entries == nil {
+		return nil
+	}
+
+	return s.record(tx, &entities.LedgerTransaction{
+		Kind:         entities.LedgerTransactionKindRefund,
+		Reference:    fmt.Sprintf("refund:%d", refundID),
+		Description:  fmt.Sprintf("Estorno da avaliação #%d", evaluationID),
+		EvaluationID: &evaluationID,
+	}, entries)
+}
+
+// refundEntries debits the evaluator credited by the earning entries in
+// proportion to the amount refunded. It returns nil when the earning credited
+// no one.
+func refundEntries(earning []entities.LedgerEntry, amountCents int) []entities.LedgerEntry {
+	charged, share := 0, 0
+	var evaluatorID *int
+	for _, entry := range earning {
+		switch entry.Account {
+		case entities.LedgerAccountCustomerPayments:
+			charged = -entry.AmountCents
+		case entities.LedgerAccountEvaluatorPayable, entities.LedgerAccountEvaluatorHeld:
+			share = entry.AmountCents
+			evaluatorID = entry.UserID
+		}
+	}
+	if charged <= 0 || evaluatorID == nil {
+		return nil
+	}
+
+	debit := int(math.Round(float64(share) * float64(min(amountCents, charged)) / float64(charged)))
+
+	return []entities.LedgerEntry{
+		{Account: entities.LedgerAccountCustomerPayments, AmountCents: amountCents},
+		{Account: entities.LedgerAccountEvaluatorPayable, UserID: evaluatorID, AmountCents: -debit},
+		{Account: entities.LedgerAccountPlatformCommission, AmountCents: debit - amountCents},
+	}
+}
+
+// HoldEarning moves the earning of a disputed evaluation out of the
+// evaluator's balance, so that it is not paid out while the dispute lasts.
+// Earnings already paid out leave the balance negative until then.
+func (s *LedgerService) HoldEarning(tx *gorm.DB, evaluationID int) error {
+	return s.moveEarning(tx, evaluationID, entities.LedgerTransactionKindHold,
+		entities.LedgerAccountEvaluatorPayable, entities.LedgerAccountEvaluatorHeld,
+		fmt.Sprintf("Repasse retido: avaliação #%d contestada", evaluationID))
+}
+
+// ReleaseEarning returns the held earning of an evaluation whose dispute was
+// won to the evaluator's balance
+func (s *LedgerService) ReleaseEarning(tx *gorm.DB, evaluationID int) error {
+	return s.moveEarning(tx, evaluationID, entities.LedgerTransactionKindRelease,
+		entities.LedgerAccountEvaluatorHeld, entities.LedgerAccountEvaluatorPayable,
+		fmt.Sprintf("Contestação da avaliação #%d encerrada", evaluationID))
+}
+
+// RecordChargeback settles a lost dispute: what was left of the payment goes
+// back to the customer and the evaluator loses the earning held for the
+// evaluation. Evaluations not credited yet have nothing to settle.
+func (s *LedgerService) RecordChargeback(tx *gorm.DB, evaluationID int, lostCents int) error {
+	var count int64
+	if err := tx.Model(&entities.LedgerTransaction{}).
+		Where("reference = ?", fmt.Sprintf("evaluation:%d:earning", evaluationID)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	balances, err := s.evaluationBalances(tx, evaluationID, entities.LedgerAccountEvaluatorHeld)
+	if err != nil {
+		return err
+	}
+
+	held := 0
+	entries := []entities.LedgerEntry{
+		{Account: entities.LedgerAccountCustomerPayments, AmountCents: lostCents},
+	}
+	for _, balance := range balances {
+		held += balance.AmountCents
+		entries = append(entries, entities.LedgerEntry{
+			Account: entities.LedgerAccountEvaluatorHeld, UserID: balance.UserID, AmountCents: -balance.AmountCents,
+		})
+	}
+	entries = append(entries, entities.LedgerEntry{
+		Account: entities.LedgerAccountPlatformCommission, AmountCents: held - lostCents,
+	})
+
+	return s.record(tx, &entities.LedgerTransaction{
+		Kind:         entities.LedgerTransactionKindChargeback,
+		Reference:    fmt.Sprintf("evaluation:%d:chargeback", evaluationID),
+		Description:  fmt.Sprintf("Chargeback da avaliação #%d", evaluationID),
+		EvaluationID: &evaluationID,
+	}, entries)
+}
+
+// RecordPenalty debits an evaluator (admin only)
+func (s *LedgerService) RecordPenalty(adminID int, evaluatorID int, input PenaltyInput) (*entities.LedgerTransaction, error) {
+	if !isAdmin(s.db, adminID) {
+		return nil, ErrAdminRequired
+	}
+
+	var evaluator entities.Evaluator
+	if err := s.db.Where("user_id = ?", evaluatorID).First(&evaluator).Error; err != nil {
+		return nil, ErrEvaluatorNotFound
+	}
+
+	token, err := generateSecureToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := &entities.LedgerTransaction{
+		Kind:         entities.LedgerTransactionKindPenalty,
+		Reference:    "penalty:" + token,
+		Description:  input.Reason,
+		EvaluationID: input.EvaluationID,
+		CreatedByID:  &adminID,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.record(tx, transaction, []entities.LedgerEntry{
+			{Account: entities.LedgerAccountEvaluatorPayable, UserID: &evaluatorID, AmountCents: -input.AmountCents},
+			{Account: entities.LedgerAccountPenalties, AmountCents: input.AmountCents},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// GetEarnings returns a user's balance and the statement of their payable
+// account, most recent first
+func (s *LedgerService) GetEarnings(userID int, input PageInput) (*Earnings, error) {
+	balance, err := s.accountBalance(s.db, entities.LedgerAccountEvaluatorPayable, userID)
+	if err != nil {
+		return nil, err
+	}
+	held, err := s.accountBalance(s.db, entities.LedgerAccountEvaluatorHeld, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := input.normalized()
+	query := s.db.Table("ledger_entries AS e").
+		Joins("JOIN ledger_transactions AS t ON t.id = e.transaction_id").
+		Where("e.account = ? AND e.user_id = ?", entities.LedgerAccountEvaluatorPayable, userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	lines := make([]StatementLine, 0)
+	if err := query.Select("e.id, t.kind, t.description, t.evaluation_id, t.payout_id, e.amount_cents, e.created_at").
+		Order("e.id DESC").
+		Offset(page.offset()).
+		Limit(page.PageSize).
+		Scan(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	var evaluator entities.Evaluator
+	s.db.Select("pix_key").Where("user_id = ?", userID).Limit(1).Find(&evaluator)
+
+	return &Earnings{
+		BalanceCents: balance,
+		HeldCents:    held,
+		Currency:     configs.Get().Payment.Currency,
+		PixKey:       evaluator.PixKey,
+		Statement: &Page[StatementLine]{
+			Items:    lines,
+			Page:     page.Page,
+			PageSize: page.PageSize,
+			Total:    total,
+		},
+	}, nil
+}
+
+// UpdatePayoutDetails sets where an evaluator's payouts are sent
+func (s *LedgerService) UpdatePayoutDetails(userID int, input PayoutDetailsInput) error {
+	result := s.db.Model(&entities.Evaluator{}).
+		Where("user_id = ?", userID).
+		Update("pix_key", input.PixKey)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := s.db.Model(&entities.Evaluator{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrEvaluatorNotFound
+		}
+	}
+	return nil
+}
+
+func (s *LedgerService) accountBalance(db *gorm.DB, account entities.LedgerAccount, userID int) (int, error) {
+	var balance int
+	if err := db.Model(&entities.LedgerEntry{}).
+		Where("account = ? AND user_id = ?", account, userID).
+		Select("COALESCE(SUM(amount_cents), 0)").
+		Scan(&balance).Error; err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+type userBalance struct {
+	UserID      *int
+	AmountCents int
+}
+
+// evaluationBalances returns the positive balances of an account coming from
+// the earning of an evaluation and the refunds and disputes that followed
+func (s *LedgerService) evaluationBalances(tx *gorm.DB, evaluationID int, account entities.LedgerAccount) ([]userBalance, error) {
+	var balances []userBalance
+	err := tx.Table("ledger_entries AS e").
+		Select("e.user_id, SUM(e.amount_cents) AS amount_cents").
+		Joins("JOIN ledger_transactions AS t ON t.id = e.transaction_id").
+		Where("t.evaluation_id = ? AND e.account = ? AND t.kind IN ?", evaluationID, account, []entities.LedgerTransactionKind{
+			entities.LedgerTransactionKindEarning,
+			entities.LedgerTransactionKindRefund,
+			entities.LedgerTransactionKindHold,
+			entities.LedgerTransactionKindRelease,
+			entities.LedgerTransactionKindChargeback,
+		}).
+		Group("e.user_id").
+		Having("SUM(e.amount_cents) > 0").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// moveEarning moves the balances of an evaluation between two evaluator
+// accounts. Each move is a new transaction, as disputes can be reopened.
+func (s *LedgerService) moveEarning(tx *gorm.DB, evaluationID int, kind entities.LedgerTransactionKind, from, to entities.LedgerAccount, description string) error {
+	balances, err := s.evaluationBalances(tx, evaluationID, from)
+	if err != nil || len(balances) == 0 {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&entities.LedgerTransaction{}).
+		Where("evaluation_id = ? AND kind = ?", evaluationID, kind).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	entries := make([]entities.LedgerEntry, 0, len(balances)*2)
+	for _, balance := range balances {
+		entries = append(entries,
+			entities.LedgerEntry{Account: from, UserID: balance.UserID, AmountCents: -balance.AmountCents},
+			entities.LedgerEntry{Account: to, UserID: balance.UserID, AmountCents: balance.AmountCents},
+		)
+	}
+
+	return s.record(tx, &entities.LedgerTransaction{
+		Kind:         kind,
+		Reference:    fmt.Sprintf("evaluation:%d:%s:%d", evaluationID, kind, count+1),
+		Description:  description,
+		EvaluationID: &evaluationID,
+	}, entries)
+}
+
+// record stores a transaction and its non-zero entries, which must add up to
+// zero. A transaction whose reference was already recorded is skipped.
+func (s *LedgerService) record(tx *gorm.DB, transaction *entities.LedgerTransaction, entries []entities.LedgerEntry) error {
+	sum := 0
+	kept := make([]entities.LedgerEntry, 0, len(entries))
+	for _, entry := range entries {
+		sum += entry.AmountCents
+		if entry.AmountCents != 0 {
+			kept = append(kept, entry)
+		}
+	}
+	if sum != 0 {
+		return ErrUnbalancedLedgerTransaction
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(transaction)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 || len(kept) == 0 {
+		return nil
+	}
+
+	for i := range kept {
+		kept[i].TransactionID = transaction.ID
+	}
+	return tx.Create(&kept).Error
+}
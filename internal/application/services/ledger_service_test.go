@@ -0,0 +1,122 @@
+package services
+
+import (
+	"indicar-api/internal/domain/entities"
+	"testing"
+)
+
+// ledgerAmounts sums the entries of a transaction by account, checking that
+// they balance
+func ledgerAmounts(t *testing.T, entries []entities.LedgerEntry) map[entities.LedgerAccount]int {
+	t.Helper()
+	sum := 0
+	amounts := make(map[entities.LedgerAccount]int)
+	for _, entry := range entries {
+		sum += entry.AmountCents
+		amounts[entry.Account] += entry.AmountCents
+	}
+	if sum != 0 {
+		t.Errorf("entries add up to %d, want 0: %+v", sum, entries)
+	}
+	return amounts
+}
+
+func testEvaluationWithCoupon() *entities.Evaluation {
+	evaluatorID, couponID := 7, 3
+	price := 15000
+	return &entities.Evaluation{
+		ID:          42,
+		EvaluatorID: &evaluatorID,
+		PriceCents:  &price,
+		PriceQuote: &entities.PriceQuote{
+			BasePriceCents: 20000,
+			TotalCents:     15000,
+			Adjustments: []entities.PriceAdjustment{
+				{Kind: "coupon", CouponID: &couponID, AmountCents: -5000},
+			},
+		},
+	}
+}
+
+func TestEarningEntries(t *testing.T) {
+	evaluation := testEvaluationWithCoupon()
+
+	tests := []struct {
+		name    string
+		payment entities.Payment
+		want    map[entities.LedgerAccount]int
+	}{
+		{
+			// The platform funds the coupon: the evaluator's share is
+			// computed on the price before the discount
+			name:    "paid",
+			payment: entities.Payment{AmountCents: 15000, Status: entities.PaymentStatusPaid},
+			want: map[entities.LedgerAccount]int{
+				entities.LedgerAccountCustomerPayments:   -15000,
+				entities.LedgerAccountPromotions:         -5000,
+				entities.LedgerAccountEvaluatorPayable:   16000,
+				entities.LedgerAccountPlatformCommission: 4000,
+			},
+		},
+		{
+			name:    "half refunded before completion",
+			payment: entities.Payment{AmountCents: 15000, RefundedCents: 7500, Status: entities.PaymentStatusPartiallyRefunded},
+			want: map[entities.LedgerAccount]int{
+				entities.LedgerAccountCustomerPayments:   -7500,
+				entities.LedgerAccountPromotions:         -2500,
+				entities.LedgerAccountEvaluatorPayable:   8000,
+				entities.LedgerAccountPlatformCommission: 2000,
+			},
+		},
+		{
+			name:    "disputed",
+			payment: entities.Payment{AmountCents: 15000, Status: entities.PaymentStatusDisputed},
+			want: map[entities.LedgerAccount]int{
+				entities.LedgerAccountCustomerPayments:   -15000,
+				entities.LedgerAccountPromotions:         -5000,
+				entities.LedgerAccountEvaluatorHeld:      16000,
+				entities.LedgerAccountPlatformCommission: 4000,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries := earningEntries(evaluation, &test.payment, 20)
+			amounts := ledgerAmounts(t, entries)
+			for account, want := range test.want {
+				if amounts[account] != want {
+					t.Errorf("%s = %d, want %d", account, amounts[account], want)
+				}
+			}
+			for _, entry := range entries {
+				isEvaluator := entry.Account == entities.LedgerAccountEvaluatorPayable || entry.Account == entities.LedgerAccountEvaluatorHeld
+				if isEvaluator != (entry.UserID != nil) || (isEvaluator && *entry.UserID != 7) {
+					t.Errorf("entry %s has user %v", entry.Account, entry.UserID)
+				}
+			}
+		})
+	}
+}
+
+func TestRefundEntries(t *testing.T) {
+	earning := earningEntries(testEvaluationWithCoupon(), &entities.Payment{AmountCents: 15000, Status: entities.PaymentStatusPaid}, 20)
+
+	// Half of the payment refunded: the evaluator loses half of their share
+	amounts := ledgerAmounts(t, refundEntries(earning, 7500))
+	if amounts[entities.LedgerAccountCustomerPayments] != 7500 ||
+		amounts[entities.LedgerAccountEvaluatorPayable] != -8000 ||
+		amounts[entities.LedgerAccountPlatformCommission] != 500 {
+		t.Errorf("half refund moved %v", amounts)
+	}
+
+	// Refunds never debit more than the evaluator's share
+	amounts = ledgerAmounts(t, refundEntries(earning, 20000))
+	if amounts[entities.LedgerAccountEvaluatorPayable] != -16000 {
+		t.Errorf("refund above the charge debited the evaluator %d, want -16000", amounts[entities.LedgerAccountEvaluatorPayable])
+	}
+
+	if entries := refundEntries(nil, 7500); entries != nil {
+		t.Errorf("refund without an earning = %+v, want nil", entries)
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	notificationDispatchInterval = 10 * time.Second
+	notificationDispatchBatch    = 50
+	// How long a claimed notification may take to be delivered before another
+	// dispatcher can claim it again
+	notificationClaimTimeout = 5 * time.Minute
+)
+
+// ErrPermanentDelivery is wrapped by senders when retrying a delivery cannot
+// succeed, such as when the user has no device to receive it
+var ErrPermanentDelivery = errors.New("notification cannot be delivered")
+
+// NotificationSender delivers notifications of one channel
+type NotificationSender interface {
+	Send(notification *entities.Notification) error
+}
+
+// NotificationDispatcher delivers queued notifications through the sender of
+// their channel. Notifications are claimed with row locks, so several API
+// instances can dispatch at once; failed deliveries are retried with
+// exponential backoff until they reach the maximum attempts.
+type NotificationDispatcher struct {
+	db      *gorm.DB
+	senders map[string]NotificationSender
+}
+
+// NewNotificationDispatcher creates a dispatcher delivering through senders,
+// by channel. Notifications of channels without a sender are marked dead.
+func NewNotificationDispatcher(db *gorm.DB, senders map[string]NotificationSender) (*NotificationDispatcher, error) {
+	if configs.Get().Notification.MaxAttempts < 1 {
+		return nil, errors.New("NOTIFICATION_MAX_ATTEMPTS must be at least 1")
+	}
+
+	return &NotificationDispatcher{
+		db:      db,
+		senders: senders,
+	}, nil
+}
+
+// Start runs the background worker
+func (d *NotificationDispatcher) Start() {
+	go d.run()
+}
+
+func (d *NotificationDispatcher) run() {
+	ticker := time.NewTicker(notificationDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches are claimed
+		for {
+			dispatched, err := d.dispatch()
+			if err != nil {
+				log.Printf("failed to dispatch notifications: %v", err)
+			}
+			if dispatched < notificationDispatchBatch {
+				break
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// dispatch claims a batch of due notifications and delivers them, returning
+// how many were claimed
+func (d *NotificationDispatcher) dispatch() (int, error) {
+	notifications, err := d.claim()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range notifications {
+		if err := d.deliver(&notifications[i]); err != nil {
+			log.Printf("failed to update notification %d: %v", notifications[i].ID, err)
+		}
+	}
+	return len(notifications), nil
+}
+
+// claim marks due notifications as sending and counts the attempt. Queued
+// notifications, failed ones whose retry is due and sending ones whose claim
+// expired, e.g. after a crash, are due. Rows locked by another dispatcher are
+// skipped.
+func (d *NotificationDispatcher) claim() ([]entities.Notification, error) {
+	var notifications []entities.Notification
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", entities.NotificationStatusQueued).
+			Or("status IN ? AND next_attempt_at <= ?",
+				[]string{entities.NotificationStatusFailed, entities.NotificationStatusSending}, now).
+			Order("id").
+			Limit(notificationDispatchBatch).
+			Find(&notifications).Error; err != nil {
+			return err
+		}
+		if len(notifications) == 0 {
+			return nil
+		}
+
+		ids := make([]int, len(notifications))
+		for i := range notifications {
+			ids[i] = notifications[i].ID
+		}
+
+		claimedUntil := now.Add(notificationClaimTimeout)
+		if err := tx.Model(&entities.Notification{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":          entities.NotificationStatusSending,
+				"attempts":        gorm.Expr("attempts + 1"),
+				"next_attempt_at": claimedUntil,
+			}).Error; err != nil {
+			return err
+		}
+
+		for i := range notifications {
+			notifications[i].Status = entities.NotificationStatusSending
+			notifications[i].Attempts++
+			notifications[i].NextAttemptAt = &claimedUntil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// deliver sends a claimed notification and records the outcome
+func (d *NotificationDispatcher) deliver(notification *entities.Notification) error {
+	sender, ok := d.senders[notification.Channel]
+	var err error
+	if !ok {
+		err = fmt.Errorf("%w: no provider configured for channel %s", ErrPermanentDelivery, notification.Channel)
+	} else {
+		err = sender.Send(notification)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{}
+	switch {
+	case err == nil:
+		updates["status"] = entities.NotificationStatusSent
+		updates["sent_at"] = now
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = nil
+	case errors.Is(err, ErrPermanentDelivery) || notification.Attempts >= configs.Get().Notification.MaxAttempts:
+		updates["status"] = entities.NotificationStatusDead
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = err.Error()
+	default:
+		updates["status"] = entities.NotificationStatusFailed
+		updates["next_attempt_at"] = now.Add(notificationRetryDelay(notification.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	// Only the outcome of the current claim is recorded: a notification
+	// claimed again after its claim expired belongs to the new attempt
+	return d.db.Model(&entities.Notification{}).
+		Where("id = ? AND status = ? AND attempts = ?",
+			notification.ID, entities.NotificationStatusSending, notification.Attempts).
+		Updates(updates).Error
+}
+
+// notificationRetryDelay is the delay before retrying a notification that
+// failed its nth attempt: the base delay, doubled after each attempt, up to
+// the maximum
+func notificationRetryDelay(attempts int) time.Duration {
+	notificationConfig := configs.Get().Notification
+	return retryDelay(attempts, notificationConfig.RetryBaseSeconds, notificationConfig.RetryMaxSeconds)
+}
+
+// retryDelay is the exponential backoff after the nth failed attempt: the
+// base delay, doubled after each attempt, up to the maximum
+func retryDelay(attempts int, baseSeconds int, maxSeconds int) time.Duration {
+	base := time.Duration(max(baseSeconds, 1)) * time.Second
+	maxDelay := time.Duration(max(maxSeconds, 1)) * time.Second
+
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
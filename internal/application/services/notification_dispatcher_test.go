@@ -0,0 +1,33 @@
+package services
+
+import (
+	"indicar-api/configs"
+	"testing"
+	"time"
+)
+
+func TestNotificationRetryDelay(t *testing.T) {
+	t.Setenv("NOTIFICATION_RETRY_BASE_SECONDS", "30")
+	t.Setenv("NOTIFICATION_RETRY_MAX_SECONDS", "100")
+	if err := configs.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempts, want := range map[int]time.Duration{
+		1: 30 * time.Second,
+		2: 60 * time.Second,
+		3: 100 * time.Second,
+		9: 100 * time.Second,
+	} {
+		if got := notificationRetryDelay(attempts); got != want {
+			t.Errorf("notificationRetryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestRetryDelayWithoutConfiguration(t *testing.T) {
+	// Unset delays fall back to one second instead of retrying in a loop
+	if got := retryDelay(3, 0, 0); got != time.Second {
+		t.Errorf("retryDelay(3, 0, 0) = %s, want 1s", got)
+	}
+}
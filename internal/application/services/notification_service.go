@@ -58,10 +58,10 @@ func (s *NotificationService) RegisterDevice(userID int, input RegisterDeviceInp
 func (s *NotificationService) CreateNotification(userID int, title string, message string) (*entities.Notification, error) {
 	notification := &entities.Notification{
 		UserID:  userID,
-		Channel: "push",
+		Channel: entities.NotificationChannelPush,
 		Title:   title,
 		Message: message,
-		Status:  "queued",
+		Status:  entities.NotificationStatusQueued,
 	}
 
 	if err := s.db.Create(notification).Error; err != nil {
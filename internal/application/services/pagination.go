@@ -0,0 +1,35 @@
+package services
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type PageInput struct {
+	Page     int `form:"page" binding:"omitempty,min=1"`
+	PageSize int `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type Page[T any] struct {
+	Items    []T   `json:"items"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Total    int64 `json:"total"`
+}
+
+func (p PageInput) normalized() PageInput {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PageSize < 1 {
+		p.PageSize = defaultPageSize
+	}
+	if p.PageSize > maxPageSize {
+		p.PageSize = maxPageSize
+	}
+	return p
+}
+
+func (p PageInput) offset() int {
+	return (p.Page - 1) * p.PageSize
+}
@@ -0,0 +1,726 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"indicar-api/internal/infrastructure/payment"
+	"log"
+	"math"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrPaymentAccessDenied  = errors.New("unauthorized: only the requester, the evaluator or an admin can access this payment")
+	ErrPaymentNotRetryable  = errors.New("payment is not failed, canceled or expired: a new charge cannot be created")
+	ErrEvaluationNotPayable = errors.New("evaluation is completed or canceled: a new charge cannot be created")
+	ErrPaymentUnsupported   = errors.New("payment method not supported by the payment provider")
+	ErrPixCodeUnavailable   = errors.New("payment has no Pix code to be paid")
+	ErrPaymentTransition    = errors.New("invalid payment status transition")
+	ErrPaymentNotRefundable = errors.New("payment is not paid: it cannot be refunded")
+)
+
+type PaymentService struct {
+	db             *gorm.DB
+	provider       payment.Provider
+	ledgerService  *LedgerService
+	receiptService *ReceiptService
+}
+
+func NewPaymentService(db *gorm.DB, provider payment.Provider, ledgerService *LedgerService, receiptService *ReceiptService) *PaymentService {
+	return &PaymentService{
+		db:             db,
+		provider:       provider,
+		ledgerService:  ledgerService,
+		receiptService: receiptService,
+	}
+}
+
+type RetryPaymentInput struct {
+	// Defaults to the method of the previous attempt
+	Method string `json:"method" binding:"omitempty,oneof=card pix"`
+}
+
+type RefundPaymentInput struct {
+	// Defaults to the whole amount still refundable
+	AmountCents int `json:"amount_cents" binding:"omitempty,min=1"`
+	// Defaults to requested_by_customer
+	Reason string `json:"reason" binding:"omitempty,oneof=requested_by_customer evaluation_canceled service_issue duplicate fraud other"`
+	Notes  string `json:"notes" binding:"max=500"`
+}
+
+// CreateForEvaluation charges the price of an evaluation. It is called
+// outside of any transaction, so that no lock is held while the provider
+// answers; a declined charge is still recorded, as a failed payment that can
+// be retried.
+func (s *PaymentService) CreateForEvaluation(evaluation *entities.Evaluation, method entities.PaymentMethod) (*entities.Payment, error) {
+	record := &entities.Payment{
+		EvaluationID: evaluation.ID,
+		Currency:     configs.Get().Payment.Currency,
+		Attempts:     1,
+	}
+	if err := s.createCharge(evaluation, method, record); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// GetByEvaluation returns the payment of an evaluation, refreshing its status
+// from the provider while it is not settled
+func (s *PaymentService) GetByEvaluation(evaluationID int, userID int) (*entities.Payment, error) {
+	record, err := s.getAccessiblePayment(evaluationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Status == entities.PaymentStatusPending || record.Status == entities.PaymentStatusAuthorized {
+		// Best effort: the stored status is returned when the provider is unavailable
+		charge, err := s.provider.GetCharge(record.ProviderChargeID)
+		if err != nil {
+			log.Printf("failed to fetch status of payment %d: %v", record.ID, err)
+			return record, nil
+		}
+		if err := s.applyCharge(record, charge); err != nil {
+			if !errors.Is(err, ErrPaymentTransition) {
+				return nil, err
+			}
+			log.Printf("ignored status of payment %d from provider: %v", record.ID, err)
+		}
+	}
+
+	return record, nil
+}
+
+// GetPixQRCode returns a PNG QR code of the BR Code of an evaluation's Pix
+// payment, while it is waiting to be paid
+func (s *PaymentService) GetPixQRCode(evaluationID int, userID int) ([]byte, error) {
+	record, err := s.GetByEvaluation(evaluationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Method != entities.PaymentMethodPix || record.PixPayload == nil ||
+		record.Status != entities.PaymentStatusPending {
+		return nil, ErrPixCodeUnavailable
+	}
+
+	return qrcode.Encode(*record.PixPayload, qrcode.Medium, 256)
+}
+
+// ConfirmCharge fetches a charge from the provider and applies its status to
+// the payment it belongs to. It is how provider notifications reach payments.
+func (s *PaymentService) ConfirmCharge(chargeID string) (*entities.Payment, error) {
+	var record entities.Payment
+	if err := s.db.Where("provider = ? AND provider_charge_id = ?", s.provider.Name(), chargeID).
+		First(&record).Error; err != nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	charge, err := s.provider.GetCharge(chargeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyCharge(&record, charge); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// SimulatePixPayment pays a Pix charge of the fake provider, standing in for
+// the customer and the PSP notification during development
+func (s *PaymentService) SimulatePixPayment(chargeID string) (*entities.Payment, error) {
+	fake, ok := s.provider.(*payment.FakeProvider)
+	if !ok {
+		return nil, ErrPaymentUnsupported
+	}
+
+	if _, err := fake.PayPix(chargeID); err != nil {
+		if errors.Is(err, payment.ErrChargeNotFound) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+
+	return s.ConfirmCharge(chargeID)
+}
+
+// Retry creates a new charge for an evaluation whose payment failed, was
+// canceled or expired. Only the requester can retry.
+func (s *PaymentService) Retry(evaluationID int, userID int, input RetryPaymentInput) (*entities.Payment, error) {
+	var evaluation entities.Evaluation
+	if err := s.db.First(&evaluation, evaluationID).Error; err != nil {
+		return nil, fmt.Errorf("%w: evaluation not found", ErrPaymentNotFound)
+	}
+
+	if evaluation.RequesterID != userID {
+		return nil, ErrPaymentAccessDenied
+	}
+
+	// Canceled evaluations had their charge voided and completed ones are over
+	switch evaluation.Status {
+	case entities.EvaluationStatusCreated, entities.EvaluationStatusAccepted, entities.EvaluationStatusInProgress:
+	default:
+		return nil, ErrEvaluationNotPayable
+	}
+
+	var record entities.Payment
+	if err := s.db.Where("evaluation_id = ?", evaluationID).First(&record).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return s.CreateForEvaluation(&evaluation, paymentMethodOrDefault(input.Method, entities.PaymentMethodCard))
+	}
+
+	// Pix codes past their expiration may not have been refreshed yet
+	if record.Status == entities.PaymentStatusPending && record.ExpiresAt != nil && record.ExpiresAt.Before(time.Now()) {
+		if charge, err := s.provider.GetCharge(record.ProviderChargeID); err == nil {
+			if err := s.applyCharge(&record, charge); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch record.Status {
+	case entities.PaymentStatusFailed, entities.PaymentStatusCanceled, entities.PaymentStatusExpired:
+	default:
+		return nil, ErrPaymentNotRetryable
+	}
+
+	record.Attempts++
+	if err := s.createCharge(&evaluation, paymentMethodOrDefault(input.Method, record.Method), &record); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&record).
+		Select("provider", "provider_charge_id", "amount_cents", "method", "status", "attempts",
+			"pix_tx_id", "pix_payload", "expires_at", "paid_at").
+		Updates(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Capture settles an authorized payment (admin only)
+func (s *PaymentService) Capture(evaluationID int, userID int) (*entities.Payment, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	record, err := s.getPayment(evaluationID)
+	if err != nil {
+		return nil, err
+	}
+	if record.AmountCents == 0 {
+		return nil, payment.ErrInvalidState
+	}
+
+	charge, err := s.provider.Capture(record.ProviderChargeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyCharge(record, charge); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Refund returns all or part of a paid payment to the requester (admin only)
+func (s *PaymentService) Refund(evaluationID int, userID int, input RefundPaymentInput) (*entities.Refund, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	record, err := s.getPayment(evaluationID)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &entities.Refund{
+		AmountCents: input.AmountCents,
+		Reason:      entities.RefundReasonRequestedByCustomer,
+		Source:      entities.RefundSourceAdmin,
+		CreatedByID: &userID,
+	}
+	if input.Reason != "" {
+		refund.Reason = entities.RefundReason(input.Reason)
+	}
+	if input.Notes != "" {
+		refund.Notes = &input.Notes
+	}
+
+	if err := s.refund(record, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// RefundForCancellation applies the cancellation policy to the payment of a
+// canceled evaluation. Charges not captured yet are voided, whatever the
+// evaluation's progress; what is left of paid ones is refunded, less
+// PAYMENT_LATE_CANCELLATION_FEE_PERCENT of the amount when the evaluation had
+// already started.
+func (s *PaymentService) RefundForCancellation(evaluationID int, started bool) (*entities.Refund, error) {
+	record, err := s.getPayment(evaluationID)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Status == entities.PaymentStatusAuthorized || record.Status == entities.PaymentStatusPending {
+		charge, err := s.provider.Cancel(record.ProviderChargeID)
+		if errors.Is(err, payment.ErrInvalidState) {
+			// Captured or settled meanwhile: refunded below if it was paid
+			charge, err = s.provider.GetCharge(record.ProviderChargeID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to cancel charge: %w", err)
+		}
+		if err := s.applyCharge(record, charge); err != nil {
+			return nil, err
+		}
+	}
+
+	if record.AmountCents == 0 || (record.Status != entities.PaymentStatusPaid &&
+		record.Status != entities.PaymentStatusPartiallyRefunded) {
+		return nil, nil
+	}
+
+	// Refunds already made or waiting for the provider count towards what
+	// is returned
+	pending, err := pendingRefundCents(s.db, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	amount := record.AmountCents - record.RefundedCents - pending
+	if started {
+		percent := configs.Get().Payment.LateCancellationFeePercent
+		fee := int(math.Round(float64(record.AmountCents) * float64(percent) / 100))
+		amount = min(amount, record.AmountCents-fee-record.RefundedCents-pending)
+	}
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	refund := &entities.Refund{
+		AmountCents: amount,
+		Reason:      entities.RefundReasonEvaluationCanceled,
+		Source:      entities.RefundSourcePolicy,
+	}
+	if err := s.refund(record, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// ListRefunds returns the refunds of an evaluation's payment, oldest first
+func (s *PaymentService) ListRefunds(evaluationID int, userID int) ([]entities.Refund, error) {
+	record, err := s.getAccessiblePayment(evaluationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds := make([]entities.Refund, 0)
+	if err := s.db.Where("payment_id = ?", record.ID).Order("id").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// refund returns refund.AmountCents of a payment to the customer, or all that
+// is left of it when zero. The refund is recorded pending, with the payment
+// locked, before the provider is called, so that concurrent refunds only see
+// what the pending ones leave. It is failed if the provider refuses it and
+// settled with the payment locked again otherwise.
+func (s *PaymentService) refund(record *entities.Payment, refund *entities.Refund) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPayment(tx, record); err != nil {
+			return err
+		}
+		if record.AmountCents == 0 {
+			return payment.ErrInvalidState
+		}
+		if record.Status != entities.PaymentStatusPaid && record.Status != entities.PaymentStatusPartiallyRefunded {
+			return ErrPaymentNotRefundable
+		}
+
+		pending, err := pendingRefundCents(tx, record.ID)
+		if err != nil {
+			return err
+		}
+		remaining := record.AmountCents - record.RefundedCents - pending
+		if refund.AmountCents == 0 {
+			refund.AmountCents = remaining
+		}
+		if refund.AmountCents <= 0 || refund.AmountCents > remaining {
+			return payment.ErrInvalidAmount
+		}
+
+		refund.PaymentID = record.ID
+		refund.Status = entities.RefundStatusPending
+		return tx.Create(refund).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.provider.Refund(record.ProviderChargeID, refund.AmountCents); err != nil {
+		message := err.Error()
+		refund.Status = entities.RefundStatusFailed
+		refund.Error = &message
+		if err := s.db.Model(refund).Select("status", "error").Updates(refund).Error; err != nil {
+			log.Printf("failed to record failure of refund %d: %v", refund.ID, err)
+		}
+		return err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPayment(tx, record); err != nil {
+			return err
+		}
+		return s.settleRefund(tx, record, refund)
+	}); err != nil {
+		return err
+	}
+
+	// The receipt shows refunds, and a full refund cancels the invoice
+	s.receiptService.Enqueue(record.ID)
+	return nil
+}
+
+// settleRefund marks a refund as succeeded and applies it to its payment,
+// locked by the caller, and to the evaluator's earning
+func (s *PaymentService) settleRefund(tx *gorm.DB, record *entities.Payment, refund *entities.Refund) error {
+	refund.Status = entities.RefundStatusSucceeded
+	refund.Error = nil
+	if err := tx.Model(refund).Select("status", "error").Updates(refund).Error; err != nil {
+		return err
+	}
+
+	record.RefundedCents += refund.AmountCents
+	if err := tx.Model(&entities.Payment{}).
+		Where("id = ?", record.ID).
+		Update("refunded_cents", record.RefundedCents).Error; err != nil {
+		return err
+	}
+
+	// The evaluator gives back their share of what was refunded
+	if err := s.ledgerService.RecordRefund(tx, record.EvaluationID, refund.ID, refund.AmountCents); err != nil {
+		return err
+	}
+
+	status := entities.PaymentStatusPartiallyRefunded
+	if record.RefundedCents >= record.AmountCents {
+		status = entities.PaymentStatusRefunded
+	}
+	return s.transition(tx, record, status)
+}
+
+// createCharge creates the charge of the attempt in record with the given
+// method and stores it in record
+func (s *PaymentService) createCharge(evaluation *entities.Evaluation, method entities.PaymentMethod, record *entities.Payment) error {
+	paymentConfig := configs.Get().Payment
+	// Evaluations are charged the price they were quoted; older ones, from
+	// before pricing rules, the flat price
+	amount := paymentConfig.EvaluationPriceCents
+	if evaluation.PriceCents != nil {
+		amount = *evaluation.PriceCents
+	}
+
+	// A new reference per attempt, so that retries create new charges while
+	// repeated calls for the same attempt do not
+	input := payment.ChargeInput{
+		Reference:   chargeReference(evaluation.ID, record.Attempts),
+		AmountCents: amount,
+		Currency:    paymentConfig.Currency,
+		Description: fmt.Sprintf("Avaliação veicular #%d", evaluation.ID),
+	}
+
+	// Fully discounted evaluations have nothing to charge
+	if amount == 0 {
+		now := time.Now()
+		record.Provider = s.provider.Name()
+		record.ProviderChargeID = "free_" + input.Reference
+		record.AmountCents = 0
+		record.Method = method
+		record.Status = entities.PaymentStatusPaid
+		record.PaidAt = &now
+		record.PixTxID, record.PixPayload, record.ExpiresAt = nil, nil, nil
+		return nil
+	}
+
+	var charge *payment.Charge
+	var code *payment.PixCode
+	var err error
+	switch method {
+	case entities.PaymentMethodPix:
+		pixProvider, ok := s.provider.(payment.PixProvider)
+		if !ok {
+			return ErrPaymentUnsupported
+		}
+		expiresIn := time.Duration(paymentConfig.PixExpirationMinutes) * time.Minute
+		charge, code, err = pixProvider.CreatePixCharge(input, expiresIn)
+	default:
+		charge, err = s.provider.CreateCharge(input)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create charge: %w", err)
+	}
+
+	record.Provider = s.provider.Name()
+	record.ProviderChargeID = charge.ID
+	record.AmountCents = charge.AmountCents
+	record.Method = method
+	record.Status = paymentStatusFor(charge.Status)
+	record.PixTxID, record.PixPayload, record.ExpiresAt = nil, nil, nil
+	if code != nil {
+		record.PixTxID = &code.TxID
+		record.PixPayload = &code.Payload
+		record.ExpiresAt = &code.ExpiresAt
+	}
+
+	return nil
+}
+
+func (s *PaymentService) applyCharge(record *entities.Payment, charge *payment.Charge) error {
+	return s.applyProviderState(record, paymentStatusFor(charge.Status), charge.RefundedCents)
+}
+
+// applyProviderState brings a payment to the state of its charge reported by
+// the provider. Refunds made outside the API, e.g. on the provider's
+// dashboard, are recorded for the difference between what the provider
+// refunded and the refunds known here, and refund statuses follow from the
+// recorded refunds rather than from the provider.
+func (s *PaymentService) applyProviderState(record *entities.Payment, status entities.PaymentStatus, refundedCents int) error {
+	var wasPaid bool
+	var refundedBefore int
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPayment(tx, record); err != nil {
+			return err
+		}
+		wasPaid = record.PaidAt != nil
+		refundedBefore = record.RefundedCents
+
+		if status == entities.PaymentStatusRefunded && refundedCents == 0 {
+			refundedCents = record.AmountCents
+		}
+
+		if record.Status == entities.PaymentStatusPaid || record.Status == entities.PaymentStatusPartiallyRefunded {
+			// Refunds still waiting for the provider are already counted
+			pending, err := pendingRefundCents(tx, record.ID)
+			if err != nil {
+				return err
+			}
+
+			missing := min(refundedCents-record.RefundedCents-pending, record.AmountCents-record.RefundedCents)
+			if missing > 0 {
+				refund := &entities.Refund{
+					PaymentID:   record.ID,
+					AmountCents: missing,
+					Reason:      entities.RefundReasonOther,
+					Source:      entities.RefundSourceProvider,
+					Status:      entities.RefundStatusPending,
+				}
+				if err := tx.Create(refund).Error; err != nil {
+					return err
+				}
+				if err := s.settleRefund(tx, record, refund); err != nil {
+					return err
+				}
+			}
+		}
+
+		switch status {
+		case entities.PaymentStatusRefunded, entities.PaymentStatusPartiallyRefunded:
+			return nil
+		case entities.PaymentStatusPaid:
+			// A dispute was won: refunds made before it still count
+			if record.RefundedCents > 0 {
+				status = entities.PaymentStatusPartiallyRefunded
+			}
+		}
+		return s.transition(tx, record, status)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The receipt is generated once the capture is committed, and updated
+	// with refunds
+	if (!wasPaid && record.PaidAt != nil) || record.RefundedCents != refundedBefore {
+		s.receiptService.Enqueue(record.ID)
+	}
+	return nil
+}
+
+// transition moves a payment to a new status, following the payment state
+// machine. It credits the evaluator of an evaluation completed before the
+// capture, and holds their earning while the charge is disputed.
+// Moving to the current status again is not an error, so that notifications
+// can be applied more than once.
+func (s *PaymentService) transition(tx *gorm.DB, record *entities.Payment, status entities.PaymentStatus) error {
+	if status == record.Status {
+		return nil
+	}
+	if !isValidPaymentStatusTransition(record.Status, status) {
+		return fmt.Errorf("%w: %s to %s", ErrPaymentTransition, record.Status, status)
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status == entities.PaymentStatusPaid && record.PaidAt == nil {
+		now := time.Now()
+		updates["paid_at"] = now
+		record.PaidAt = &now
+	}
+
+	// The status is checked again so that concurrent notifications cannot
+	// move the payment back
+	result := tx.Model(&entities.Payment{}).
+		Where("id = ? AND status = ?", record.ID, record.Status).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: payment %d changed concurrently", ErrPaymentTransition, record.ID)
+	}
+
+	previous := record.Status
+	record.Status = status
+
+	switch {
+	case status == entities.PaymentStatusPaid && (previous == entities.PaymentStatusPending || previous == entities.PaymentStatusAuthorized):
+		// Evaluations completed before the capture are credited now
+		var evaluation entities.Evaluation
+		if err := tx.First(&evaluation, record.EvaluationID).Error; err != nil {
+			return err
+		}
+		if evaluation.Status != entities.EvaluationStatusCompleted {
+			return nil
+		}
+		return s.ledgerService.RecordEarning(tx, &evaluation)
+	case status == entities.PaymentStatusDisputed:
+		return s.ledgerService.HoldEarning(tx, record.EvaluationID)
+	case status == entities.PaymentStatusChargedBack:
+		return s.ledgerService.RecordChargeback(tx, record.EvaluationID, record.AmountCents-record.RefundedCents)
+	case previous == entities.PaymentStatusDisputed:
+		return s.ledgerService.ReleaseEarning(tx, record.EvaluationID)
+	}
+	return nil
+}
+
+func (s *PaymentService) getPayment(evaluationID int) (*entities.Payment, error) {
+	var record entities.Payment
+	if err := s.db.Where("evaluation_id = ?", evaluationID).First(&record).Error; err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	return &record, nil
+}
+
+// getAccessiblePayment returns the payment of an evaluation if the user is its
+// requester, its evaluator or an admin
+func (s *PaymentService) getAccessiblePayment(evaluationID int, userID int) (*entities.Payment, error) {
+	var evaluation entities.Evaluation
+	if err := s.db.First(&evaluation, evaluationID).Error; err != nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	isEvaluator := evaluation.EvaluatorID != nil && *evaluation.EvaluatorID == userID
+	if evaluation.RequesterID != userID && !isEvaluator && !isAdmin(s.db, userID) {
+		return nil, ErrPaymentAccessDenied
+	}
+
+	return s.getPayment(evaluationID)
+}
+
+// isValidPaymentStatusTransition is the payment state machine. Failed,
+// canceled, expired, refunded and charged back payments are final: retrying
+// creates a new charge instead of reviving the old one. A won dispute returns
+// the payment to paid, or partially refunded.
+func isValidPaymentStatusTransition(current, new entities.PaymentStatus) bool {
+	switch current {
+	case entities.PaymentStatusPending:
+		return new == entities.PaymentStatusAuthorized || new == entities.PaymentStatusPaid ||
+			new == entities.PaymentStatusFailed || new == entities.PaymentStatusCanceled ||
+			new == entities.PaymentStatusExpired
+	case entities.PaymentStatusAuthorized:
+		return new == entities.PaymentStatusPaid || new == entities.PaymentStatusFailed ||
+			new == entities.PaymentStatusCanceled
+	case entities.PaymentStatusPaid:
+		return new == entities.PaymentStatusPartiallyRefunded || new == entities.PaymentStatusRefunded ||
+			new == entities.PaymentStatusDisputed
+	case entities.PaymentStatusPartiallyRefunded:
+		return new == entities.PaymentStatusRefunded || new == entities.PaymentStatusDisputed
+	case entities.PaymentStatusDisputed:
+		return new == entities.PaymentStatusPaid || new == entities.PaymentStatusPartiallyRefunded ||
+			new == entities.PaymentStatusChargedBack
+	default:
+		return false
+	}
+}
+
+func paymentStatusFor(status payment.ChargeStatus) entities.PaymentStatus {
+	switch status {
+	case payment.ChargeStatusAuthorized:
+		return entities.PaymentStatusAuthorized
+	case payment.ChargeStatusPaid:
+		return entities.PaymentStatusPaid
+	case payment.ChargeStatusFailed:
+		return entities.PaymentStatusFailed
+	case payment.ChargeStatusCanceled:
+		return entities.PaymentStatusCanceled
+	case payment.ChargeStatusRefunded:
+		return entities.PaymentStatusRefunded
+	case payment.ChargeStatusExpired:
+		return entities.PaymentStatusExpired
+	case payment.ChargeStatusDisputed:
+		return entities.PaymentStatusDisputed
+	case payment.ChargeStatusChargedBack:
+		return entities.PaymentStatusChargedBack
+	default:
+		return entities.PaymentStatusPending
+	}
+}
+
+// pendingRefundCents sums the refunds of a payment still waiting for the
+// provider
+func pendingRefundCents(tx *gorm.DB, paymentID int) (int, error) {
+	var pending int
+	err := tx.Model(&entities.Refund{}).
+		Where("payment_id = ? AND status = ?", paymentID, entities.RefundStatusPending).
+		Select("COALESCE(SUM(amount_cents), 0)").
+		Scan(&pending).Error
+	return pending, err
+}
+
+// lockPayment reloads a payment, locking it until the end of tx
+func lockPayment(tx *gorm.DB, record *entities.Payment) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(record, record.ID).Error
+}
+
+func paymentMethodOrDefault(method string, fallback entities.PaymentMethod) entities.PaymentMethod {
+	if method == "" {
+		return fallback
+	}
+	return entities.PaymentMethod(method)
+}
+
+// chargeReference identifies an attempt to charge an evaluation
+func chargeReference(evaluationID int, attempt int) string {
+	return fmt.Sprintf("evaluation-%d-%d", evaluationID, attempt)
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"indicar-api/internal/domain/entities"
+	"testing"
+)
+
+func TestPaymentStatusTransitions(t *testing.T) {
+	statuses := []entities.PaymentStatus{
+		entities.PaymentStatusPending,
+		entities.PaymentStatusAuthorized,
+		entities.PaymentStatusPaid,
+		entities.PaymentStatusFailed,
+		entities.PaymentStatusCanceled,
+		entities.PaymentStatusExpired,
+		entities.PaymentStatusPartiallyRefunded,
+		entities.PaymentStatusRefunded,
+		entities.PaymentStatusDisputed,
+		entities.PaymentStatusChargedBack,
+	}
+
+	allowed := map[entities.PaymentStatus][]entities.PaymentStatus{
+		entities.PaymentStatusPending: {
+			entities.PaymentStatusAuthorized, entities.PaymentStatusPaid, entities.PaymentStatusFailed,
+			entities.PaymentStatusCanceled, entities.PaymentStatusExpired,
+		},
+		entities.PaymentStatusAuthorized: {
+			entities.PaymentStatusPaid, entities.PaymentStatusFailed, entities.PaymentStatusCanceled,
+		},
+		entities.PaymentStatusPaid: {
+			entities.PaymentStatusPartiallyRefunded, entities.PaymentStatusRefunded, entities.PaymentStatusDisputed,
+		},
+		entities.PaymentStatusPartiallyRefunded: {
+			entities.PaymentStatusRefunded, entities.PaymentStatusDisputed,
+		},
+		entities.PaymentStatusDisputed: {
+			entities.PaymentStatusPaid, entities.PaymentStatusPartiallyRefunded, entities.PaymentStatusChargedBack,
+		},
+	}
+
+	for _, current := range statuses {
+		for _, next := range statuses {
+			want := false
+			for _, status := range allowed[current] {
+				want = want || status == next
+			}
+			if got := isValidPaymentStatusTransition(current, next); got != want {
+				t.Errorf("%s -> %s allowed = %v, want %v", current, next, got, want)
+			}
+		}
+	}
+}
+
+func TestChargeReferenceIsDeterministicPerAttempt(t *testing.T) {
+	if chargeReference(42, 1) != chargeReference(42, 1) {
+		t.Error("the same attempt has different references")
+	}
+	if chargeReference(42, 1) == chargeReference(42, 2) {
+		t.Error("retries share the reference of the first attempt")
+	}
+}
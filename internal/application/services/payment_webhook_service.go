@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"indicar-api/internal/infrastructure/payment"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWebhookProviderUnknown = errors.New("payment provider does not accept webhooks here")
+	ErrWebhookProcessing      = errors.New("failed to process payment event")
+)
+
+// PaymentWebhookService ingests the webhook calls of the payment provider.
+// Every verified call is stored raw; events are deduplicated by the
+// provider's event ID and applied to payments through the payment state
+// machine.
+type PaymentWebhookService struct {
+	db             *gorm.DB
+	paymentService *PaymentService
+}
+
+func NewPaymentWebhookService(db *gorm.DB, paymentService *PaymentService) *PaymentWebhookService {
+	return &PaymentWebhookService{
+		db:             db,
+		paymentService: paymentService,
+	}
+}
+
+// Receive verifies, stores and applies a webhook call. Calls with an invalid
+// signature are only logged: anyone can make them, so storing them would let
+// anyone fill the table. An event that was already processed is returned as
+// is, with duplicate set; one whose processing failed before is processed
+// again, so that provider retries go through.
+func (s *PaymentWebhookService) Receive(providerName string, header http.Header, body []byte) (*entities.PaymentEvent, bool, error) {
+	provider, err := s.webhookProvider(providerName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := provider.VerifyWebhook(header, body, webhookSecret(providerName)); err != nil {
+		log.Printf("rejected %s payment webhook (%d bytes): %v", providerName, len(body), err)
+		return nil, false, err
+	}
+
+	event := &entities.PaymentEvent{
+		Provider: providerName,
+		Payload:  string(body),
+		Status:   entities.PaymentEventStatusReceived,
+	}
+
+	parsed, err := provider.ParseWebhook(body)
+	if err != nil {
+		s.storeUnprocessed(event, entities.PaymentEventStatusFailed, err)
+		return nil, false, err
+	}
+
+	event.EventID = &parsed.ID
+	event.EventType = &parsed.Type
+	event.ChargeID = &parsed.ChargeID
+
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	duplicate := result.RowsAffected == 0
+	if duplicate {
+		if err := s.db.Where("provider = ? AND event_id = ?", providerName, parsed.ID).
+			First(event).Error; err != nil {
+			return nil, false, err
+		}
+		if event.Status != entities.PaymentEventStatusReceived && event.Status != entities.PaymentEventStatusFailed {
+			return event, true, nil
+		}
+	}
+
+	if err := s.process(event, parsed); err != nil {
+		return event, duplicate, err
+	}
+	return event, duplicate, nil
+}
+
+// Replay applies again the stored events of the current provider that were
+// not processed, oldest first. It returns how many were processed.
+func (s *PaymentWebhookService) Replay() (int, error) {
+	providerName := s.paymentService.provider.Name()
+	provider, err := s.webhookProvider(providerName)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []entities.PaymentEvent
+	if err := s.db.Where("provider = ? AND status IN ?", providerName, []entities.PaymentEventStatus{
+		entities.PaymentEventStatusReceived,
+		entities.PaymentEventStatusFailed,
+	}).
+		Where("event_id IS NOT NULL").
+		Order("id").
+		Find(&events).Error; err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range events {
+		event := &events[i]
+
+		parsed, err := provider.ParseWebhook([]byte(event.Payload))
+		if err != nil {
+			log.Printf("failed to parse payment event %d: %v", event.ID, err)
+			continue
+		}
+
+		if err := s.process(event, parsed); err != nil {
+			log.Printf("failed to replay payment event %d: %v", event.ID, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// process applies an event to its payment and records the outcome. Events
+// for unknown charges or with transitions the state machine refuses are
+// ignored; only unexpected errors leave the event failed.
+func (s *PaymentWebhookService) process(event *entities.PaymentEvent, parsed *payment.WebhookEvent) error {
+	status := entities.PaymentEventStatusProcessed
+	var processErr error
+
+	var record entities.Payment
+	err := s.db.Where("provider = ? AND provider_charge_id = ?", event.Provider, parsed.ChargeID).
+		First(&record).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status, processErr = entities.PaymentEventStatusIgnored, ErrPaymentNotFound
+	case err != nil:
+		status, processErr = entities.PaymentEventStatusFailed, err
+	default:
+		event.PaymentID = &record.ID
+		processErr = s.paymentService.applyProviderState(&record, paymentStatusFor(parsed.Status), parsed.RefundedCents)
+		if errors.Is(processErr, ErrPaymentTransition) {
+			status = entities.PaymentEventStatusIgnored
+		} else if processErr != nil {
+			status = entities.PaymentEventStatusFailed
+		}
+	}
+
+	now := time.Now()
+	event.Status = status
+	event.ProcessedAt = &now
+	event.Error = nil
+	if processErr != nil {
+		message := processErr.Error()
+		event.Error = &message
+	}
+
+	if err := s.db.Model(event).
+		Select("payment_id", "status", "error", "processed_at").
+		Updates(event).Error; err != nil {
+		return err
+	}
+
+	if status == entities.PaymentEventStatusFailed {
+		return fmt.Errorf("%w: %v", ErrWebhookProcessing, processErr)
+	}
+	return nil
+}
+
+// storeUnprocessed keeps verified calls that could not be read, for audit
+func (s *PaymentWebhookService) storeUnprocessed(event *entities.PaymentEvent, status entities.PaymentEventStatus, cause error) {
+	message := cause.Error()
+	event.Status = status
+	event.Error = &message
+
+	if err := s.db.Create(event).Error; err != nil {
+		log.Printf("failed to store %s payment event: %v", status, err)
+	}
+}
+
+// webhookSecret returns the secret shared with a provider to sign its
+// webhook calls, empty for providers without one
+func webhookSecret(providerName string) string {
+	switch providerName {
+	case "fake":
+		return configs.Get().Payment.FakeWebhookSecret
+	default:
+		return ""
+	}
+}
+
+func (s *PaymentWebhookService) webhookProvider(providerName string) (payment.WebhookProvider, error) {
+	if providerName != s.paymentService.provider.Name() {
+		return nil, ErrWebhookProviderUnknown
+	}
+
+	provider, ok := s.paymentService.provider.(payment.WebhookProvider)
+	if !ok {
+		return nil, ErrWebhookProviderUnknown
+	}
+	return provider, nil
+}
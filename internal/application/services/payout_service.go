@@ -0,0 +1,296 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"indicar-api/internal/infrastructure/payment"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const payoutCheckInterval = time.Hour
+
+var (
+	ErrPayoutBatchNotFound     = errors.New("payout batch not found")
+	ErrPayoutBankNotConfigured = errors.New("the bank account of payouts is not configured")
+)
+
+// PayoutService transfers evaluator balances in batches, created on the
+// configured schedule or on demand by admins, and exported for the team that
+// makes the transfers
+type PayoutService struct {
+	db            *gorm.DB
+	ledgerService *LedgerService
+}
+
+func NewPayoutService(db *gorm.DB, ledgerService *LedgerService) *PayoutService {
+	return &PayoutService{
+		db:            db,
+		ledgerService: ledgerService,
+	}
+}
+
+// Start runs the background worker creating scheduled batches
+func (s *PayoutService) Start() {
+	go s.run()
+}
+
+func (s *PayoutService) run() {
+	ticker := time.NewTicker(payoutCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.createScheduledBatch(); err != nil {
+			log.Printf("failed to create payout batch: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (s *PayoutService) createScheduledBatch() error {
+	payoutConfig := configs.Get().Payout
+	period, due := payoutPeriod(time.Now(), payoutConfig.Schedule, payoutConfig.Day)
+	if !due {
+		return nil
+	}
+
+	var existing int64
+	if err := s.db.Model(&entities.PayoutBatch{}).Where("period = ?", period).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	batch, err := s.createBatch(&period)
+	if err != nil {
+		return err
+	}
+	if batch != nil {
+		log.Printf("payout batch %d created with %d payouts", batch.ID, batch.PayoutCount)
+	}
+	return nil
+}
+
+// CreateBatch pays out now every balance above the minimum (admin only)
+func (s *PayoutService) CreateBatch(userID int) (*entities.PayoutBatch, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+	return s.createBatch(nil)
+}
+
+// createBatch creates a batch with one payout per evaluator whose balance
+// reaches the minimum amount. Evaluators without a Pix key are left out until
+// they set one. A batch is created even when empty, marking the schedule as
+// done.
+//
+// Balances are read with a locking read, so concurrent batches, from the
+// schedule of another instance or an admin, wait for each other and never pay
+// the same balance twice. Scheduled batches are unique per period: nil is
+// returned when the period's batch already exists.
+func (s *PayoutService) createBatch(period *string) (*entities.PayoutBatch, error) {
+	type payableBalance struct {
+		UserID       int
+		BalanceCents int
+		FullName     string
+		DocumentID   *string
+		PixKey       string
+	}
+
+	batch := &entities.PayoutBatch{Status: entities.PayoutBatchStatusCreated, Period: period}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(batch)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			batch = nil
+			return nil
+		}
+
+		var balances []payableBalance
+		if err := tx.Table("ledger_entries AS e").
+			Select("e.user_id, SUM(e.amount_cents) AS balance_cents, u.full_name, ev.document_id, ev.pix_key").
+			Joins("JOIN users AS u ON u.id = e.user_id").
+			Joins("JOIN evaluators AS ev ON ev.user_id = e.user_id").
+			Where("e.account = ? AND ev.pix_key IS NOT NULL AND ev.pix_key <> ''", entities.LedgerAccountEvaluatorPayable).
+			Group("e.user_id, u.full_name, ev.document_id, ev.pix_key").
+			Having("SUM(e.amount_cents) >= ?", max(configs.Get().Payout.MinAmountCents, 1)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scan(&balances).Error; err != nil {
+			return err
+		}
+
+		for _, balance := range balances {
+			payout := entities.Payout{
+				BatchID:       batch.ID,
+				EvaluatorID:   balance.UserID,
+				AmountCents:   balance.BalanceCents,
+				RecipientName: balance.FullName,
+				DocumentID:    balance.DocumentID,
+				PixKey:        balance.PixKey,
+			}
+			if err := tx.Create(&payout).Error; err != nil {
+				return err
+			}
+
+			evaluatorID := balance.UserID
+			if err := s.ledgerService.record(tx, &entities.LedgerTransaction{
+				Kind:        entities.LedgerTransactionKindPayout,
+				Reference:   fmt.Sprintf("payout:%d", payout.ID),
+				Description: fmt.Sprintf("Repasse do lote #%d", batch.ID),
+				PayoutID:    &payout.ID,
+			}, []entities.LedgerEntry{
+				{Account: entities.LedgerAccountEvaluatorPayable, UserID: &evaluatorID, AmountCents: -payout.AmountCents},
+				{Account: entities.LedgerAccountPayouts, AmountCents: payout.AmountCents},
+			}); err != nil {
+				return err
+			}
+
+			batch.TotalCents += payout.AmountCents
+			batch.PayoutCount++
+		}
+
+		return tx.Model(batch).Updates(map[string]interface{}{
+			"total_cents":  batch.TotalCents,
+			"payout_count": batch.PayoutCount,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// ListBatches returns payout batches, most recent first (admin only)
+func (s *PayoutService) ListBatches(userID int, input PageInput) (*Page[entities.PayoutBatch], error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	page := input.normalized()
+	query := s.db.Model(&entities.PayoutBatch{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	batches := make([]entities.PayoutBatch, 0)
+	if err := query.Order("id DESC").
+		Offset(page.offset()).
+		Limit(page.PageSize).
+		Find(&batches).Error; err != nil {
+		return nil, err
+	}
+
+	return &Page[entities.PayoutBatch]{
+		Items:    batches,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		Total:    total,
+	}, nil
+}
+
+// GetBatch returns a payout batch with its payouts (admin only)
+func (s *PayoutService) GetBatch(userID int, batchID int) (*entities.PayoutBatch, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	var batch entities.PayoutBatch
+	if err := s.db.Preload("Payouts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&batch, batchID).Error; err != nil {
+		return nil, ErrPayoutBatchNotFound
+	}
+	return &batch, nil
+}
+
+// ExportBatch returns the transfers of a batch as a CNAB 240 remittance file
+// paying each evaluator by Pix, to be sent to the bank, and marks the batch as
+// exported (admin only). The file number is the batch ID, so banks reject a
+// batch sent twice.
+func (s *PayoutService) ExportBatch(userID int, batchID int) ([]byte, error) {
+	batch, err := s.GetBatch(userID, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	payoutConfig := configs.Get().Payout
+	if payoutConfig.BankCode == "" || payoutConfig.BankAgency == "" || payoutConfig.BankAccount == "" {
+		return nil, ErrPayoutBankNotConfigured
+	}
+
+	now := time.Now().In(businessLocation)
+	remittance := payment.CNAB240Remittance{
+		CompanyName:     configs.Get().Receipt.CompanyName,
+		CompanyDocument: configs.Get().Receipt.CompanyDocument,
+		BankCode:        payoutConfig.BankCode,
+		BankName:        payoutConfig.BankName,
+		Agreement:       payoutConfig.BankAgreement,
+		Agency:          payoutConfig.BankAgency,
+		AgencyDigit:     payoutConfig.BankAgencyDigit,
+		Account:         payoutConfig.BankAccount,
+		AccountDigit:    payoutConfig.BankAccountDigit,
+		Sequence:        batch.ID,
+		CreatedAt:       now,
+		PaymentDate:     now,
+	}
+	for _, payout := range batch.Payouts {
+		transfer := payment.PixTransfer{
+			RecipientName: payout.RecipientName,
+			PixKey:        payout.PixKey,
+			AmountCents:   payout.AmountCents,
+			Reference:     fmt.Sprintf("REPASSE-%d", payout.ID),
+		}
+		if payout.DocumentID != nil {
+			transfer.RecipientDocument = *payout.DocumentID
+		}
+		remittance.Transfers = append(remittance.Transfers, transfer)
+	}
+
+	content, err := remittance.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	if batch.Status != entities.PayoutBatchStatusExported {
+		if err := s.db.Model(batch).Updates(map[string]interface{}{
+			"status":      entities.PayoutBatchStatusExported,
+			"exported_at": time.Now(),
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return content, nil
+}
+
+// payoutPeriod tells whether a scheduled batch is due on the day of now, in
+// the business timezone, and returns the period it pays, which identifies the
+// batch
+func payoutPeriod(now time.Time, schedule string, day int) (string, bool) {
+	now = now.In(businessLocation)
+	date := now.Format("2006-01-02")
+
+	switch schedule {
+	case "daily":
+		return "daily:" + date, true
+	case "weekly":
+		return "weekly:" + date, int(now.Weekday()) == day
+	case "monthly":
+		// Months shorter than the configured day pay on their last day
+		lastDay := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, businessLocation).Day()
+		return "monthly:" + now.Format("2006-01"), now.Day() == min(day, lastDay)
+	default:
+		return "", false
+	}
+}
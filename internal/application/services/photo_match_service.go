@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"indicar-api/internal/domain/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAdminRequired      = errors.New("admin access required")
+	ErrPhotoMatchNotFound = errors.New("photo match not found")
+)
+
+// PhotoMatchService lets admins review photos flagged as reused across
+// evaluations
+type PhotoMatchService struct {
+	db           *gorm.DB
+	photoService *EvaluationPhotoService
+}
+
+func NewPhotoMatchService(db *gorm.DB, photoService *EvaluationPhotoService) *PhotoMatchService {
+	return &PhotoMatchService{
+		db:           db,
+		photoService: photoService,
+	}
+}
+
+type ListPhotoMatchesInput struct {
+	PageInput
+	Status string `form:"status" binding:"omitempty,oneof=pending confirmed dismissed"`
+}
+
+type ReviewPhotoMatchInput struct {
+	Status string `json:"status" binding:"required,oneof=confirmed dismissed"`
+}
+
+// List returns flagged matches, most recent first, with download URLs for
+// both photos. It defaults to the matches still pending review.
+func (s *PhotoMatchService) List(userID int, input ListPhotoMatchesInput) (*Page[entities.PhotoMatch], error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	page := input.PageInput.normalized()
+	status := input.Status
+	if status == "" {
+		status = string(entities.PhotoMatchStatusPending)
+	}
+
+	query := s.db.Model(&entities.PhotoMatch{}).Where("status = ?", status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	matches := make([]entities.PhotoMatch, 0)
+	if err := query.Preload("Photo").
+		Preload("MatchedPhoto").
+		Order("created_at DESC").
+		Offset(page.offset()).
+		Limit(page.PageSize).
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		if err := s.photoService.presignPhoto(&matches[i].Photo); err != nil {
+			return nil, err
+		}
+		if err := s.photoService.presignPhoto(&matches[i].MatchedPhoto); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Page[entities.PhotoMatch]{
+		Items:    matches,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		Total:    total,
+	}, nil
+}
+
+// Review records an admin's decision on a flagged match
+func (s *PhotoMatchService) Review(userID int, matchID int, input ReviewPhotoMatchInput) (*entities.PhotoMatch, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	var match entities.PhotoMatch
+	if err := s.db.First(&match, matchID).Error; err != nil {
+		return nil, ErrPhotoMatchNotFound
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&match).Updates(map[string]interface{}{
+		"status":         input.Status,
+		"reviewed_by_id": userID,
+		"reviewed_at":    now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	match.Status = entities.PhotoMatchStatus(input.Status)
+	match.ReviewedByID = &userID
+	match.ReviewedAt = &now
+
+	return &match, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"indicar-api/internal/domain/entities"
+	"indicar-api/internal/infrastructure/media"
+	"indicar-api/internal/infrastructure/storage"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	photoVariantQueueSize     = 100
+	photoVariantSweepInterval = time.Minute
+	photoVariantSweepBatch    = 50
+)
+
+type photoVariant struct {
+	name    string
+	maxSize int
+	quality int
+}
+
+var (
+	thumbnailVariant = photoVariant{name: "thumbnail", maxSize: 320, quality: 75}
+	mediumVariant    = photoVariant{name: "medium", maxSize: 1280, quality: 85}
+)
+
+// PhotoVariantService generates the thumbnail and medium variants of
+// evaluation photos. Uploads enqueue their photo; a periodic sweep picks up
+// anything the queue missed, such as photos uploaded before a restart.
+type PhotoVariantService struct {
+	db    *gorm.DB
+	store storage.BlobStore
+	queue chan int
+}
+
+func NewPhotoVariantService(db *gorm.DB, store storage.BlobStore) *PhotoVariantService {
+	return &PhotoVariantService{
+		db:    db,
+		store: store,
+		queue: make(chan int, photoVariantQueueSize),
+	}
+}
+
+// Enqueue schedules variant generation without blocking the upload. When the
+// queue is full the photo stays pending until the next sweep.
+func (s *PhotoVariantService) Enqueue(photoID int) {
+	select {
+	case s.queue <- photoID:
+	default:
+	}
+}
+
+// Start runs the background worker
+func (s *PhotoVariantService) Start() {
+	go s.run()
+}
+
+func (s *PhotoVariantService) run() {
+	ticker := time.NewTicker(photoVariantSweepInterval)
+	defer ticker.Stop()
+
+	s.sweep()
+	for {
+		select {
+		case photoID := <-s.queue:
+			if err := s.Generate(photoID); err != nil {
+				log.Printf("failed to generate variants for photo %d: %v", photoID, err)
+			}
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+func (s *PhotoVariantService) sweep() {
+	var photoIDs []int
+	if err := s.db.Model(&entities.EvaluationPhoto{}).
+		Where("variants_status = ?", entities.PhotoVariantsStatusPending).
+		Order("id").
+		Limit(photoVariantSweepBatch).
+		Pluck("id", &photoIDs).Error; err != nil {
+		log.Printf("failed to list photos pending variants: %v", err)
+		return
+	}
+
+	for _, photoID := range photoIDs {
+		if err := s.Generate(photoID); err != nil {
+			log.Printf("failed to generate variants for photo %d: %v", photoID, err)
+		}
+	}
+}
+
+// Backfill generates the variants of every photo that does not have them yet,
+// including the ones whose generation failed before. It returns how many
+// photos were processed successfully.
+func (s *PhotoVariantService) Backfill() (int, error) {
+	var photoIDs []int
+	if err := s.db.Model(&entities.EvaluationPhoto{}).
+		Where("variants_status IN ?", []entities.PhotoVariantsStatus{
+			entities.PhotoVariantsStatusPending,
+			entities.PhotoVariantsStatusFailed,
+		}).
+		Order("id").
+		Pluck("id", &photoIDs).Error; err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, photoID := range photoIDs {
+		if err := s.Generate(photoID); err != nil {
+			log.Printf("failed to generate variants for photo %d: %v", photoID, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// Generate creates and stores the variants of a photo. Photos that cannot be
+// decoded (HEIC) are marked as unsupported and keep only their original.
+func (s *PhotoVariantService) Generate(photoID int) error {
+	var photo entities.EvaluationPhoto
+	if err := s.db.First(&photo, photoID).Error; err != nil {
+		return err
+	}
+
+	if photo.VariantsStatus == entities.PhotoVariantsStatusReady {
+		return nil
+	}
+
+	img, err := s.decodeOriginal(photo.S3Key)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) || errors.Is(err, media.ErrTooManyPixels) {
+			return s.setStatus(photoID, entities.PhotoVariantsStatusUnsupported)
+		}
+		s.setStatus(photoID, entities.PhotoVariantsStatusFailed)
+		return err
+	}
+
+	thumbnailKey, err := s.storeVariant(photo.S3Key, img, thumbnailVariant)
+	if err != nil {
+		s.setStatus(photoID, entities.PhotoVariantsStatusFailed)
+		return err
+	}
+
+	mediumKey, err := s.storeVariant(photo.S3Key, img, mediumVariant)
+	if err != nil {
+		s.setStatus(photoID, entities.PhotoVariantsStatusFailed)
+		return err
+	}
+
+	// The photo may have been replaced meanwhile: only record variants of the
+	// file that was processed
+	return s.db.Model(&entities.EvaluationPhoto{}).
+		Where("id = ? AND s3_key = ?", photoID, photo.S3Key).
+		Updates(map[string]interface{}{
+			"thumbnail_key":   thumbnailKey,
+			"medium_key":      mediumKey,
+			"variants_status": entities.PhotoVariantsStatusReady,
+		}).Error
+}
+
+func (s *PhotoVariantService) decodeOriginal(key string) (image.Image, error) {
+	object, _, err := s.store.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original: %w", err)
+	}
+	defer object.Close()
+
+	return media.Decode(object)
+}
+
+func (s *PhotoVariantService) storeVariant(originalKey string, img image.Image, variant photoVariant) (string, error) {
+	var buf bytes.Buffer
+	if err := media.EncodeJPEG(&buf, media.Resize(img, variant.maxSize), variant.quality); err != nil {
+		return "", fmt.Errorf("failed to encode %s: %w", variant.name, err)
+	}
+
+	key := variantKey(originalKey, variant.name)
+	if err := s.store.Put(key, &buf, "image/jpeg"); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", variant.name, err)
+	}
+
+	return key, nil
+}
+
+func (s *PhotoVariantService) setStatus(photoID int, status entities.PhotoVariantsStatus) error {
+	return s.db.Model(&entities.EvaluationPhoto{}).
+		Where("id = ?", photoID).
+		Update("variants_status", status).Error
+}
+
+// variantKey stores variants next to their original:
+// evaluations/1/photos/123.png -> evaluations/1/photos/123_thumbnail.jpg
+func variantKey(originalKey string, variant string) string {
+	base := strings.TrimSuffix(originalKey, path.Ext(originalKey))
+	return fmt.Sprintf("%s_%s.jpg", base, variant)
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPricingRuleNotFound = errors.New("pricing rule not found")
+	ErrInvalidPricingRule  = errors.New("invalid pricing rule")
+	ErrInvalidQuote        = errors.New("invalid quote request")
+)
+
+// PricingService computes evaluation prices from the pricing rules managed by
+// admins and the coupons given by customers
+type PricingService struct {
+	db            *gorm.DB
+	couponService *CouponService
+}
+
+func NewPricingService(db *gorm.DB, couponService *CouponService) *PricingService {
+	return &PricingService{
+		db:            db,
+		couponService: couponService,
+	}
+}
+
+type PriceQuoteInput struct {
+	CityID          int        `form:"city_id" json:"city_id" binding:"required"`
+	VehicleCategory string     `form:"vehicle_category" json:"vehicle_category" binding:"omitempty,oneof=car motorcycle pickup suv van truck"`
+	VehicleYear     *int       `form:"vehicle_year" json:"vehicle_year"`
+	Urgent          bool       `form:"urgent" json:"urgent"`
+	ScheduledAt     *time.Time `form:"scheduled_at" json:"scheduled_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	CouponCode      string     `form:"coupon_code" json:"coupon_code" binding:"omitempty,max=40"`
+}
+
+type ListPricingRulesInput struct {
+	PageInput
+	Kind   string `form:"kind" binding:"omitempty,oneof=base vehicle_category vehicle_year urgency time_slot"`
+	CityID *int   `form:"city_id"`
+}
+
+type PricingRuleInput struct {
+	Name            string  `json:"name" binding:"required,max=120"`
+	Kind            string  `json:"kind" binding:"required,oneof=base vehicle_category vehicle_year urgency time_slot"`
+	AmountCents     int     `json:"amount_cents"`
+	Percent         int     `json:"percent" binding:"min=-100,max=1000"`
+	Active          *bool   `json:"active"`
+	CityID          *int    `json:"city_id"`
+	VehicleCategory *string `json:"vehicle_category" binding:"omitempty,oneof=car motorcycle pickup suv van truck"`
+	MinVehicleYear  *int    `json:"min_vehicle_year" binding:"omitempty,min=1900"`
+	MaxVehicleYear  *int    `json:"max_vehicle_year" binding:"omitempty,min=1900"`
+	Weekdays        *string `json:"weekdays"`
+	StartHour       *int    `json:"start_hour" binding:"omitempty,min=0,max=23"`
+	EndHour         *int    `json:"end_hour" binding:"omitempty,min=0,max=24"`
+}
+
+// Quote computes the price of an evaluation for a user: the base price of its
+// city (or the default one), plus every active modifier whose conditions
+// match, minus the coupon discount. Time conditions are checked against the
+// scheduled time, or the current time for evaluations to be done as soon as
+// possible.
+func (s *PricingService) Quote(userID int, input PriceQuoteInput) (*entities.PriceQuote, error) {
+	var city entities.City
+	if err := s.db.Select("id").First(&city, input.CityID).Error; err != nil {
+		return nil, fmt.Errorf("%w: city not found", ErrInvalidQuote)
+	}
+
+	at := time.Now()
+	if input.ScheduledAt != nil {
+		if input.ScheduledAt.Before(at) {
+			return nil, fmt.Errorf("%w: scheduled_at must be in the future", ErrInvalidQuote)
+		}
+		at = *input.ScheduledAt
+	}
+	// Time slots are in Brasília time, whatever the server's timezone
+	at = at.In(businessLocation)
+
+	category := entities.VehicleCategoryCar
+	if input.VehicleCategory != "" {
+		category = entities.VehicleCategory(input.VehicleCategory)
+	}
+
+	var rules []entities.PricingRule
+	if err := s.db.Where("active = ? AND (city_id IS NULL OR city_id = ?)", true, input.CityID).
+		Order("id").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	paymentConfig := configs.Get().Payment
+	quote := &entities.PriceQuote{
+		BasePriceCents: paymentConfig.EvaluationPriceCents,
+		Adjustments:    make([]entities.PriceAdjustment, 0),
+		Currency:       paymentConfig.Currency,
+	}
+
+	// A city's own base price takes precedence over the default one; among
+	// equals, the most recent rule wins
+	var base *entities.PricingRule
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Kind != entities.PricingRuleKindBase {
+			continue
+		}
+		if base == nil || rule.CityID != nil || base.CityID == nil {
+			base = rule
+		}
+	}
+	if base != nil {
+		quote.BasePriceCents = base.AmountCents
+	}
+
+	total := quote.BasePriceCents
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Kind == entities.PricingRuleKindBase || !ruleMatches(rule, category, input.VehicleYear, input.Urgent, at) {
+			continue
+		}
+
+		amount := rule.AmountCents + int(math.Round(float64(quote.BasePriceCents)*float64(rule.Percent)/100))
+		quote.Adjustments = append(quote.Adjustments, entities.PriceAdjustment{
+			Kind:        string(rule.Kind),
+			RuleID:      &rule.ID,
+			Name:        rule.Name,
+			AmountCents: amount,
+		})
+		total += amount
+	}
+
+	total = max(total, 0)
+
+	if input.CouponCode != "" {
+		coupon, discount, err := s.couponService.Apply(userID, input.CouponCode, input.CityID, total)
+		if err != nil {
+			return nil, err
+		}
+
+		quote.CouponCode = &coupon.Code
+		quote.Adjustments = append(quote.Adjustments, entities.PriceAdjustment{
+			Kind:        "coupon",
+			CouponID:    &coupon.ID,
+			Name:        coupon.Code,
+			AmountCents: -discount,
+		})
+		total -= discount
+	}
+
+	quote.TotalCents = total
+	return quote, nil
+}
+
+// ListRules returns the pricing rules, optionally filtered by kind and city
+// (admin only)
+func (s *PricingService) ListRules(userID int, input ListPricingRulesInput) (*Page[entities.PricingRule], error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	page := input.PageInput.normalized()
+	query := s.db.Model(&entities.PricingRule{})
+	if input.Kind != "" {
+		query = query.Where("kind = ?", input.Kind)
+	}
+	if input.CityID != nil {
+		query = query.Where("city_id = ?", *input.CityID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	rules := make([]entities.PricingRule, 0)
+	if err := query.Order("kind, id").
+		Offset(page.offset()).
+		Limit(page.PageSize).
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	return &Page[entities.PricingRule]{
+		Items:    rules,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		Total:    total,
+	}, nil
+}
+
+// CreateRule adds a pricing rule (admin only)
+func (s *PricingService) CreateRule(userID int, input PricingRuleInput) (*entities.PricingRule, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	rule := &entities.PricingRule{}
+	if err := s.fillRule(rule, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(rule).Error; err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces a pricing rule (admin only). Evaluations already
+// requested keep the price they were quoted.
+func (s *PricingService) UpdateRule(userID int, ruleID int, input PricingRuleInput) (*entities.PricingRule, error) {
+	if !isAdmin(s.db, userID) {
+		return nil, ErrAdminRequired
+	}
+
+	var rule entities.PricingRule
+	if err := s.db.First(&rule, ruleID).Error; err != nil {
+		return nil, ErrPricingRuleNotFound
+	}
+
+	if err := s.fillRule(&rule, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Select("*").Omit("created_at", "updated_at").Updates(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteRule removes a pricing rule (admin only)
+func (s *PricingService) DeleteRule(userID int, ruleID int) error {
+	if !isAdmin(s.db, userID) {
+		return ErrAdminRequired
+	}
+
+	result := s.db.Delete(&entities.PricingRule{}, ruleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPricingRuleNotFound
+	}
+	return nil
+}
+
+// fillRule validates input and copies it into rule
+func (s *PricingService) fillRule(rule *entities.PricingRule, input PricingRuleInput) error {
+	kind := entities.PricingRuleKind(input.Kind)
+
+	if input.CityID != nil {
+		var city entities.City
+		if err := s.db.Select("id").First(&city, *input.CityID).Error; err != nil {
+			return fmt.Errorf("%w: city not found", ErrInvalidPricingRule)
+		}
+	}
+
+	if input.Weekdays != nil {
+		if _, err := parseWeekdays(*input.Weekdays); err != nil {
+			return err
+		}
+	}
+
+	hasYear := input.MinVehicleYear != nil || input.MaxVehicleYear != nil
+	hasTime := input.Weekdays != nil || input.StartHour != nil || input.EndHour != nil
+
+	switch {
+	case kind == entities.PricingRuleKindBase && (input.AmountCents <= 0 || input.Percent != 0):
+		return fmt.Errorf("%w: base prices need a positive amount_cents and no percent", ErrInvalidPricingRule)
+	case kind == entities.PricingRuleKindBase && (input.VehicleCategory != nil || hasYear || hasTime):
+		return fmt.Errorf("%w: base prices can only be restricted to a city", ErrInvalidPricingRule)
+	case kind != entities.PricingRuleKindBase && input.AmountCents == 0 && input.Percent == 0:
+		return fmt.Errorf("%w: modifiers need amount_cents or percent", ErrInvalidPricingRule)
+	case kind == entities.PricingRuleKindVehicleCategory && input.VehicleCategory == nil:
+		return fmt.Errorf("%w: vehicle_category is required", ErrInvalidPricingRule)
+	case kind == entities.PricingRuleKindVehicleYear && !hasYear:
+		return fmt.Errorf("%w: min_vehicle_year or max_vehicle_year is required", ErrInvalidPricingRule)
+	case kind == entities.PricingRuleKindTimeSlot && !hasTime:
+		return fmt.Errorf("%w: weekdays or start_hour and end_hour are required", ErrInvalidPricingRule)
+	case input.MinVehicleYear != nil && input.MaxVehicleYear != nil && *input.MinVehicleYear > *input.MaxVehicleYear:
+		return fmt.Errorf("%w: min_vehicle_year is after max_vehicle_year", ErrInvalidPricingRule)
+	case (input.StartHour == nil) != (input.EndHour == nil):
+		return fmt.Errorf("%w: start_hour and end_hour must be set together", ErrInvalidPricingRule)
+	case input.StartHour != nil && *input.StartHour == *input.EndHour:
+		return fmt.Errorf("%w: start_hour and end_hour must differ", ErrInvalidPricingRule)
+	}
+
+	rule.Name = input.Name
+	rule.Kind = kind
+	rule.AmountCents = input.AmountCents
+	rule.Percent = input.Percent
+	rule.Active = input.Active == nil || *input.Active
+	rule.CityID = input.CityID
+	rule.VehicleCategory = nil
+	if input.VehicleCategory != nil {
+		category := entities.VehicleCategory(*input.VehicleCategory)
+		rule.VehicleCategory = &category
+	}
+	rule.MinVehicleYear = input.MinVehicleYear
+	rule.MaxVehicleYear = input.MaxVehicleYear
+	rule.Weekdays = input.Weekdays
+	rule.StartHour = input.StartHour
+	rule.EndHour = input.EndHour
+
+	return nil
+}
+
+// ruleMatches checks the conditions of a modifier; the city was already
+// matched when rules were loaded. Weekdays and hours are taken in the business
+// timezone.
+func ruleMatches(rule *entities.PricingRule, category entities.VehicleCategory, vehicleYear *int, urgent bool, at time.Time) bool {
+	at = at.In(businessLocation)
+
+	if rule.Kind == entities.PricingRuleKindUrgency && !urgent {
+		return false
+	}
+
+	if rule.VehicleCategory != nil && *rule.VehicleCategory != category {
+		return false
+	}
+
+	if rule.MinVehicleYear != nil || rule.MaxVehicleYear != nil {
+		if vehicleYear == nil {
+			return false
+		}
+		if rule.MinVehicleYear != nil && *vehicleYear < *rule.MinVehicleYear {
+			return false
+		}
+		if rule.MaxVehicleYear != nil && *vehicleYear > *rule.MaxVehicleYear {
+			return false
+		}
+	}
+
+	if rule.Weekdays != nil {
+		weekdays, err := parseWeekdays(*rule.Weekdays)
+		if err != nil || !weekdays[at.Weekday()] {
+			return false
+		}
+	}
+
+	if rule.StartHour != nil && rule.EndHour != nil {
+		hour, start, end := at.Hour(), *rule.StartHour, *rule.EndHour
+		// Slots such as 22 to 6 wrap around midnight
+		if start < end && (hour < start || hour >= end) {
+			return false
+		}
+		if start > end && hour < start && hour >= end {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseWeekdays(value string) (map[time.Weekday]bool, error) {
+	weekdays := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || day < 0 || day > 6 {
+			return nil, fmt.Errorf("%w: weekdays must be comma-separated days from 0 (Sunday) to 6 (Saturday)", ErrInvalidPricingRule)
+		}
+		weekdays[time.Weekday(day)] = true
+	}
+	return weekdays, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"indicar-api/internal/domain/entities"
+	"testing"
+	"time"
+)
+
+func TestRuleMatchesTimeSlotInBusinessTimezone(t *testing.T) {
+	weekdays := "1,2,3,4,5"
+	start, end := 18, 22
+	rule := &entities.PricingRule{Kind: entities.PricingRuleKindTimeSlot, Weekdays: &weekdays, StartHour: &start, EndHour: &end}
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		// Monday 19:00 in São Paulo (UTC-3), whatever the server's timezone
+		{time.Date(2026, 10, 19, 22, 0, 0, 0, time.UTC), true},
+		// Monday 23:00 in São Paulo, Tuesday 02:00 UTC
+		{time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC), false},
+		// Monday 17:00 in São Paulo
+		{time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC), false},
+		// Saturday 19:00 in São Paulo
+		{time.Date(2026, 10, 24, 22, 0, 0, 0, time.UTC), false},
+	}
+	for _, test := range tests {
+		got := ruleMatches(rule, entities.VehicleCategoryCar, nil, false, test.at)
+		if got != test.want {
+			t.Errorf("ruleMatches at %s = %v, want %v", test.at, got, test.want)
+		}
+	}
+}
+
+func TestRuleMatchesSlotWrappingMidnight(t *testing.T) {
+	start, end := 22, 6
+	rule := &entities.PricingRule{Kind: entities.PricingRuleKindTimeSlot, StartHour: &start, EndHour: &end}
+
+	for hour, want := range map[int]bool{21: false, 22: true, 2: true, 6: false} {
+		at := time.Date(2026, 10, 19, hour, 30, 0, 0, businessLocation)
+		if got := ruleMatches(rule, entities.VehicleCategoryCar, nil, false, at); got != want {
+			t.Errorf("ruleMatches at %02d:30 = %v, want %v", hour, got, want)
+		}
+	}
+}
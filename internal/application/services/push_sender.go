@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/internal/domain/entities"
+	"indicar-api/internal/infrastructure/push"
+	"log"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// PushSender delivers push notifications to every device registered by the
+// user, through the provider of each device's platform. Devices whose token
+// the provider reports as unregistered are removed.
+type PushSender struct {
+	db *gorm.DB
+	// Providers by device platform ("android" or "ios")
+	providers map[string]push.Provider
+}
+
+func NewPushSender(db *gorm.DB, providers map[string]push.Provider) *PushSender {
+	return &PushSender{
+		db:        db,
+		providers: providers,
+	}
+}
+
+// Send succeeds when at least one device received the notification; it is
+// not retried for the others, which would deliver it twice to the first. It
+// fails temporarily when no device received it and a provider may accept it
+// later, and permanently otherwise.
+func (s *PushSender) Send(notification *entities.Notification) error {
+	var devices []entities.PushDevice
+	if err := s.db.Where("user_id = ?", notification.UserID).Order("id").Find(&devices).Error; err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return fmt.Errorf("%w: user has no push devices", ErrPermanentDelivery)
+	}
+
+	message := push.Message{
+		Title: notification.Title,
+		Body:  notification.Message,
+		Data:  map[string]string{"notification_id": strconv.Itoa(notification.ID)},
+	}
+
+	delivered := 0
+	temporary := false
+	var lastErr error
+	for _, device := range devices {
+		provider, ok := s.providers[device.Platform]
+		if !ok {
+			lastErr = fmt.Errorf("no push provider for platform %s", device.Platform)
+			continue
+		}
+
+		err := provider.Send(device.DeviceToken, message)
+		switch {
+		case err == nil:
+			delivered++
+		case errors.Is(err, push.ErrUnregistered):
+			lastErr = err
+			// The token may have been registered again meanwhile
+			if err := s.db.Where("id = ? AND device_token = ?", device.ID, device.DeviceToken).
+				Delete(&entities.PushDevice{}).Error; err != nil {
+				log.Printf("failed to remove unregistered push device %d: %v", device.ID, err)
+			}
+		case errors.Is(err, push.ErrRejected):
+			lastErr = err
+		default:
+			temporary = true
+			lastErr = fmt.Errorf("%s: %w", provider.Name(), err)
+		}
+	}
+
+	switch {
+	case delivered > 0:
+		return nil
+	case temporary:
+		return lastErr
+	default:
+		return fmt.Errorf("%w: %v", ErrPermanentDelivery, lastErr)
+	}
+}
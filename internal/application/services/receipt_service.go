@@ -0,0 +1,495 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/domain/entities"
+	"indicar-api/internal/infrastructure/invoice"
+	"indicar-api/internal/infrastructure/pdf"
+	"indicar-api/internal/infrastructure/storage"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	receiptQueueSize     = 100
+	receiptSweepInterval = time.Minute
+	receiptSweepBatch    = 50
+)
+
+var (
+	ErrReceiptNotFound     = errors.New("receipt not found")
+	ErrReceiptAccessDenied = errors.New("unauthorized: only the requester or an admin can access this receipt")
+	ErrReceiptUnavailable  = errors.New("payment was not captured: it has no receipt")
+)
+
+var inspectionTypeNames = map[entities.InspectionType]string{
+	entities.InspectionTypeStandard:      "padrão",
+	entities.InspectionTypeComplete:      "completa",
+	entities.InspectionTypePrecautionary: "cautelar",
+}
+
+// ReceiptService generates the PDF receipt of each captured payment and
+// issues its NFS-e. Captures enqueue their payment; a periodic sweep picks up
+// anything the queue missed and retries invoices that failed.
+type ReceiptService struct {
+	db     *gorm.DB
+	store  storage.BlobStore
+	issuer invoice.Issuer
+	queue  chan int
+}
+
+func NewReceiptService(db *gorm.DB, store storage.BlobStore, issuer invoice.Issuer) *ReceiptService {
+	return &ReceiptService{
+		db:     db,
+		store:  store,
+		issuer: issuer,
+		queue:  make(chan int, receiptQueueSize),
+	}
+}
+
+// Enqueue schedules the receipt of a payment without blocking the capture.
+// When the queue is full the receipt is generated by the next sweep.
+func (s *ReceiptService) Enqueue(paymentID int) {
+	select {
+	case s.queue <- paymentID:
+	default:
+	}
+}
+
+// Start runs the background worker
+func (s *ReceiptService) Start() {
+	go s.run()
+}
+
+func (s *ReceiptService) run() {
+	ticker := time.NewTicker(receiptSweepInterval)
+	defer ticker.Stop()
+
+	s.sweep()
+	for {
+		select {
+		case paymentID := <-s.queue:
+			if _, err := s.Generate(paymentID); err != nil {
+				log.Printf("failed to generate receipt for payment %d: %v", paymentID, err)
+			}
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+func (s *ReceiptService) sweep() {
+	// Captured payments without a receipt, whose receipt is incomplete or
+	// misses refunds, or whose invoice is due to be issued or canceled.
+	// Invoices that are dead, canceled or waiting for their next attempt are
+	// left alone.
+	now := time.Now()
+	var paymentIDs []int
+	if err := s.db.Table("payments AS p").
+		Joins("LEFT JOIN receipts AS r ON r.payment_id = p.id").
+		Where("p.paid_at IS NOT NULL AND p.amount_cents > 0").
+		Where("r.id IS NULL OR r.s3_key IS NULL OR r.refunded_cents <> p.refunded_cents"+
+			" OR ((r.invoice_status IN ? OR (r.invoice_status = ? AND p.status = ?))"+
+			" AND (r.invoice_next_attempt_at IS NULL OR r.invoice_next_attempt_at <= ?))",
+			[]entities.InvoiceStatus{entities.InvoiceStatusPending, entities.InvoiceStatusFailed},
+			entities.InvoiceStatusIssued, entities.PaymentStatusRefunded, now).
+		Order("p.id").
+		Limit(receiptSweepBatch).
+		Pluck("p.id", &paymentIDs).Error; err != nil {
+		log.Printf("failed to list payments pending receipts: %v", err)
+		return
+	}
+
+	for _, paymentID := range paymentIDs {
+		if _, err := s.Generate(paymentID); err != nil {
+			log.Printf("failed to generate receipt for payment %d: %v", paymentID, err)
+		}
+	}
+}
+
+// Generate creates or updates the receipt of a captured payment: it issues
+// the invoice when due, cancels it, or skips it, once the payment is fully
+// refunded, copies refunds and stores the PDF. It can be called again after a
+// failure. An invoice that cannot be issued is retried with exponential
+// backoff until INVOICE_MAX_ATTEMPTS; the PDF is still stored, without its
+// number.
+//
+// The worker, the sweep of other instances and downloads may generate the
+// same receipt at once: they take turns holding a lock on the receipt row.
+func (s *ReceiptService) Generate(paymentID int) (*entities.Receipt, error) {
+	var record entities.Payment
+	if err := s.db.Preload("Evaluation.Requester").First(&record, paymentID).Error; err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	if record.PaidAt == nil || record.AmountCents == 0 {
+		return nil, ErrReceiptUnavailable
+	}
+
+	if err := s.createReceipt(&record); err != nil {
+		return nil, err
+	}
+
+	var receipt entities.Receipt
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("payment_id = ?", paymentID).
+			First(&receipt).Error; err != nil {
+			return err
+		}
+
+		// Refunds may have been made while waiting for the lock
+		if err := tx.Select("id", "status", "refunded_cents").First(&record, paymentID).Error; err != nil {
+			return err
+		}
+
+		changed, err := s.updateInvoice(tx, &receipt, &record)
+		if err != nil {
+			return err
+		}
+		if receipt.RefundedCents != record.RefundedCents {
+			receipt.RefundedCents = record.RefundedCents
+			changed = true
+		}
+
+		// The PDF is rendered again when its content changes
+		if receipt.S3Key == nil || changed {
+			return s.storePDF(tx, &receipt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &receipt, nil
+}
+
+// createReceipt records the receipt of a payment, copying the customer data
+// and amounts as they are now. A receipt created concurrently is kept.
+func (s *ReceiptService) createReceipt(record *entities.Payment) error {
+	evaluation := record.Evaluation
+	customer := evaluation.Requester
+
+	discount := 0
+	if evaluation.PriceQuote != nil {
+		for _, adjustment := range evaluation.PriceQuote.Adjustments {
+			if adjustment.AmountCents < 0 {
+				discount -= adjustment.AmountCents
+			}
+		}
+	}
+
+	taxCents := int(math.Round(float64(record.AmountCents) * configs.Get().Receipt.ISSPercent / 100))
+
+	receipt := entities.Receipt{
+		PaymentID:        record.ID,
+		EvaluationID:     evaluation.ID,
+		CustomerName:     customer.FullName,
+		CustomerDocument: customer.DocumentID,
+		Description:      receiptDescription(&evaluation),
+		AmountCents:      record.AmountCents,
+		DiscountCents:    discount,
+		TaxCents:         taxCents,
+		Currency:         record.Currency,
+		PaidAt:           *record.PaidAt,
+		InvoiceIssuer:    s.issuer.Name(),
+		InvoiceStatus:    entities.InvoiceStatusPending,
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&receipt).Error
+}
+
+// updateInvoice issues the invoice of a receipt when due, or cancels it once
+// the payment is fully refunded, and tells whether the PDF must be rendered
+// again. Failures of the issuer are recorded on the receipt, not returned.
+func (s *ReceiptService) updateInvoice(tx *gorm.DB, receipt *entities.Receipt, record *entities.Payment) (bool, error) {
+	if receipt.InvoiceNextAttemptAt != nil && receipt.InvoiceNextAttemptAt.After(time.Now()) {
+		return false, nil
+	}
+	refunded := record.Status == entities.PaymentStatusRefunded
+
+	switch receipt.InvoiceStatus {
+	case entities.InvoiceStatusPending, entities.InvoiceStatusFailed:
+		if refunded {
+			// Nothing is left to invoice
+			return true, s.updateReceipt(tx, receipt, map[string]interface{}{
+				"invoice_status":          entities.InvoiceStatusCanceled,
+				"invoice_error":           nil,
+				"invoice_next_attempt_at": nil,
+			})
+		}
+		return s.issueInvoice(tx, receipt)
+	case entities.InvoiceStatusIssued:
+		if refunded {
+			return s.cancelInvoice(tx, receipt)
+		}
+	case entities.InvoiceStatusDead:
+		// Refunds are still applied, and a refund of everything settles it
+		if refunded {
+			return true, s.updateReceipt(tx, receipt, map[string]interface{}{
+				"invoice_status": entities.InvoiceStatusCanceled,
+			})
+		}
+	}
+	return false, nil
+}
+
+func (s *ReceiptService) issueInvoice(tx *gorm.DB, receipt *entities.Receipt) (bool, error) {
+	document := ""
+	if receipt.CustomerDocument != nil {
+		document = *receipt.CustomerDocument
+	}
+
+	issued, err := s.issuer.Issue(invoice.InvoiceInput{
+		Reference:        fmt.Sprintf("payment-%d", receipt.PaymentID),
+		CustomerName:     receipt.CustomerName,
+		CustomerDocument: document,
+		Description:      receipt.Description,
+		ServiceCode:      configs.Get().Invoice.ServiceCode,
+		AmountCents:      receipt.AmountCents,
+		TaxCents:         receipt.TaxCents,
+	})
+	if err != nil {
+		log.Printf("failed to issue invoice for receipt %d: %v", receipt.ID, err)
+
+		invoiceConfig := configs.Get().Invoice
+		attempts := receipt.InvoiceAttempts + 1
+		updates := map[string]interface{}{
+			"invoice_status":          entities.InvoiceStatusFailed,
+			"invoice_error":           err.Error(),
+			"invoice_attempts":        attempts,
+			"invoice_next_attempt_at": time.Now().Add(retryDelay(attempts, invoiceConfig.RetryBaseSeconds, invoiceConfig.RetryMaxSeconds)),
+		}
+		if attempts >= invoiceConfig.MaxAttempts {
+			updates["invoice_status"] = entities.InvoiceStatusDead
+			updates["invoice_next_attempt_at"] = nil
+		}
+		return false, s.updateReceipt(tx, receipt, updates)
+	}
+
+	return true, s.updateReceipt(tx, receipt, map[string]interface{}{
+		"invoice_status":            entities.InvoiceStatusIssued,
+		"invoice_number":            issued.Number,
+		"invoice_verification_code": issued.VerificationCode,
+		"invoice_issued_at":         issued.IssuedAt,
+		"invoice_error":             nil,
+		"invoice_attempts":          0,
+		"invoice_next_attempt_at":   nil,
+	})
+}
+
+// cancelInvoice cancels the invoice of a fully refunded payment, retrying
+// with the maximum backoff until the issuer accepts it
+func (s *ReceiptService) cancelInvoice(tx *gorm.DB, receipt *entities.Receipt) (bool, error) {
+	if err := s.issuer.Cancel(*receipt.InvoiceNumber, "Pagamento reembolsado"); err != nil {
+		log.Printf("failed to cancel invoice of receipt %d: %v", receipt.ID, err)
+
+		invoiceConfig := configs.Get().Invoice
+		attempts := receipt.InvoiceAttempts + 1
+		return false, s.updateReceipt(tx, receipt, map[string]interface{}{
+			"invoice_error":           err.Error(),
+			"invoice_attempts":        attempts,
+			"invoice_next_attempt_at": time.Now().Add(retryDelay(attempts, invoiceConfig.RetryBaseSeconds, invoiceConfig.RetryMaxSeconds)),
+		})
+	}
+
+	return true, s.updateReceipt(tx, receipt, map[string]interface{}{
+		"invoice_status":          entities.InvoiceStatusCanceled,
+		"invoice_canceled_at":     time.Now(),
+		"invoice_error":           nil,
+		"invoice_attempts":        0,
+		"invoice_next_attempt_at": nil,
+	})
+}
+
+// updateReceipt saves columns of a receipt and reloads it
+func (s *ReceiptService) updateReceipt(tx *gorm.DB, receipt *entities.Receipt, updates map[string]interface{}) error {
+	if err := tx.Model(receipt).Updates(updates).Error; err != nil {
+		return err
+	}
+	return tx.First(receipt, receipt.ID).Error
+}
+
+func (s *ReceiptService) storePDF(tx *gorm.DB, receipt *entities.Receipt) error {
+	key := fmt.Sprintf("receipts/%d/receipt_%d.pdf", receipt.EvaluationID, receipt.ID)
+	if err := s.store.Put(key, bytes.NewReader(renderReceipt(receipt)), "application/pdf"); err != nil {
+		return fmt.Errorf("failed to upload receipt: %w", err)
+	}
+
+	bucket := s.store.Bucket()
+	receipt.S3Bucket = &bucket
+	receipt.S3Key = &key
+	return tx.Model(receipt).Updates(map[string]interface{}{
+		"s3_bucket":      bucket,
+		"s3_key":         key,
+		"refunded_cents": receipt.RefundedCents,
+	}).Error
+}
+
+// GetPDF returns the receipt of a payment and its PDF, which the caller must
+// close, generating or updating it first if the worker has not done so yet.
+// Only the requester of the evaluation or an admin can access it.
+func (s *ReceiptService) GetPDF(paymentID int, userID int) (*entities.Receipt, io.ReadCloser, error) {
+	var record entities.Payment
+	if err := s.db.Preload("Evaluation").First(&record, paymentID).Error; err != nil {
+		return nil, nil, ErrReceiptNotFound
+	}
+	if record.Evaluation.RequesterID != userID && !isAdmin(s.db, userID) {
+		return nil, nil, ErrReceiptAccessDenied
+	}
+
+	var receipt entities.Receipt
+	err := s.db.Where("payment_id = ?", paymentID).First(&receipt).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	if err != nil || receipt.S3Key == nil || receipt.RefundedCents != record.RefundedCents {
+		generated, err := s.Generate(paymentID)
+		if err != nil {
+			return nil, nil, err
+		}
+		receipt = *generated
+	}
+
+	object, _, err := s.store.Get(*receipt.S3Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read receipt: %w", err)
+	}
+	return &receipt, object, nil
+}
+
+func receiptDescription(evaluation *entities.Evaluation) string {
+	vehicle := strings.TrimSpace(evaluation.VehicleMake + " " + evaluation.VehicleModel)
+	if evaluation.VehicleYear != nil {
+		vehicle = fmt.Sprintf("%s %d", vehicle, *evaluation.VehicleYear)
+	}
+	if evaluation.VehiclePlate != nil && *evaluation.VehiclePlate != "" {
+		vehicle = fmt.Sprintf("%s, placa %s", vehicle, *evaluation.VehiclePlate)
+	}
+
+	description := fmt.Sprintf("Avaliação veicular #%d", evaluation.ID)
+	if name, ok := inspectionTypeNames[evaluation.InspectionType]; ok {
+		description += " " + name
+	}
+	return fmt.Sprintf("%s - %s", description, vehicle)
+}
+
+// renderReceipt lays out the receipt as a single A4 page
+func renderReceipt(receipt *entities.Receipt) []byte {
+	receiptConfig := configs.Get().Receipt
+	doc := pdf.NewDocument()
+
+	const left, right = 50.0, 545.0
+	y := 70.0
+
+	doc.Text(left, y, 20, true, "Recibo de pagamento")
+	doc.TextRight(right, y, 10, false, fmt.Sprintf("Recibo nº %d", receipt.ID))
+	y += 24
+	doc.Text(left, y, 11, true, receiptConfig.CompanyName)
+	if receiptConfig.CompanyDocument != "" {
+		y += 14
+		doc.Text(left, y, 10, false, "CNPJ: "+formatDocument(receiptConfig.CompanyDocument))
+	}
+	y += 16
+	doc.Line(left, y, right, y)
+
+	y += 26
+	doc.Text(left, y, 11, true, "Cliente")
+	y += 16
+	doc.Text(left, y, 10, false, receipt.CustomerName)
+	if receipt.CustomerDocument != nil {
+		label := "CPF"
+		if len(*receipt.CustomerDocument) == 14 {
+			label = "CNPJ"
+		}
+		y += 14
+		doc.Text(left, y, 10, false, label+": "+formatDocument(*receipt.CustomerDocument))
+	}
+
+	y += 28
+	doc.Text(left, y, 11, true, "Serviço")
+	y += 16
+	doc.Text(left, y, 10, false, receipt.Description)
+
+	y += 28
+	doc.Text(left, y, 11, true, "Valores")
+	row := func(label, value string, bold bool) {
+		y += 16
+		doc.Text(left, y, 10, bold, label)
+		doc.TextRight(right, y, 10, bold, value)
+	}
+	if receipt.DiscountCents > 0 {
+		row("Subtotal", formatBRL(receipt.AmountCents+receipt.DiscountCents), false)
+		row("Descontos", "- "+formatBRL(receipt.DiscountCents), false)
+	}
+	row("Total pago", formatBRL(receipt.AmountCents), true)
+	if receipt.RefundedCents > 0 {
+		row("Reembolsado", "- "+formatBRL(receipt.RefundedCents), false)
+		row("Valor líquido", formatBRL(receipt.AmountCents-receipt.RefundedCents), true)
+	}
+	row(fmt.Sprintf("ISS incluso (%s%%)", formatPercent(receiptConfig.ISSPercent)), formatBRL(receipt.TaxCents), false)
+	y += 8
+	doc.Line(left, y, right, y)
+
+	y += 22
+	doc.Text(left, y, 10, false, "Pago em "+receipt.PaidAt.In(businessLocation).Format("02/01/2006 15:04")+" (horário de Brasília)")
+	y += 14
+	switch {
+	case receipt.InvoiceStatus == entities.InvoiceStatusIssued:
+		doc.Text(left, y, 10, false, fmt.Sprintf("NFS-e nº %s - código de verificação %s",
+			*receipt.InvoiceNumber, *receipt.InvoiceVerificationCode))
+	case receipt.InvoiceStatus == entities.InvoiceStatusCanceled && receipt.InvoiceNumber != nil:
+		doc.Text(left, y, 10, false, fmt.Sprintf("NFS-e nº %s cancelada - pagamento reembolsado", *receipt.InvoiceNumber))
+	case receipt.InvoiceStatus == entities.InvoiceStatusCanceled:
+		doc.Text(left, y, 10, false, "Pagamento reembolsado - sem NFS-e")
+	default:
+		doc.Text(left, y, 10, false, "NFS-e em emissão")
+	}
+
+	return doc.Bytes()
+}
+
+// formatBRL formats an amount as "R$ 1.234,56"
+func formatBRL(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+
+	units := fmt.Sprintf("%d", cents/100)
+	var grouped strings.Builder
+	for i, digit := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+	return fmt.Sprintf("%sR$ %s,%02d", sign, grouped.String(), cents%100)
+}
+
+// formatPercent formats a rate as "5" or "2,5"
+func formatPercent(percent float64) string {
+	return strings.Replace(fmt.Sprintf("%g", percent), ".", ",", 1)
+}
+
+// formatDocument punctuates a CPF (123.456.789-09) or CNPJ
+// (12.345.678/0001-95), leaving anything else as is
+func formatDocument(document string) string {
+	switch len(document) {
+	case 11:
+		return fmt.Sprintf("%s.%s.%s-%s", document[:3], document[3:6], document[6:9], document[9:])
+	case 14:
+		return fmt.Sprintf("%s.%s.%s/%s-%s", document[:2], document[2:5], document[5:8], document[8:12], document[12:])
+	default:
+		return document
+	}
+}
@@ -1,29 +1,48 @@
 package services
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"indicar-api/configs"
 	"indicar-api/internal/domain/entities"
-	"indicar-api/internal/infrastructure/aws"
+	"indicar-api/internal/infrastructure/media"
+	"indicar-api/internal/infrastructure/storage"
+	"io"
+	"strconv"
 	"time"
 
+	"github.com/skip2/go-qrcode"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const maxReportFileSize = 50 * 1024 * 1024 // 50MB
+
+// Verification codes avoid characters that are easy to confuse when typed by hand
+const verificationCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var ErrInvalidEvaluatorFilter = errors.New("evaluator must be 'me' or an evaluator ID")
+
 type ReportService struct {
-	db        *gorm.DB
-	s3Service *aws.S3Service
+	db         *gorm.DB
+	store      storage.BlobStore
+	signingKey ed25519.PrivateKey
 }
 
-func NewReportService(db *gorm.DB) (*ReportService, error) {
-	s3Service, err := aws.NewS3Service()
+func NewReportService(db *gorm.DB, store storage.BlobStore) (*ReportService, error) {
+	signingKey, err := loadSigningKey(configs.Get().Report.SigningKey)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ReportService{
-		db:        db,
-		s3Service: s3Service,
+		db:         db,
+		store:      store,
+		signingKey: signingKey,
 	}, nil
 }
 
@@ -32,15 +51,43 @@ type CreateReportInput struct {
 	Summary      *string `json:"summary"`
 }
 
+type ListReportsInput struct {
+	PageInput
+	Status    string `form:"status" binding:"omitempty,oneof=draft finalized"`
+	Evaluator string `form:"evaluator"`
+}
+
 type UpdateReportInput struct {
 	Summary *string                `json:"summary"`
 	Status  *entities.ReportStatus `json:"status"`
 }
 
+type ReportVerification struct {
+	VerificationCode string                `json:"verification_code"`
+	ReportStatus     entities.ReportStatus `json:"report_status"`
+	Summary          *string               `json:"summary,omitempty"`
+	Evaluation       EvaluationSummary     `json:"evaluation"`
+	FileVersion      int                   `json:"file_version,omitempty"`
+	SHA256           string                `json:"sha256,omitempty"`
+	Signature        *string               `json:"signature,omitempty"`
+	PublicKey        *string               `json:"public_key,omitempty"`
+	IssuedAt         *time.Time            `json:"issued_at,omitempty"`
+}
+
+type EvaluationSummary struct {
+	VehicleMake  string                    `json:"vehicle_make"`
+	VehicleModel string                    `json:"vehicle_model"`
+	VehicleYear  *int                      `json:"vehicle_year,omitempty"`
+	VehiclePlate *string                   `json:"vehicle_plate,omitempty"`
+	City         string                    `json:"city"`
+	StateCode    string                    `json:"state_code"`
+	Status       entities.EvaluationStatus `json:"status"`
+	EvaluatedAt  time.Time                 `json:"evaluated_at"`
+}
+
 type UploadReportFileInput struct {
-	File        []byte
+	File        io.Reader
 	ContentType string
-	SizeBytes   int
 	Filename    string
 }
 
@@ -54,11 +101,17 @@ func (s *ReportService) Create(evaluatorID int, input CreateReportInput) (*entit
 		return nil, errors.New("unauthorized: only the assigned evaluator can create a report")
 	}
 
+	verificationCode, err := generateVerificationCode()
+	if err != nil {
+		return nil, err
+	}
+
 	report := &entities.Report{
-		EvaluationID: input.EvaluationID,
-		EvaluatorID:  evaluatorID,
-		Summary:      input.Summary,
-		Status:       entities.ReportStatusDraft,
+		EvaluationID:     input.EvaluationID,
+		EvaluatorID:      evaluatorID,
+		Summary:          input.Summary,
+		Status:           entities.ReportStatusDraft,
+		VerificationCode: &verificationCode,
 	}
 
 	if err := s.db.Create(report).Error; err != nil {
@@ -68,7 +121,13 @@ func (s *ReportService) Create(evaluatorID int, input CreateReportInput) (*entit
 	return report, nil
 }
 
-func (s *ReportService) GetByID(id int) (*entities.Report, error) {
+// GetByID returns a report, if the user can access it.
+func (s *ReportService) GetByID(id int, userID int) (*entities.Report, error) {
+	return s.getAccessibleReport(id, userID)
+}
+
+// getReport loads a report without checking who asks for it
+func (s *ReportService) getReport(id int) (*entities.Report, error) {
 	var report entities.Report
 	if err := s.db.First(&report, id).Error; err != nil {
 		return nil, err
@@ -76,8 +135,67 @@ func (s *ReportService) GetByID(id int) (*entities.Report, error) {
 	return &report, nil
 }
 
+// GetByEvaluationID returns the report of an evaluation, if the user can access it.
+func (s *ReportService) GetByEvaluationID(evaluationID int, userID int) (*entities.Report, error) {
+	var report entities.Report
+	if err := s.db.Where("evaluation_id = ?", evaluationID).First(&report).Error; err != nil {
+		return nil, errors.New("report not found")
+	}
+
+	return s.getAccessibleReport(report.ID, userID)
+}
+
+// List returns the reports visible to the user: admins see every report, other
+// users only the ones they evaluated or requested. Evaluator "me" restricts the
+// list to the user's own reports.
+func (s *ReportService) List(userID int, input ListReportsInput) (*Page[entities.Report], error) {
+	page := input.PageInput.normalized()
+	query := s.db.Model(&entities.Report{})
+
+	if !isAdmin(s.db, userID) {
+		requested := s.db.Model(&entities.Evaluation{}).Select("id").Where("requester_id = ?", userID)
+		query = query.Where("evaluator_id = ? OR evaluation_id IN (?)", userID, requested)
+	}
+
+	switch input.Evaluator {
+	case "":
+	case "me":
+		query = query.Where("evaluator_id = ?", userID)
+	default:
+		evaluatorID, err := strconv.Atoi(input.Evaluator)
+		if err != nil {
+			return nil, ErrInvalidEvaluatorFilter
+		}
+		query = query.Where("evaluator_id = ?", evaluatorID)
+	}
+
+	if input.Status != "" {
+		query = query.Where("status = ?", input.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	reports := make([]entities.Report, 0)
+	if err := query.Order("created_at DESC").
+		Offset(page.offset()).
+		Limit(page.PageSize).
+		Find(&reports).Error; err != nil {
+		return nil, err
+	}
+
+	return &Page[entities.Report]{
+		Items:    reports,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		Total:    total,
+	}, nil
+}
+
 func (s *ReportService) Update(id int, evaluatorID int, input UpdateReportInput) (*entities.Report, error) {
-	report, err := s.GetByID(id)
+	report, err := s.getReport(id)
 	if err != nil {
 		return nil, err
 	}
@@ -106,15 +224,10 @@ func (s *ReportService) Update(id int, evaluatorID int, input UpdateReportInput)
 
 func (s *ReportService) UploadReportFile(reportID int, evaluatorID int, input UploadReportFileInput) (*entities.ReportFile, error) {
 	allowedTypes := []string{"pdf", "application/pdf"}
-	if err := s.s3Service.ValidateFileType(input.Filename, allowedTypes); err != nil {
+	if err := storage.ValidateFileType(input.Filename, allowedTypes); err != nil {
 		return nil, fmt.Errorf("invalid file type: %w", err)
 	}
 
-	maxSize := int64(50 * 1024 * 1024) // 50MB
-	if err := s.s3Service.ValidateFileSize(int64(input.SizeBytes), maxSize); err != nil {
-		return nil, fmt.Errorf("file too large: %w", err)
-	}
-
 	var report entities.Report
 	if err := s.db.First(&report, reportID).Error; err != nil {
 		return nil, errors.New("report not found")
@@ -124,54 +237,155 @@ func (s *ReportService) UploadReportFile(reportID int, evaluatorID int, input Up
 		return nil, errors.New("unauthorized: only the report's evaluator can upload files")
 	}
 
-	var existingFile entities.ReportFile
-	if err := s.db.Where("report_id = ?", reportID).First(&existingFile).Error; err == nil {
-		if err := s.s3Service.DeleteFile(existingFile.S3Key); err != nil {
-			return nil, fmt.Errorf("failed to delete existing file: %w", err)
-		}
-		if err := s.db.Delete(&existingFile).Error; err != nil {
-			return nil, fmt.Errorf("failed to delete existing file record: %w", err)
-		}
+	if report.Status == entities.ReportStatusFinalized {
+		return nil, errors.New("report is finalized: its files can no longer be changed")
 	}
 
+	// Previous versions are kept: a new object is always written under a new key
 	s3Key := fmt.Sprintf("reports/%d/report_%d.pdf", reportID, time.Now().UnixNano())
 
-	if err := s.s3Service.UploadFile(s3Key, input.File, input.ContentType); err != nil {
-		return nil, fmt.Errorf("failed to upload file to S3: %w", err)
+	// Check the PDF signature before anything is written to storage
+	header, content, err := media.Peek(storage.LimitReader(input.File, maxReportFileSize))
+	if err != nil {
+		if errors.Is(err, storage.ErrTooLarge) {
+			return nil, fmt.Errorf("file too large: %w", storage.ErrTooLarge)
+		}
+		return nil, err
+	}
+
+	if err := media.CheckPDFHeader(header, input.ContentType); err != nil {
+		return nil, err
 	}
 
+	// Stream the upload, enforcing the 50MB limit and hashing the content on the way.
+	// The last bytes are kept to check the PDF trailer once the upload completes.
+	tail := media.NewTailBuffer(1024)
+	body := storage.NewChecksumReader(io.TeeReader(content, tail))
+	if err := s.store.Put(s3Key, body, "application/pdf"); err != nil {
+		if errors.Is(err, storage.ErrTooLarge) {
+			return nil, fmt.Errorf("file too large: %w", storage.ErrTooLarge)
+		}
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if err := media.CheckPDFTrailer(tail.Bytes()); err != nil {
+		s.store.Delete(s3Key)
+		return nil, err
+	}
+
+	digest := body.SHA256()
+	sizeBytes := int(body.Size())
+
 	reportFile := &entities.ReportFile{
 		ReportID:    reportID,
-		S3Bucket:    s.s3Service.Bucket,
+		S3Bucket:    s.store.Bucket(),
 		S3Key:       s3Key,
-		ContentType: input.ContentType,
-		SizeBytes:   &input.SizeBytes,
+		ContentType: "application/pdf",
+		SizeBytes:   &sizeBytes,
+		SHA256:      hex.EncodeToString(digest),
+	}
+
+	if s.signingKey != nil {
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(s.signingKey, digest))
+		reportFile.Signature = &signature
 	}
 
-	if err := s.db.Create(reportFile).Error; err != nil {
-		s.s3Service.DeleteFile(s3Key)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the report so concurrent uploads get sequential versions
+		var locked entities.Report
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, reportID).Error; err != nil {
+			return err
+		}
+
+		if locked.Status == entities.ReportStatusFinalized {
+			return errors.New("report is finalized: its files can no longer be changed")
+		}
+
+		var latestVersion int
+		if err := tx.Model(&entities.ReportFile{}).
+			Where("report_id = ?", reportID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latestVersion).Error; err != nil {
+			return err
+		}
+
+		reportFile.Version = latestVersion + 1
+		return tx.Create(reportFile).Error
+	})
+	if err != nil {
+		s.store.Delete(s3Key)
 		return nil, fmt.Errorf("failed to create report file record: %w", err)
 	}
 
 	return reportFile, nil
 }
 
-func (s *ReportService) GetReportFileURL(reportID int, evaluatorID int) (string, error) {
-	var reportFile entities.ReportFile
-	if err := s.db.Where("report_id = ?", reportID).First(&reportFile).Error; err != nil {
-		return "", errors.New("report file not found")
+func (s *ReportService) ListReportFiles(reportID int, userID int) ([]entities.ReportFile, error) {
+	if _, err := s.getAccessibleReport(reportID, userID); err != nil {
+		return nil, err
+	}
+
+	var files []entities.ReportFile
+	if err := s.db.Where("report_id = ?", reportID).
+		Order("version DESC").
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// GetReportFileURL returns a pre-signed URL for the given file version, or for
+// the latest version when version is 0.
+func (s *ReportService) GetReportFileURL(reportID int, userID int, version int) (string, error) {
+	if _, err := s.getAccessibleReport(reportID, userID); err != nil {
+		return "", err
 	}
 
+	return s.reportFileURL(reportID, version, time.Hour)
+}
+
+// getAccessibleReport loads a report the user is allowed to read: the report's
+// evaluator, the requester of the evaluation, or an admin.
+func (s *ReportService) getAccessibleReport(reportID int, userID int) (*entities.Report, error) {
 	var report entities.Report
 	if err := s.db.First(&report, reportID).Error; err != nil {
-		return "", errors.New("report not found")
+		return nil, errors.New("report not found")
 	}
 
-	if report.EvaluatorID != evaluatorID {
-		return "", errors.New("unauthorized: only the report's evaluator can access the file")
+	if report.EvaluatorID == userID {
+		return &report, nil
 	}
 
-	url, err := s.s3Service.GetPresignedURL(reportFile.S3Key, time.Hour)
+	var evaluation entities.Evaluation
+	if err := s.db.First(&evaluation, report.EvaluationID).Error; err != nil {
+		return nil, errors.New("evaluation not found")
+	}
+	if evaluation.RequesterID == userID {
+		return &report, nil
+	}
+
+	if isAdmin(s.db, userID) {
+		return &report, nil
+	}
+
+	return nil, errors.New("unauthorized: only the report's evaluator, the evaluation requester or an admin can access the report")
+}
+
+func (s *ReportService) reportFileURL(reportID int, version int, expiration time.Duration) (string, error) {
+	query := s.db.Where("report_id = ?", reportID)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	} else {
+		query = query.Order("version DESC")
+	}
+
+	var reportFile entities.ReportFile
+	if err := query.First(&reportFile).Error; err != nil {
+		return "", errors.New("report file not found")
+	}
+
+	url, err := s.store.Presign(reportFile.S3Key, expiration)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate pre-signed URL: %w", err)
 	}
@@ -179,6 +393,105 @@ func (s *ReportService) GetReportFileURL(reportID int, evaluatorID int) (string,
 	return url, nil
 }
 
+// Verify looks up a report by its public verification code and returns what a
+// holder of the PDF needs to check it: the evaluation summary and the expected
+// hash (and signature, when signing is enabled) of the latest file version.
+func (s *ReportService) Verify(code string) (*ReportVerification, error) {
+	var report entities.Report
+	if err := s.db.Where("verification_code = ?", code).First(&report).Error; err != nil {
+		return nil, errors.New("verification code not found")
+	}
+
+	var evaluation entities.Evaluation
+	if err := s.db.Preload("City").First(&evaluation, report.EvaluationID).Error; err != nil {
+		return nil, errors.New("evaluation not found")
+	}
+
+	verification := &ReportVerification{
+		VerificationCode: code,
+		ReportStatus:     report.Status,
+		Summary:          report.Summary,
+		Evaluation: EvaluationSummary{
+			VehicleMake:  evaluation.VehicleMake,
+			VehicleModel: evaluation.VehicleModel,
+			VehicleYear:  evaluation.VehicleYear,
+			VehiclePlate: evaluation.VehiclePlate,
+			City:         evaluation.City.Name,
+			StateCode:    evaluation.City.StateCode,
+			Status:       evaluation.Status,
+			EvaluatedAt:  evaluation.UpdatedAt,
+		},
+	}
+
+	var reportFile entities.ReportFile
+	if err := s.db.Where("report_id = ?", report.ID).Order("version DESC").First(&reportFile).Error; err == nil {
+		verification.FileVersion = reportFile.Version
+		verification.SHA256 = reportFile.SHA256
+		verification.Signature = reportFile.Signature
+		verification.IssuedAt = &reportFile.CreatedAt
+	}
+
+	if s.signingKey != nil {
+		publicKey := base64.StdEncoding.EncodeToString(s.signingKey.Public().(ed25519.PublicKey))
+		verification.PublicKey = &publicKey
+	}
+
+	return verification, nil
+}
+
+// GetVerificationQRCode returns a PNG QR code pointing to the public
+// verification page. The API does not generate or change report PDFs: the
+// evaluator places this QR code and the verification code in the document
+// before uploading it, since the SHA-256 and signature are computed over the
+// uploaded file as is.
+func (s *ReportService) GetVerificationQRCode(reportID int, userID int) ([]byte, error) {
+	report, err := s.getAccessibleReport(reportID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reports created before verification codes existed get one on demand
+	if report.VerificationCode == nil {
+		code, err := generateVerificationCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.Model(report).Update("verification_code", code).Error; err != nil {
+			return nil, err
+		}
+		report.VerificationCode = &code
+	}
+
+	verifyURL := configs.Get().App.BaseURL + "/verify/" + *report.VerificationCode
+	return qrcode.Encode(verifyURL, qrcode.Medium, 256)
+}
+
+func loadSigningKey(encodedSeed string) (ed25519.PrivateKey, error) {
+	if encodedSeed == "" {
+		return nil, nil
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(encodedSeed)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("REPORT_SIGNING_KEY must be a base64-encoded 32-byte Ed25519 seed")
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func generateVerificationCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, len(buf))
+	for i, b := range buf {
+		code[i] = verificationCodeAlphabet[int(b)%len(verificationCodeAlphabet)]
+	}
+	return string(code), nil
+}
+
 func isValidReportStatusTransition(current, new entities.ReportStatus) bool {
 	switch current {
 	case entities.ReportStatusDraft:
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"indicar-api/internal/domain/entities"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultShareExpiry  = 7 * 24 * time.Hour
+	sharedFileURLExpiry = 15 * time.Minute
+)
+
+var (
+	ErrShareNotFound         = errors.New("share link not found")
+	ErrShareExpired          = errors.New("share link has expired or was revoked")
+	ErrSharePasswordRequired = errors.New("invalid or missing share password")
+)
+
+type ReportShareService struct {
+	db            *gorm.DB
+	reportService *ReportService
+}
+
+func NewReportShareService(db *gorm.DB, reportService *ReportService) *ReportShareService {
+	return &ReportShareService{
+		db:            db,
+		reportService: reportService,
+	}
+}
+
+type CreateReportShareInput struct {
+	ExpiresInHours int     `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+	Password       *string `json:"password" binding:"omitempty,min=4"`
+}
+
+type SharedReportResponse struct {
+	Report  *entities.Report `json:"report"`
+	FileURL string           `json:"file_url,omitempty"`
+}
+
+func (s *ReportShareService) Create(reportID int, userID int, input CreateReportShareInput) (*entities.ReportShare, error) {
+	if _, err := s.reportService.getAccessibleReport(reportID, userID); err != nil {
+		return nil, err
+	}
+
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := defaultShareExpiry
+	if input.ExpiresInHours > 0 {
+		expiry = time.Duration(input.ExpiresInHours) * time.Hour
+	}
+
+	share := &entities.ReportShare{
+		ReportID:    reportID,
+		CreatedByID: userID,
+		Token:       token,
+		ExpiresAt:   time.Now().Add(expiry),
+	}
+
+	if input.Password != nil && *input.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*input.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash := string(hashedPassword)
+		share.PasswordHash = &passwordHash
+		share.HasPassword = true
+	}
+
+	if err := s.db.Create(share).Error; err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (s *ReportShareService) List(reportID int, userID int) ([]entities.ReportShare, error) {
+	if _, err := s.reportService.getAccessibleReport(reportID, userID); err != nil {
+		return nil, err
+	}
+
+	var shares []entities.ReportShare
+	if err := s.db.Where("report_id = ?", reportID).
+		Order("created_at DESC").
+		Find(&shares).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range shares {
+		shares[i].HasPassword = shares[i].PasswordHash != nil
+	}
+
+	return shares, nil
+}
+
+func (s *ReportShareService) Revoke(reportID int, shareID int, userID int) error {
+	if _, err := s.reportService.getAccessibleReport(reportID, userID); err != nil {
+		return err
+	}
+
+	result := s.db.Model(&entities.ReportShare{}).
+		Where("id = ? AND report_id = ?", shareID, reportID).
+		Update("revoked", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareNotFound
+	}
+
+	return nil
+}
+
+// Access resolves a public share token into the shared report and a
+// short-lived download URL, recording the access.
+func (s *ReportShareService) Access(token string, password string, ipAddress string, userAgent string) (*SharedReportResponse, error) {
+	var share entities.ReportShare
+	if err := s.db.Where("token = ?", token).First(&share).Error; err != nil {
+		return nil, ErrShareNotFound
+	}
+
+	if share.Revoked || time.Now().After(share.ExpiresAt) {
+		return nil, ErrShareExpired
+	}
+
+	if share.PasswordHash != nil {
+		if err := bcrypt.CompareHashAndPassword([]byte(*share.PasswordHash), []byte(password)); err != nil {
+			return nil, ErrSharePasswordRequired
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&share).Updates(map[string]interface{}{
+			"access_count":     gorm.Expr("access_count + 1"),
+			"last_accessed_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		access := &entities.ReportShareAccess{
+			ShareID:   share.ID,
+			IPAddress: ipAddress,
+		}
+		if userAgent != "" {
+			if len(userAgent) > 255 {
+				userAgent = userAgent[:255]
+			}
+			access.UserAgent = &userAgent
+		}
+
+		return tx.Create(access).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.reportService.getReport(share.ReportID)
+	if err != nil {
+		return nil, errors.New("report not found")
+	}
+
+	response := &SharedReportResponse{Report: report}
+
+	// A report may be shared before its PDF is uploaded
+	if url, err := s.reportService.reportFileURL(share.ReportID, 0, sharedFileURLExpiry); err == nil {
+		response.FileURL = url
+	}
+
+	return response, nil
+}
+
+func generateSecureToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,18 @@
+package services
+
+import (
+	"time"
+	_ "time/tzdata"
+)
+
+// businessLocation is the timezone of payout schedules, pricing time slots and
+// dates shown to customers, independent of the server's timezone
+var businessLocation = mustLoadLocation("America/Sao_Paulo")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
@@ -3,10 +3,13 @@ package services
 import (
 	"errors"
 	"indicar-api/internal/domain/entities"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
+var ErrInvalidDocument = errors.New("document_id must be a valid CPF or CNPJ")
+
 type UserService struct {
 	db *gorm.DB
 }
@@ -38,6 +41,16 @@ func (s *UserService) UpdateUser(userID int, input UpdateUserInput) (*entities.U
 	if input.Phone != nil {
 		user.Phone = input.Phone
 	}
+	if input.DocumentID != nil {
+		document := normalizeDocument(*input.DocumentID)
+		if document == "" {
+			user.DocumentID = nil
+		} else if !isValidDocument(document) {
+			return nil, ErrInvalidDocument
+		} else {
+			user.DocumentID = &document
+		}
+	}
 
 	if err := s.db.Save(user).Error; err != nil {
 		return nil, err
@@ -73,7 +86,70 @@ func (s *UserService) GetEvaluator(evaluatorID int) (*entities.User, *entities.E
 	return &user, &evaluator, nil
 }
 
+func isAdmin(db *gorm.DB, userID int) bool {
+	var user entities.User
+	if err := db.Select("role").First(&user, userID).Error; err != nil {
+		return false
+	}
+	return user.Role == entities.UserRoleAdmin
+}
+
+// normalizeDocument strips the punctuation of a formatted CPF or CNPJ
+func normalizeDocument(document string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '-', '/', ' ':
+			return -1
+		}
+		return r
+	}, document)
+}
+
+// isValidDocument checks the length and check digits of a CPF (11 digits) or
+// CNPJ (14 digits)
+func isValidDocument(document string) bool {
+	if len(document) != 11 && len(document) != 14 {
+		return false
+	}
+
+	digits := make([]int, len(document))
+	repeated := true
+	for i, r := range document {
+		if r < '0' || r > '9' {
+			return false
+		}
+		digits[i] = int(r - '0')
+		if digits[i] != digits[0] {
+			repeated = false
+		}
+	}
+	if repeated {
+		return false
+	}
+
+	checkDigit := func(n int) int {
+		sum := 0
+		for i := 0; i < n; i++ {
+			weight := n + 1 - i
+			if len(digits) == 14 {
+				// CNPJ weights cycle from 9 down to 2
+				weight = (n-1-i)%8 + 2
+			}
+			sum += digits[i] * weight
+		}
+		if rest := sum % 11; rest >= 2 {
+			return 11 - rest
+		}
+		return 0
+	}
+
+	n := len(digits) - 2
+	return checkDigit(n) == digits[n] && checkDigit(n+1) == digits[n+1]
+}
+
 type UpdateUserInput struct {
 	FullName string  `json:"full_name"`
 	Phone    *string `json:"phone"`
+	// CPF or CNPJ, with or without punctuation; empty clears it
+	DocumentID *string `json:"document_id"`
 }
@@ -0,0 +1,26 @@
+package services
+
+import "testing"
+
+func TestDocumentValidation(t *testing.T) {
+	tests := []struct {
+		document string
+		valid    bool
+	}{
+		{"529.982.247-25", true},
+		{"52998224725", true},
+		{"529.982.247-24", false},
+		{"111.111.111-11", false},
+		{"5299822472", false},
+		{"11.222.333/0001-81", true},
+		{"11222333000181", true},
+		{"11.222.333/0001-80", false},
+		{"00.000.000/0000-00", false},
+		{"1122233300018A", false},
+	}
+	for _, test := range tests {
+		if got := isValidDocument(normalizeDocument(test.document)); got != test.valid {
+			t.Errorf("isValidDocument(%q) = %v, want %v", test.document, got, test.valid)
+		}
+	}
+}
@@ -5,6 +5,10 @@ type City struct {
 	Name        string `json:"name" gorm:"type:varchar(120);not null;uniqueIndex:idx_name_state"`
 	StateCode   string `json:"state_code" gorm:"type:varchar(2);not null;uniqueIndex:idx_name_state"`
 	CountryCode string `json:"country_code" gorm:"type:varchar(2);not null;default:BR"`
+
+	// Approximate center, used to check where evaluation photos were taken
+	Latitude  *float64 `json:"latitude,omitempty" gorm:"type:decimal(10,7)"`
+	Longitude *float64 `json:"longitude,omitempty" gorm:"type:decimal(10,7)"`
 }
 
 type EvaluatorCity struct {
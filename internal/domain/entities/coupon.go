@@ -0,0 +1,68 @@
+package entities
+
+import "time"
+
+type CouponDiscountType string
+
+const (
+	CouponDiscountTypePercent CouponDiscountType = "percent"
+	CouponDiscountTypeFixed   CouponDiscountType = "fixed"
+)
+
+type CouponRedemptionStatus string
+
+const (
+	CouponRedemptionStatusRedeemed CouponRedemptionStatus = "redeemed"
+	CouponRedemptionStatusReleased CouponRedemptionStatus = "released"
+)
+
+// Coupon is a promotional code giving a discount on the price of an
+// evaluation. DiscountValue is a percentage or an amount in cents, depending
+// on DiscountType.
+type Coupon struct {
+	ID               int                `json:"id" gorm:"primaryKey;autoIncrement"`
+	Code             string             `json:"code" gorm:"type:varchar(40);not null;uniqueIndex"`
+	Description      *string            `json:"description,omitempty" gorm:"type:varchar(255)"`
+	DiscountType     CouponDiscountType `json:"discount_type" gorm:"type:ENUM('percent', 'fixed');not null"`
+	DiscountValue    int                `json:"discount_value" gorm:"not null"`
+	MaxDiscountCents *int               `json:"max_discount_cents,omitempty"`
+	Active           bool               `json:"active" gorm:"not null;default:true"`
+	CreatedAt        time.Time          `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+	UpdatedAt        time.Time          `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+
+	// Restrictions; unset ones do not apply
+	ValidFrom             *time.Time `json:"valid_from,omitempty" gorm:"type:datetime(3)"`
+	ValidUntil            *time.Time `json:"valid_until,omitempty" gorm:"type:datetime(3)"`
+	CityID                *int       `json:"city_id,omitempty" gorm:"index"`
+	FirstOrderOnly        bool       `json:"first_order_only" gorm:"not null;default:false"`
+	MaxRedemptions        *int       `json:"max_redemptions,omitempty"`
+	MaxRedemptionsPerUser *int       `json:"max_redemptions_per_user,omitempty"`
+
+	// Redemptions currently held, kept in sync with CouponRedemption to
+	// enforce MaxRedemptions atomically
+	RedemptionCount int `json:"redemption_count" gorm:"not null;default:0"`
+
+	// Relationships
+	City *City `json:"-" gorm:"foreignKey:CityID;constraint:OnDelete:CASCADE"`
+}
+
+// CouponRedemption is the use of a coupon by an evaluation. Redemptions are
+// released, giving the use back, when the evaluation is canceled before its
+// payment is captured.
+type CouponRedemption struct {
+	ID            int                    `json:"id" gorm:"primaryKey;autoIncrement"`
+	CouponID      int                    `json:"coupon_id" gorm:"not null;index:idx_coupon_user"`
+	UserID        int                    `json:"user_id" gorm:"not null;index:idx_coupon_user"`
+	EvaluationID  int                    `json:"evaluation_id" gorm:"not null;uniqueIndex"`
+	PaymentID     *int                   `json:"payment_id,omitempty" gorm:"index"`
+	DiscountCents int                    `json:"discount_cents" gorm:"not null"`
+	Status        CouponRedemptionStatus `json:"status" gorm:"type:ENUM('redeemed', 'released');not null;default:'redeemed'"`
+	ReleasedAt    *time.Time             `json:"released_at,omitempty"`
+	CreatedAt     time.Time              `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+
+	// Relationships
+	Coupon     Coupon     `json:"-" gorm:"foreignKey:CouponID"`
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	Evaluation Evaluation `json:"-" gorm:"foreignKey:EvaluationID;constraint:OnDelete:CASCADE"`
+	Payment    *Payment   `json:"-" gorm:"foreignKey:PaymentID;constraint:OnDelete:SET NULL"`
+}
@@ -12,19 +12,60 @@ const (
 	EvaluationStatusCanceled   EvaluationStatus = "canceled"
 )
 
+type InspectionType string
+
+const (
+	InspectionTypeStandard      InspectionType = "standard"
+	InspectionTypeComplete      InspectionType = "complete"
+	InspectionTypePrecautionary InspectionType = "precautionary"
+)
+
+type PhotoCategory string
+
+const (
+	PhotoCategoryFront         PhotoCategory = "front"
+	PhotoCategoryRear          PhotoCategory = "rear"
+	PhotoCategoryLeft          PhotoCategory = "left"
+	PhotoCategoryRight         PhotoCategory = "right"
+	PhotoCategoryEngine        PhotoCategory = "engine"
+	PhotoCategoryOdometer      PhotoCategory = "odometer"
+	PhotoCategoryChassisNumber PhotoCategory = "chassis_number"
+	PhotoCategoryInterior      PhotoCategory = "interior"
+	PhotoCategoryDamageDetail  PhotoCategory = "damage_detail"
+)
+
+type PhotoVariantsStatus string
+
+const (
+	PhotoVariantsStatusPending     PhotoVariantsStatus = "pending"
+	PhotoVariantsStatusReady       PhotoVariantsStatus = "ready"
+	PhotoVariantsStatusFailed      PhotoVariantsStatus = "failed"
+	PhotoVariantsStatusUnsupported PhotoVariantsStatus = "unsupported"
+)
+
 type Evaluation struct {
-	ID           int              `json:"id" gorm:"primaryKey;autoIncrement"`
-	RequesterID  int              `json:"requester_id" gorm:"not null;index:idx_requester_status"`
-	EvaluatorID  *int             `json:"evaluator_id,omitempty" gorm:"index:idx_evaluator_status"`
-	CityID       int              `json:"city_id" gorm:"not null;index:idx_city_status"`
-	VehicleMake  string           `json:"vehicle_make" gorm:"type:varchar(80);not null;index:idx_vehicle"`
-	VehicleModel string           `json:"vehicle_model" gorm:"type:varchar(120);not null;index:idx_vehicle"`
-	VehicleYear  *int             `json:"vehicle_year,omitempty"`
-	VehiclePlate *string          `json:"vehicle_plate,omitempty" gorm:"type:varchar(16)"`
-	Status       EvaluationStatus `json:"status" gorm:"type:ENUM('created', 'accepted', 'in_progress', 'completed', 'canceled');not null;index:idx_requester_status,idx_evaluator_status,idx_city_status"`
-	Notes        *string          `json:"notes,omitempty" gorm:"type:text"`
-	CreatedAt    time.Time        `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
-	UpdatedAt    time.Time        `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+	ID              int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	RequesterID     int              `json:"requester_id" gorm:"not null;index:idx_requester_status"`
+	EvaluatorID     *int             `json:"evaluator_id,omitempty" gorm:"index:idx_evaluator_status"`
+	CityID          int              `json:"city_id" gorm:"not null;index:idx_city_status"`
+	VehicleMake     string           `json:"vehicle_make" gorm:"type:varchar(80);not null;index:idx_vehicle"`
+	VehicleModel    string           `json:"vehicle_model" gorm:"type:varchar(120);not null;index:idx_vehicle"`
+	VehicleYear     *int             `json:"vehicle_year,omitempty"`
+	VehiclePlate    *string          `json:"vehicle_plate,omitempty" gorm:"type:varchar(16)"`
+	VehicleCategory VehicleCategory  `json:"vehicle_category" gorm:"type:ENUM('car', 'motorcycle', 'pickup', 'suv', 'van', 'truck');not null;default:'car'"`
+	InspectionType  InspectionType   `json:"inspection_type" gorm:"type:ENUM('standard', 'complete', 'precautionary');not null;default:'standard'"`
+	Status          EvaluationStatus `json:"status" gorm:"type:ENUM('created', 'accepted', 'in_progress', 'completed', 'canceled');not null;index:idx_requester_status,idx_evaluator_status,idx_city_status"`
+	Notes           *string          `json:"notes,omitempty" gorm:"type:text"`
+	CreatedAt       time.Time        `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+	UpdatedAt       time.Time        `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+
+	// Scheduling preferences, which affect the price
+	Urgent      bool       `json:"urgent" gorm:"not null;default:false"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty" gorm:"type:datetime(3)"`
+
+	// Price snapshot taken when the evaluation was requested
+	PriceCents *int        `json:"price_cents,omitempty"`
+	PriceQuote *PriceQuote `json:"price_quote,omitempty" gorm:"serializer:json;type:json"`
 
 	// Relationships
 	Requester User  `json:"-" gorm:"foreignKey:RequesterID"`
@@ -34,13 +75,91 @@ type Evaluation struct {
 
 type EvaluationPhoto struct {
 	ID           int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	EvaluationID int       `json:"evaluation_id" gorm:"not null;index:idx_evaluation_created"`
+	EvaluationID int       `json:"evaluation_id" gorm:"not null;index:idx_evaluation_created;index:idx_evaluation_category;uniqueIndex:idx_evaluation_sha256,priority:1"`
 	S3Bucket     string    `json:"s3_bucket" gorm:"type:varchar(128);not null;uniqueIndex:idx_s3_location"`
 	S3Key        string    `json:"s3_key" gorm:"type:varchar(256);not null;uniqueIndex:idx_s3_location"`
 	ContentType  *string   `json:"content_type,omitempty" gorm:"type:varchar(80)"`
 	SizeBytes    *int      `json:"size_bytes,omitempty"`
+	Width        *int      `json:"width,omitempty"`
+	Height       *int      `json:"height,omitempty"`
 	CreatedAt    time.Time `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3);index:idx_evaluation_created"`
 
+	// What the photo shows and where it appears in the evaluation's gallery
+	Category *PhotoCategory `json:"category,omitempty" gorm:"type:ENUM('front', 'rear', 'left', 'right', 'engine', 'odometer', 'chassis_number', 'interior', 'damage_detail');index:idx_evaluation_category"`
+	Caption  *string        `json:"caption,omitempty" gorm:"type:varchar(255)"`
+	Position int            `json:"position" gorm:"not null;default:0"`
+
+	// Content hashes used to detect duplicated and reused photos
+	SHA256         *string `json:"sha256,omitempty" gorm:"type:char(64);index;uniqueIndex:idx_evaluation_sha256,priority:2"`
+	PerceptualHash *uint64 `json:"perceptual_hash,omitempty,string" gorm:"type:bigint unsigned"`
+
+	// Taken from the EXIF data, which is stripped from the stored file
+	CapturedAt        *time.Time `json:"captured_at,omitempty" gorm:"type:datetime(3)"`
+	Latitude          *float64   `json:"latitude,omitempty" gorm:"type:decimal(10,7)"`
+	Longitude         *float64   `json:"longitude,omitempty" gorm:"type:decimal(10,7)"`
+	Orientation       *int       `json:"orientation,omitempty" gorm:"type:tinyint"`
+	CaptureDistanceKm *float64   `json:"capture_distance_km,omitempty" gorm:"type:decimal(10,2)"`
+
+	// Resized variants, generated in the background after upload
+	ThumbnailKey   *string             `json:"thumbnail_key,omitempty" gorm:"type:varchar(256)"`
+	MediumKey      *string             `json:"medium_key,omitempty" gorm:"type:varchar(256)"`
+	VariantsStatus PhotoVariantsStatus `json:"variants_status" gorm:"type:ENUM('pending', 'ready', 'failed', 'unsupported');not null;default:'pending';index"`
+
+	// Presigned download URLs, filled when photos are listed
+	URL          string `json:"url,omitempty" gorm:"-"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty" gorm:"-"`
+	MediumURL    string `json:"medium_url,omitempty" gorm:"-"`
+
+	// Validation warnings, such as a capture location far from the evaluation's city
+	Warnings []string `json:"warnings,omitempty" gorm:"-"`
+
 	// Relationships
 	Evaluation Evaluation `json:"-" gorm:"foreignKey:EvaluationID"`
 }
+
+// PhotoHashBand is a slice of the perceptual hash of a photo. Hashes are split
+// in more bands than the bits two similar photos may differ in, so similar
+// photos share at least one band and are found through this index instead of
+// comparing the hash of every photo.
+type PhotoHashBand struct {
+	PhotoID int `json:"photo_id" gorm:"primaryKey;autoIncrement:false"`
+	Band    int `json:"band" gorm:"primaryKey;autoIncrement:false;type:tinyint;index:idx_band_value,priority:1"`
+	Value   int `json:"value" gorm:"not null;index:idx_band_value,priority:2"`
+
+	// Relationships
+	Photo EvaluationPhoto `json:"-" gorm:"foreignKey:PhotoID;constraint:OnDelete:CASCADE"`
+}
+
+type PhotoMatchType string
+
+const (
+	PhotoMatchTypeExact   PhotoMatchType = "exact"
+	PhotoMatchTypeSimilar PhotoMatchType = "similar"
+)
+
+type PhotoMatchStatus string
+
+const (
+	PhotoMatchStatusPending   PhotoMatchStatus = "pending"
+	PhotoMatchStatusConfirmed PhotoMatchStatus = "confirmed"
+	PhotoMatchStatusDismissed PhotoMatchStatus = "dismissed"
+)
+
+// PhotoMatch flags a photo that is identical or very similar to a photo of
+// another evaluation, for admin review
+type PhotoMatch struct {
+	ID             int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	PhotoID        int              `json:"photo_id" gorm:"not null;uniqueIndex:idx_photo_matched"`
+	MatchedPhotoID int              `json:"matched_photo_id" gorm:"not null;uniqueIndex:idx_photo_matched;index"`
+	MatchType      PhotoMatchType   `json:"match_type" gorm:"type:ENUM('exact', 'similar');not null"`
+	Distance       int              `json:"distance" gorm:"not null;default:0"`
+	Status         PhotoMatchStatus `json:"status" gorm:"type:ENUM('pending', 'confirmed', 'dismissed');not null;default:'pending';index"`
+	ReviewedByID   *int             `json:"reviewed_by_id,omitempty"`
+	ReviewedAt     *time.Time       `json:"reviewed_at,omitempty"`
+	CreatedAt      time.Time        `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+
+	// Relationships
+	Photo        EvaluationPhoto `json:"photo" gorm:"foreignKey:PhotoID;constraint:OnDelete:CASCADE"`
+	MatchedPhoto EvaluationPhoto `json:"matched_photo" gorm:"foreignKey:MatchedPhotoID;constraint:OnDelete:CASCADE"`
+	ReviewedBy   *User           `json:"-" gorm:"foreignKey:ReviewedByID"`
+}
@@ -7,6 +7,9 @@ type Evaluator struct {
 	TotalReviews int     `json:"total_reviews" gorm:"default:0;index:idx_rating"`
 	Bio          *string `json:"bio,omitempty" gorm:"type:varchar(255)"`
 
+	// Pix key where payouts are sent; private to the evaluator and admins
+	PixKey *string `json:"-" gorm:"type:varchar(77)"`
+
 	// Relationships
 	User User `json:"-" gorm:"foreignKey:UserID"`
 }
@@ -0,0 +1,31 @@
+package entities
+
+import "time"
+
+type IdempotencyKeyStatus string
+
+const (
+	IdempotencyKeyStatusInProgress IdempotencyKeyStatus = "in_progress"
+	IdempotencyKeyStatusCompleted  IdempotencyKeyStatus = "completed"
+)
+
+// IdempotencyKey stores the response to a request sent with an
+// Idempotency-Key header, replayed when the client retries the request.
+// Fingerprint is the SHA-256 of the request method, URI and body, so that a
+// key cannot be reused for a different request.
+type IdempotencyKey struct {
+	ID                  int                  `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID              int                  `json:"user_id" gorm:"not null;uniqueIndex:idx_user_key"`
+	Key                 string               `json:"key" gorm:"type:varchar(255);not null;uniqueIndex:idx_user_key"`
+	Fingerprint         string               `json:"fingerprint" gorm:"type:char(64);not null"`
+	Status              IdempotencyKeyStatus `json:"status" gorm:"type:ENUM('in_progress', 'completed');not null;default:'in_progress'"`
+	ResponseStatus      int                  `json:"response_status" gorm:"not null;default:0"`
+	ResponseContentType string               `json:"response_content_type" gorm:"type:varchar(255);not null;default:''"`
+	ResponseBody        []byte               `json:"-" gorm:"type:mediumblob"`
+	ExpiresAt           time.Time            `json:"expires_at" gorm:"type:datetime(3);not null;index"`
+	CreatedAt           time.Time            `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+	UpdatedAt           time.Time            `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
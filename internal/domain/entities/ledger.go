@@ -0,0 +1,111 @@
+package entities
+
+import "time"
+
+// LedgerAccount identifies the accounts of the double-entry ledger. Evaluator
+// payable accounts are per evaluator, through LedgerEntry.UserID.
+type LedgerAccount string
+
+const (
+	// Money received from customers
+	LedgerAccountCustomerPayments LedgerAccount = "customer_payments"
+	// Coupon discounts, funded by the platform
+	LedgerAccountPromotions LedgerAccount = "promotions"
+	// Share of evaluation prices kept by the platform
+	LedgerAccountPlatformCommission LedgerAccount = "platform_commission"
+	// Penalties charged to evaluators
+	LedgerAccountPenalties LedgerAccount = "penalties"
+	// What the platform owes each evaluator
+	LedgerAccountEvaluatorPayable LedgerAccount = "evaluator_payable"
+	// Earnings of disputed evaluations, held back from payouts until the
+	// dispute is settled
+	LedgerAccountEvaluatorHeld LedgerAccount = "evaluator_held"
+	// Money transferred to evaluators
+	LedgerAccountPayouts LedgerAccount = "payouts"
+)
+
+type LedgerTransactionKind string
+
+const (
+	LedgerTransactionKindEarning LedgerTransactionKind = "earning"
+	LedgerTransactionKindRefund  LedgerTransactionKind = "refund"
+	LedgerTransactionKindPenalty LedgerTransactionKind = "penalty"
+	LedgerTransactionKindPayout  LedgerTransactionKind = "payout"
+
+	LedgerTransactionKindHold       LedgerTransactionKind = "hold"
+	LedgerTransactionKindRelease    LedgerTransactionKind = "release"
+	LedgerTransactionKindChargeback LedgerTransactionKind = "chargeback"
+)
+
+// LedgerTransaction groups entries whose amounts add up to zero. Reference is
+// unique, so that an operation is never recorded twice.
+type LedgerTransaction struct {
+	ID           int                   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind         LedgerTransactionKind `json:"kind" gorm:"type:ENUM('earning', 'refund', 'penalty', 'payout', 'hold', 'release', 'chargeback');not null"`
+	Reference    string                `json:"reference" gorm:"type:varchar(80);not null;uniqueIndex"`
+	Description  string                `json:"description" gorm:"type:varchar(255);not null"`
+	EvaluationID *int                  `json:"evaluation_id,omitempty" gorm:"index"`
+	PayoutID     *int                  `json:"payout_id,omitempty" gorm:"index"`
+	CreatedByID  *int                  `json:"created_by_id,omitempty"`
+	CreatedAt    time.Time             `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+
+	// Relationships
+	Entries    []LedgerEntry `json:"entries,omitempty" gorm:"foreignKey:TransactionID"`
+	Evaluation *Evaluation   `json:"-" gorm:"foreignKey:EvaluationID"`
+	CreatedBy  *User         `json:"-" gorm:"foreignKey:CreatedByID"`
+}
+
+// LedgerEntry is a credit (positive amount) or debit (negative amount) to an
+// account
+type LedgerEntry struct {
+	ID            int           `json:"id" gorm:"primaryKey;autoIncrement"`
+	TransactionID int           `json:"transaction_id" gorm:"not null;index"`
+	Account       LedgerAccount `json:"account" gorm:"type:ENUM('customer_payments', 'promotions', 'platform_commission', 'penalties', 'evaluator_payable', 'evaluator_held', 'payouts');not null;index:idx_account_user"`
+	UserID        *int          `json:"user_id,omitempty" gorm:"index:idx_account_user"`
+	AmountCents   int           `json:"amount_cents" gorm:"not null"`
+	CreatedAt     time.Time     `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+
+	// Relationships
+	Transaction LedgerTransaction `json:"-" gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE"`
+	User        *User             `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type PayoutBatchStatus string
+
+const (
+	PayoutBatchStatusCreated  PayoutBatchStatus = "created"
+	PayoutBatchStatusExported PayoutBatchStatus = "exported"
+)
+
+// PayoutBatch groups the transfers of evaluator balances made at once
+type PayoutBatch struct {
+	ID          int               `json:"id" gorm:"primaryKey;autoIncrement"`
+	Status      PayoutBatchStatus `json:"status" gorm:"type:ENUM('created', 'exported');not null;default:'created'"`
+	TotalCents  int               `json:"total_cents" gorm:"not null;default:0"`
+	PayoutCount int               `json:"payout_count" gorm:"not null;default:0"`
+	// Schedule period of scheduled batches, e.g. "weekly:2026-10-19"; only one
+	// batch is created per period. Empty for batches created by admins.
+	Period     *string    `json:"period,omitempty" gorm:"type:varchar(32);unique"`
+	ExportedAt *time.Time `json:"exported_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3);index"`
+
+	// Relationships
+	Payouts []Payout `json:"payouts,omitempty" gorm:"foreignKey:BatchID"`
+}
+
+// Payout is the transfer of an evaluator's balance. The destination is copied
+// from the evaluator when the batch is created.
+type Payout struct {
+	ID            int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	BatchID       int       `json:"batch_id" gorm:"not null;index"`
+	EvaluatorID   int       `json:"evaluator_id" gorm:"not null;index"`
+	AmountCents   int       `json:"amount_cents" gorm:"not null"`
+	RecipientName string    `json:"recipient_name" gorm:"type:varchar(255);not null"`
+	DocumentID    *string   `json:"document_id,omitempty" gorm:"type:varchar(32)"`
+	PixKey        string    `json:"pix_key" gorm:"type:varchar(77);not null"`
+	CreatedAt     time.Time `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+
+	// Relationships
+	Batch     PayoutBatch `json:"-" gorm:"foreignKey:BatchID;constraint:OnDelete:CASCADE"`
+	Evaluator User        `json:"-" gorm:"foreignKey:EvaluatorID"`
+}
@@ -2,16 +2,35 @@ package entities
 
 import "time"
 
+const NotificationChannelPush = "push"
+
+const (
+	NotificationStatusQueued = "queued"
+	// Claimed by the dispatcher, which is delivering it
+	NotificationStatusSending = "sending"
+	NotificationStatusSent    = "sent"
+	// Delivery failed and will be retried at NextAttemptAt
+	NotificationStatusFailed = "failed"
+	// Delivery failed permanently or too many times, and is not retried
+	NotificationStatusDead = "dead"
+)
+
 type Notification struct {
 	ID        int        `json:"id" gorm:"primaryKey;autoIncrement"`
 	UserID    int        `json:"user_id" gorm:"not null;index:idx_user_status"`
 	Channel   string     `json:"channel" gorm:"type:varchar(16);not null"`
 	Title     string     `json:"title" gorm:"type:varchar(120);not null"`
 	Message   string     `json:"message" gorm:"type:varchar(255);not null"`
-	Status    string     `json:"status" gorm:"type:varchar(16);not null;default:queued;index:idx_user_status"`
+	Status    string     `json:"status" gorm:"type:varchar(16);not null;default:queued;index:idx_user_status;index:idx_status_next_attempt"`
 	CreatedAt time.Time  `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3);index"`
 	SentAt    *time.Time `json:"sent_at,omitempty"`
 
+	// Delivery attempts. While sending, NextAttemptAt is when the claim
+	// expires and the notification can be claimed again.
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" gorm:"type:datetime(3);index:idx_status_next_attempt"`
+	LastError     *string    `json:"last_error,omitempty" gorm:"type:text"`
+
 	// Relationships
 	User User `json:"-" gorm:"foreignKey:UserID"`
 }
@@ -2,17 +2,134 @@ package entities
 
 import "time"
 
+type PaymentStatus string
+
+const (
+	PaymentStatusPending    PaymentStatus = "pending"
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusPaid       PaymentStatus = "paid"
+	PaymentStatusFailed     PaymentStatus = "failed"
+	PaymentStatusCanceled   PaymentStatus = "canceled"
+	PaymentStatusRefunded   PaymentStatus = "refunded"
+	PaymentStatusExpired    PaymentStatus = "expired"
+
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	// The customer contested the charge with their card issuer
+	PaymentStatusDisputed PaymentStatus = "disputed"
+	// The dispute was lost and the amount returned to the customer
+	PaymentStatusChargedBack PaymentStatus = "charged_back"
+)
+
+type PaymentMethod string
+
+const (
+	PaymentMethodCard PaymentMethod = "card"
+	PaymentMethodPix  PaymentMethod = "pix"
+)
+
 type Payment struct {
-	ID               int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	EvaluationID     int       `json:"evaluation_id" gorm:"not null;unique"`
-	Provider         string    `json:"provider" gorm:"type:varchar(24);not null"`
-	ProviderChargeID string    `json:"provider_charge_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_provider_charge"`
-	AmountCents      int       `json:"amount_cents" gorm:"not null"`
-	Currency         string    `json:"currency" gorm:"type:char(3);not null;default:BRL"`
-	Status           string    `json:"status" gorm:"type:varchar(24);not null;index:idx_status_created"`
-	CreatedAt        time.Time `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3);index:idx_status_created"`
-	UpdatedAt        time.Time `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+	ID               int           `json:"id" gorm:"primaryKey;autoIncrement"`
+	EvaluationID     int           `json:"evaluation_id" gorm:"not null;unique"`
+	Provider         string        `json:"provider" gorm:"type:varchar(24);not null"`
+	ProviderChargeID string        `json:"provider_charge_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_provider_charge"`
+	AmountCents      int           `json:"amount_cents" gorm:"not null"`
+	RefundedCents    int           `json:"refunded_cents" gorm:"not null;default:0"`
+	Currency         string        `json:"currency" gorm:"type:char(3);not null;default:BRL"`
+	Method           PaymentMethod `json:"method" gorm:"type:varchar(16);not null;default:'card'"`
+	Status           PaymentStatus `json:"status" gorm:"type:varchar(24);not null;index:idx_status_created"`
+	ExpiresAt        *time.Time    `json:"expires_at,omitempty"`
+	PaidAt           *time.Time    `json:"paid_at,omitempty"`
+	CreatedAt        time.Time     `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3);index:idx_status_created"`
+	UpdatedAt        time.Time     `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+
+	// Charges created for the evaluation, retries included
+	Attempts int `json:"attempts" gorm:"not null;default:1"`
+
+	// Pix code to be paid: its transaction ID and BR Code ("copia e cola")
+	PixTxID    *string `json:"pix_txid,omitempty" gorm:"type:varchar(35)"`
+	PixPayload *string `json:"pix_payload,omitempty" gorm:"type:text"`
 
 	// Relationships
 	Evaluation Evaluation `json:"-" gorm:"foreignKey:EvaluationID"`
 }
+
+type RefundReason string
+
+const (
+	RefundReasonRequestedByCustomer RefundReason = "requested_by_customer"
+	RefundReasonEvaluationCanceled  RefundReason = "evaluation_canceled"
+	RefundReasonServiceIssue        RefundReason = "service_issue"
+	RefundReasonDuplicate           RefundReason = "duplicate"
+	RefundReasonFraud               RefundReason = "fraud"
+	RefundReasonOther               RefundReason = "other"
+)
+
+// RefundSource tells who initiated a refund: an admin, a refund policy (such
+// as the cancellation of a paid evaluation) or the provider, for refunds made
+// outside the API and learned from its notifications
+type RefundSource string
+
+const (
+	RefundSourceAdmin    RefundSource = "admin"
+	RefundSourcePolicy   RefundSource = "policy"
+	RefundSourceProvider RefundSource = "provider"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// Refund returns all or part of a payment to the customer. Refunds are
+// recorded pending before the provider is called, so that failed attempts are
+// kept too.
+type Refund struct {
+	ID          int          `json:"id" gorm:"primaryKey;autoIncrement"`
+	PaymentID   int          `json:"payment_id" gorm:"not null;index"`
+	AmountCents int          `json:"amount_cents" gorm:"not null"`
+	Reason      RefundReason `json:"reason" gorm:"type:ENUM('requested_by_customer', 'evaluation_canceled', 'service_issue', 'duplicate', 'fraud', 'other');not null"`
+	Source      RefundSource `json:"source" gorm:"type:ENUM('admin', 'policy', 'provider');not null"`
+	Status      RefundStatus `json:"status" gorm:"type:ENUM('pending', 'succeeded', 'failed');not null;default:'pending'"`
+	Notes       *string      `json:"notes,omitempty" gorm:"type:varchar(500)"`
+	Error       *string      `json:"error,omitempty" gorm:"type:text"`
+	CreatedByID *int         `json:"created_by_id,omitempty"`
+	CreatedAt   time.Time    `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+	UpdatedAt   time.Time    `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+
+	// Relationships
+	Payment   Payment `json:"-" gorm:"foreignKey:PaymentID;constraint:OnDelete:CASCADE"`
+	CreatedBy *User   `json:"-" gorm:"foreignKey:CreatedByID"`
+}
+
+type PaymentEventStatus string
+
+const (
+	PaymentEventStatusReceived  PaymentEventStatus = "received"
+	PaymentEventStatusProcessed PaymentEventStatus = "processed"
+	PaymentEventStatusIgnored   PaymentEventStatus = "ignored"
+	PaymentEventStatusFailed    PaymentEventStatus = "failed"
+)
+
+// PaymentEvent is a webhook call received from a payment provider, kept raw
+// so that it can be audited and replayed. Calls with an invalid signature are
+// not stored; calls that could not be read are kept as failed, without an
+// event ID.
+type PaymentEvent struct {
+	ID          int                `json:"id" gorm:"primaryKey;autoIncrement"`
+	Provider    string             `json:"provider" gorm:"type:varchar(24);not null;uniqueIndex:idx_provider_event"`
+	EventID     *string            `json:"event_id,omitempty" gorm:"type:varchar(128);uniqueIndex:idx_provider_event"`
+	EventType   *string            `json:"event_type,omitempty" gorm:"type:varchar(64)"`
+	ChargeID    *string            `json:"charge_id,omitempty" gorm:"type:varchar(64);index"`
+	PaymentID   *int               `json:"payment_id,omitempty" gorm:"index"`
+	Payload     string             `json:"payload" gorm:"type:mediumtext;not null"`
+	Status      PaymentEventStatus `json:"status" gorm:"type:ENUM('received', 'processed', 'ignored', 'failed');not null;default:'received';index"`
+	Error       *string            `json:"error,omitempty" gorm:"type:text"`
+	ProcessedAt *time.Time         `json:"processed_at,omitempty"`
+	CreatedAt   time.Time          `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+
+	// Relationships
+	Payment *Payment `json:"-" gorm:"foreignKey:PaymentID;constraint:OnDelete:SET NULL"`
+}
@@ -0,0 +1,71 @@
+package entities
+
+import "time"
+
+type VehicleCategory string
+
+const (
+	VehicleCategoryCar        VehicleCategory = "car"
+	VehicleCategoryMotorcycle VehicleCategory = "motorcycle"
+	VehicleCategoryPickup     VehicleCategory = "pickup"
+	VehicleCategorySUV        VehicleCategory = "suv"
+	VehicleCategoryVan        VehicleCategory = "van"
+	VehicleCategoryTruck      VehicleCategory = "truck"
+)
+
+type PricingRuleKind string
+
+const (
+	PricingRuleKindBase            PricingRuleKind = "base"
+	PricingRuleKindVehicleCategory PricingRuleKind = "vehicle_category"
+	PricingRuleKindVehicleYear     PricingRuleKind = "vehicle_year"
+	PricingRuleKindUrgency         PricingRuleKind = "urgency"
+	PricingRuleKindTimeSlot        PricingRuleKind = "time_slot"
+)
+
+// PricingRule is either the base price of evaluations, for a city or for all
+// of them, or a modifier applied on top of it when all of its conditions
+// match. Modifiers add AmountCents and Percent of the base price; both may be
+// negative.
+type PricingRule struct {
+	ID          int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string          `json:"name" gorm:"type:varchar(120);not null"`
+	Kind        PricingRuleKind `json:"kind" gorm:"type:ENUM('base', 'vehicle_category', 'vehicle_year', 'urgency', 'time_slot');not null;index:idx_kind_active"`
+	AmountCents int             `json:"amount_cents" gorm:"not null;default:0"`
+	Percent     int             `json:"percent" gorm:"not null;default:0"`
+	Active      bool            `json:"active" gorm:"not null;default:true;index:idx_kind_active"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+
+	// Conditions; unset conditions match everything
+	CityID          *int             `json:"city_id,omitempty" gorm:"index"`
+	VehicleCategory *VehicleCategory `json:"vehicle_category,omitempty" gorm:"type:ENUM('car', 'motorcycle', 'pickup', 'suv', 'van', 'truck')"`
+	MinVehicleYear  *int             `json:"min_vehicle_year,omitempty"`
+	MaxVehicleYear  *int             `json:"max_vehicle_year,omitempty"`
+	// Comma-separated days of the week, 0 (Sunday) to 6 (Saturday)
+	Weekdays *string `json:"weekdays,omitempty" gorm:"type:varchar(16)"`
+	// Hours of the day, from StartHour (inclusive) to EndHour (exclusive)
+	StartHour *int `json:"start_hour,omitempty" gorm:"type:tinyint"`
+	EndHour   *int `json:"end_hour,omitempty" gorm:"type:tinyint"`
+
+	// Relationships
+	City *City `json:"-" gorm:"foreignKey:CityID;constraint:OnDelete:CASCADE"`
+}
+
+// PriceQuote is how the price of an evaluation was computed. It is stored
+// with the evaluation, so later changes to the rules do not affect it.
+type PriceQuote struct {
+	BasePriceCents int               `json:"base_price_cents"`
+	Adjustments    []PriceAdjustment `json:"adjustments"`
+	TotalCents     int               `json:"total_cents"`
+	Currency       string            `json:"currency"`
+	CouponCode     *string           `json:"coupon_code,omitempty"`
+}
+
+type PriceAdjustment struct {
+	Kind        string `json:"kind"`
+	RuleID      *int   `json:"rule_id,omitempty"`
+	CouponID    *int   `json:"coupon_id,omitempty"`
+	Name        string `json:"name"`
+	AmountCents int    `json:"amount_cents"`
+}
@@ -0,0 +1,55 @@
+package entities
+
+import "time"
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusPending InvoiceStatus = "pending"
+	InvoiceStatusIssued  InvoiceStatus = "issued"
+	// Issuing failed and is retried at InvoiceNextAttemptAt
+	InvoiceStatusFailed InvoiceStatus = "failed"
+	// Issuing failed every attempt and is no longer retried
+	InvoiceStatusDead InvoiceStatus = "dead"
+	// The payment was fully refunded: the invoice was canceled, or never issued
+	InvoiceStatusCanceled InvoiceStatus = "canceled"
+)
+
+// Receipt is the proof of payment of an evaluation, generated as a PDF once
+// its payment is captured, along with the NFS-e. Customer data and amounts
+// are copied when the receipt is created; refunds are copied as they are
+// made, and the PDF rendered again.
+type Receipt struct {
+	ID               int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	PaymentID        int       `json:"payment_id" gorm:"not null;unique"`
+	EvaluationID     int       `json:"evaluation_id" gorm:"not null;index"`
+	CustomerName     string    `json:"customer_name" gorm:"type:varchar(120);not null"`
+	CustomerDocument *string   `json:"customer_document,omitempty" gorm:"type:varchar(14)"`
+	Description      string    `json:"description" gorm:"type:varchar(255);not null"`
+	AmountCents      int       `json:"amount_cents" gorm:"not null"`
+	DiscountCents    int       `json:"discount_cents" gorm:"not null;default:0"`
+	TaxCents         int       `json:"tax_cents" gorm:"not null;default:0"`
+	Currency         string    `json:"currency" gorm:"type:char(3);not null;default:BRL"`
+	PaidAt           time.Time `json:"paid_at" gorm:"type:datetime(3);not null"`
+	RefundedCents    int       `json:"refunded_cents" gorm:"not null;default:0"`
+	// Set once the PDF is stored
+	S3Bucket  *string   `json:"-" gorm:"type:varchar(128)"`
+	S3Key     *string   `json:"-" gorm:"type:varchar(256)"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+
+	// NFS-e issued for the payment
+	InvoiceIssuer           string        `json:"invoice_issuer" gorm:"type:varchar(24);not null"`
+	InvoiceStatus           InvoiceStatus `json:"invoice_status" gorm:"type:ENUM('pending', 'issued', 'failed', 'dead', 'canceled');not null;default:'pending';index:idx_invoice_status_next_attempt"`
+	InvoiceNumber           *string       `json:"invoice_number,omitempty" gorm:"type:varchar(64)"`
+	InvoiceVerificationCode *string       `json:"invoice_verification_code,omitempty" gorm:"type:varchar(64)"`
+	InvoiceIssuedAt         *time.Time    `json:"invoice_issued_at,omitempty"`
+	InvoiceCanceledAt       *time.Time    `json:"invoice_canceled_at,omitempty"`
+	InvoiceError            *string       `json:"invoice_error,omitempty" gorm:"type:text"`
+	InvoiceAttempts         int           `json:"invoice_attempts" gorm:"not null;default:0"`
+	InvoiceNextAttemptAt    *time.Time    `json:"-" gorm:"type:datetime(3);index:idx_invoice_status_next_attempt"`
+
+	// Relationships
+	Payment    Payment    `json:"-" gorm:"foreignKey:PaymentID;constraint:OnDelete:CASCADE"`
+	Evaluation Evaluation `json:"-" gorm:"foreignKey:EvaluationID"`
+}
@@ -10,28 +10,62 @@ const (
 )
 
 type Report struct {
-	ID           int          `json:"id" gorm:"primaryKey;autoIncrement"`
-	EvaluationID int          `json:"evaluation_id" gorm:"not null;unique"`
-	EvaluatorID  int          `json:"evaluator_id" gorm:"not null;index:idx_evaluator_status"`
-	Summary      *string      `json:"summary,omitempty" gorm:"type:varchar(255)"`
-	Status       ReportStatus `json:"status" gorm:"type:ENUM('draft','finalized');not null;index:idx_evaluator_status"`
-	CreatedAt    time.Time    `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
-	UpdatedAt    time.Time    `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
+	ID               int          `json:"id" gorm:"primaryKey;autoIncrement"`
+	EvaluationID     int          `json:"evaluation_id" gorm:"not null;unique"`
+	EvaluatorID      int          `json:"evaluator_id" gorm:"not null;index:idx_evaluator_status"`
+	Summary          *string      `json:"summary,omitempty" gorm:"type:varchar(255)"`
+	Status           ReportStatus `json:"status" gorm:"type:ENUM('draft','finalized');not null;index:idx_evaluator_status"`
+	VerificationCode *string      `json:"verification_code,omitempty" gorm:"type:varchar(16);unique"`
+	CreatedAt        time.Time    `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+	UpdatedAt        time.Time    `json:"updated_at" gorm:"type:datetime(3);not null;default:current_timestamp(3) on update current_timestamp(3)"`
 
 	// Relationships
-	Evaluation Evaluation `json:"-" gorm:"foreignKey:EvaluationID"`
-	Evaluator  User       `json:"-" gorm:"foreignKey:EvaluatorID"`
+	Evaluation Evaluation   `json:"-" gorm:"foreignKey:EvaluationID"`
+	Evaluator  User         `json:"-" gorm:"foreignKey:EvaluatorID"`
+	Files      []ReportFile `json:"files,omitempty" gorm:"foreignKey:ReportID"`
 }
 
 type ReportFile struct {
 	ID          int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	ReportID    int       `json:"report_id" gorm:"not null;unique"`
+	ReportID    int       `json:"report_id" gorm:"not null;uniqueIndex:idx_report_version"`
+	Version     int       `json:"version" gorm:"not null;default:1;uniqueIndex:idx_report_version"`
 	S3Bucket    string    `json:"s3_bucket" gorm:"type:varchar(128);not null;uniqueIndex:idx_s3_location"`
 	S3Key       string    `json:"s3_key" gorm:"type:varchar(256);not null;uniqueIndex:idx_s3_location"`
 	ContentType string    `json:"content_type" gorm:"type:varchar(80);default:'application/pdf'"`
 	SizeBytes   *int      `json:"size_bytes,omitempty"`
+	SHA256      string    `json:"sha256" gorm:"column:sha256;type:char(64);not null;default:'';index"`
+	Signature   *string   `json:"signature,omitempty" gorm:"type:varchar(128)"`
 	CreatedAt   time.Time `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
 
 	// Relationships
 	Report Report `json:"-" gorm:"foreignKey:ReportID"`
 }
+
+type ReportShare struct {
+	ID             int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	ReportID       int        `json:"report_id" gorm:"not null;index:idx_report_revoked"`
+	CreatedByID    int        `json:"created_by_id" gorm:"not null"`
+	Token          string     `json:"token" gorm:"type:varchar(64);not null;unique"`
+	PasswordHash   *string    `json:"-" gorm:"type:varchar(255)"`
+	HasPassword    bool       `json:"has_password" gorm:"-"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null;index"`
+	Revoked        bool       `json:"revoked" gorm:"not null;default:false;index:idx_report_revoked"`
+	AccessCount    int        `json:"access_count" gorm:"not null;default:0"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"type:datetime(3);not null;default:current_timestamp(3)"`
+
+	// Relationships
+	Report    Report `json:"-" gorm:"foreignKey:ReportID"`
+	CreatedBy User   `json:"-" gorm:"foreignKey:CreatedByID"`
+}
+
+type ReportShareAccess struct {
+	ID         int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	ShareID    int       `json:"share_id" gorm:"not null;index:idx_share_accessed"`
+	IPAddress  string    `json:"ip_address" gorm:"type:varchar(45);not null"`
+	UserAgent  *string   `json:"user_agent,omitempty" gorm:"type:varchar(255)"`
+	AccessedAt time.Time `json:"accessed_at" gorm:"type:datetime(3);not null;default:current_timestamp(3);index:idx_share_accessed"`
+
+	// Relationships
+	Share ReportShare `json:"-" gorm:"foreignKey:ShareID"`
+}
@@ -1,69 +1,124 @@
 package aws
 
 import (
-	"bytes"
 	"context"
-	"fmt"
+	"errors"
 	"indicar-api/configs"
-	"mime"
-	"path/filepath"
-	"strings"
+	"indicar-api/internal/infrastructure/storage"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// S3Service implements storage.BlobStore on top of Amazon S3
 type S3Service struct {
-	client *s3.Client
-	Bucket string
+	client    *s3.Client
+	uploader  *manager.Uploader
+	presigner *s3.PresignClient
+	bucket    string
 }
 
+var (
+	_ storage.BlobStore     = (*S3Service)(nil)
+	_ storage.PostPresigner = (*S3Service)(nil)
+)
+
 func NewS3Service() (*S3Service, error) {
 	// Use default AWS configuration which will automatically:
 	// 1. Use IAM roles if running on EC2/ECS/Lambda
 	// 2. Use environment variables if available
 	// 3. Use AWS credentials file if available
 	// 4. Use EC2 instance metadata if available
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(configs.Get().AWS.Region),
-	)
+	// Static credentials take precedence when configured (e.g. for MinIO).
+	awsConfig := configs.Get().AWS
+
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(awsConfig.Region),
+	}
+	if awsConfig.AccessKeyID != "" && awsConfig.SecretAccessKey != "" {
+		options = append(options, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(awsConfig.AccessKeyID, awsConfig.SecretAccessKey, ""),
+		))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), options...)
 	if err != nil {
 		return nil, err
 	}
 
-	client := s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if awsConfig.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(awsConfig.S3Endpoint)
+		}
+		o.UsePathStyle = awsConfig.S3UsePathStyle
+	})
+
+	// Pre-signed URLs are used by clients, which may reach the storage at
+	// another address than the API does
+	presignClient := client
+	if awsConfig.S3PublicEndpoint != "" {
+		presignClient = s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(awsConfig.S3PublicEndpoint)
+			o.UsePathStyle = awsConfig.S3UsePathStyle
+		})
+	}
 
 	return &S3Service{
-		client: client,
-		Bucket: configs.Get().AWS.S3Bucket,
+		client:    client,
+		uploader:  manager.NewUploader(client),
+		presigner: s3.NewPresignClient(presignClient),
+		bucket:    awsConfig.S3Bucket,
 	}, nil
 }
 
-// UploadFile uploads a file to S3
-func (s *S3Service) UploadFile(key string, data []byte, contentType string) error {
-	_, err := s.client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(s.Bucket),
+// Bucket returns the name of the S3 bucket
+func (s *S3Service) Bucket() string {
+	return s.bucket
+}
+
+// Put streams a file to S3. Large bodies are sent as a multipart upload, which
+// is aborted if reading the body or uploading a part fails.
+func (s *S3Service) Put(key string, body io.Reader, contentType string) error {
+	_, err := s.uploader.Upload(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
 		Key:         aws.String(key),
-		Body:        bytes.NewReader(data),
+		Body:        body,
 		ContentType: aws.String(contentType),
 	})
 
 	return err
 }
 
-// GetFileURL returns a public URL for the file
-func (s *S3Service) GetFileURL(key string) string {
-	return "https://" + s.Bucket + ".s3." + configs.Get().AWS.Region + ".amazonaws.com/" + key
-}
+// Get downloads a file from S3
+func (s *S3Service) Get(key string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	output, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, translateError(err)
+	}
+
+	info := &storage.ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		LastModified: aws.ToTime(output.LastModified),
+	}
 
-// GetPresignedURL generates a pre-signed URL for secure file access
-func (s *S3Service) GetPresignedURL(key string, expiration time.Duration) (string, error) {
-	presignClient := s3.NewPresignClient(s.client)
+	return output.Body, info, nil
+}
 
-	request, err := presignClient.PresignGetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(s.Bucket),
+// Presign generates a pre-signed URL for secure file access
+func (s *S3Service) Presign(key string, expiration time.Duration) (string, error) {
+	request, err := s.presigner.PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	}, func(opts *s3.PresignOptions) {
 		opts.Expires = expiration
@@ -76,55 +131,96 @@ func (s *S3Service) GetPresignedURL(key string, expiration time.Duration) (strin
 	return request.URL, nil
 }
 
-// ValidateFileType validates if the file type is allowed
-func (s *S3Service) ValidateFileType(filename string, allowedTypes []string) error {
-	ext := strings.ToLower(filepath.Ext(filename))
-	contentType := mime.TypeByExtension(ext)
-
-	if contentType == "" {
-		return fmt.Errorf("unable to determine file type for extension: %s", ext)
-	}
-
-	for _, allowedType := range allowedTypes {
-		if strings.Contains(contentType, allowedType) {
-			return nil
+// PresignPost generates a pre-signed POST form for direct uploads, restricted
+// to the key, the content type and a maximum size
+func (s *S3Service) PresignPost(key string, contentType string, maxSize int64, expiration time.Duration) (*storage.PresignedPost, error) {
+	request, err := s.presigner.PresignPostObject(context.TODO(), &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, func(opts *s3.PresignPostOptions) {
+		opts.Expires = expiration
+		opts.Conditions = []interface{}{
+			map[string]string{"Content-Type": contentType},
+			[]interface{}{"content-length-range", 1, maxSize},
 		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Errorf("file type %s is not allowed. Allowed types: %v", contentType, allowedTypes)
-}
-
-// ValidateFileSize validates if the file size is within limits
-func (s *S3Service) ValidateFileSize(size int64, maxSize int64) error {
-	if size > maxSize {
-		return fmt.Errorf("file size %d bytes exceeds maximum allowed size of %d bytes", size, maxSize)
+	fields := make(map[string]string, len(request.Values)+2)
+	for name, value := range request.Values {
+		fields[name] = value
 	}
-	return nil
+	fields["key"] = key
+	fields["Content-Type"] = contentType
+
+	return &storage.PresignedPost{
+		URL:    request.URL,
+		Fields: fields,
+	}, nil
 }
 
-// DeleteFile deletes a file from S3
-func (s *S3Service) DeleteFile(key string) error {
+// Delete deletes a file from S3
+func (s *S3Service) Delete(key string) error {
 	_, err := s.client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Bucket: aws.String(s.Bucket),
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
 	return err
 }
 
-// FileExists checks if a file exists in S3
-func (s *S3Service) FileExists(key string) (bool, error) {
-	_, err := s.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
-		Bucket: aws.String(s.Bucket),
+// Head returns the metadata of a file in S3
+func (s *S3Service) Head(key string) (*storage.ObjectInfo, error) {
+	output, err := s.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
-
 	if err != nil {
-		// Check if it's a "not found" error
-		if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "NotFound") {
-			return false, nil
+		return nil, translateError(err)
+	}
+
+	return &storage.ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
+}
+
+// List returns the files whose key starts with prefix
+func (s *S3Service) List(prefix string) ([]storage.ObjectInfo, error) {
+	objects := make([]storage.ObjectInfo, 0)
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			objects = append(objects, storage.ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
 		}
-		return false, err
 	}
 
-	return true, nil
+	return objects, nil
+}
+
+// translateError maps S3 "not found" errors to storage.ErrNotFound
+func translateError(err error) error {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return storage.ErrNotFound
+	}
+
+	return err
 }
@@ -12,14 +12,29 @@ var models = []interface{}{
 	&entities.City{},
 	&entities.Evaluator{},
 	&entities.EvaluatorCity{},
+	&entities.PricingRule{},
+	&entities.Coupon{},
 	&entities.Evaluation{},
 	&entities.EvaluationPhoto{},
+	&entities.PhotoHashBand{},
+	&entities.PhotoMatch{},
 	&entities.Report{},
 	&entities.ReportFile{},
+	&entities.ReportShare{},
+	&entities.ReportShareAccess{},
 	&entities.Payment{},
+	&entities.PaymentEvent{},
+	&entities.Refund{},
+	&entities.Receipt{},
+	&entities.CouponRedemption{},
+	&entities.PayoutBatch{},
+	&entities.Payout{},
+	&entities.LedgerTransaction{},
+	&entities.LedgerEntry{},
 	&entities.Notification{},
 	&entities.PushDevice{},
 	&entities.AuthRefreshToken{},
+	&entities.IdempotencyKey{},
 }
 
 func RunMigrations(db *gorm.DB) {
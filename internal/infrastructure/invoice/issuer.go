@@ -0,0 +1,38 @@
+package invoice
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidInvoice = errors.New("invalid invoice")
+
+// Issuer issues NFS-e (notas fiscais de serviço eletrônicas) through a city
+// tax system or an intermediary. Issuing is idempotent by Reference, and so
+// is canceling.
+type Issuer interface {
+	// Name identifies the issuer, as persisted with each receipt
+	Name() string
+	Issue(input InvoiceInput) (*Invoice, error)
+	// Cancel cancels an issued invoice, e.g. after a full refund
+	Cancel(number string, reason string) error
+}
+
+type InvoiceInput struct {
+	// Our own identifier for the invoice
+	Reference        string
+	CustomerName     string
+	CustomerDocument string
+	Description      string
+	// Municipal service code (item of the LC 116 list)
+	ServiceCode string
+	AmountCents int
+	// ISS included in the amount
+	TaxCents int
+}
+
+type Invoice struct {
+	Number           string
+	VerificationCode string
+	IssuedAt         time.Time
+}
@@ -0,0 +1,67 @@
+package invoice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalIssuer stands in for a real NFS-e issuer during development and
+// tests. Invoices are numbered from their reference and kept in memory; no
+// tax authority is involved.
+type LocalIssuer struct {
+	mu       sync.Mutex
+	invoices map[string]*Invoice
+	canceled map[string]bool
+}
+
+func NewLocalIssuer() *LocalIssuer {
+	return &LocalIssuer{
+		invoices: make(map[string]*Invoice),
+		canceled: make(map[string]bool),
+	}
+}
+
+func (i *LocalIssuer) Name() string {
+	return "local"
+}
+
+func (i *LocalIssuer) Issue(input InvoiceInput) (*Invoice, error) {
+	if input.Reference == "" || input.AmountCents <= 0 || input.TaxCents < 0 {
+		return nil, ErrInvalidInvoice
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if invoice, ok := i.invoices[input.Reference]; ok {
+		copied := *invoice
+		return &copied, nil
+	}
+
+	sum := sha256.Sum256([]byte(input.Reference))
+	invoice := &Invoice{
+		Number:           "LOCAL-" + input.Reference,
+		VerificationCode: strings.ToUpper(hex.EncodeToString(sum[:4])),
+		IssuedAt:         time.Now(),
+	}
+	i.invoices[input.Reference] = invoice
+
+	copied := *invoice
+	return &copied, nil
+}
+
+func (i *LocalIssuer) Cancel(number string, reason string) error {
+	if !strings.HasPrefix(number, "LOCAL-") {
+		return ErrInvalidInvoice
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// Invoices issued before a restart are not in memory anymore
+	i.canceled[number] = true
+	return nil
+}
@@ -0,0 +1,247 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Metadata is the EXIF data kept from a photo before its metadata is stripped
+type Metadata struct {
+	CapturedAt  *time.Time
+	Latitude    *float64
+	Longitude   *float64
+	Orientation int
+}
+
+// EXIF tags read from IFD0, the Exif IFD and the GPS IFD
+const (
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+)
+
+const exifDateLayout = "2006:01:02 15:04:05"
+
+var exifPrefix = []byte("Exif\x00\x00")
+
+// ReadMetadata extracts the capture time, GPS position and orientation of a
+// JPEG, PNG, WebP or HEIC image. Images without EXIF data return empty
+// metadata.
+func ReadMetadata(data []byte, contentType string) (*Metadata, error) {
+	var payload []byte
+	switch contentType {
+	case "image/jpeg":
+		payload = jpegEXIF(data)
+	case "image/png":
+		payload = pngEXIF(data)
+	case "image/webp":
+		payload = webpEXIF(data)
+	case "image/heic":
+		payload = heicEXIF(data)
+	}
+
+	if payload == nil {
+		return &Metadata{}, nil
+	}
+	metadata, err := parseEXIF(bytes.TrimPrefix(payload, exifPrefix))
+	if err == nil && contentType == "image/heic" {
+		// HEIC rotation comes from the irot and imir properties, which
+		// viewers apply; the EXIF orientation is informative only
+		metadata.Orientation = 1
+	}
+	return metadata, err
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// parseEXIF reads a TIFF structure: a byte order mark, then a chain of IFDs
+// (tables of 12-byte entries) referencing each other by offset
+func parseEXIF(data []byte) (*Metadata, error) {
+	if len(data) < 8 {
+		return nil, errors.New("EXIF data is truncated")
+	}
+
+	t := &tiffReader{data: data}
+	switch string(data[0:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid EXIF byte order")
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{Orientation: 1}
+	if orientation, ok := t.uint(ifd0[tagOrientation]); ok && orientation >= 1 && orientation <= 8 {
+		metadata.Orientation = int(orientation)
+	}
+
+	capturedAt := t.string(ifd0[tagDateTime])
+	offset := ""
+	if exifOffset, ok := t.uint(ifd0[tagExifIFD]); ok {
+		if exifIFD, err := t.readIFD(exifOffset); err == nil {
+			if original := t.string(exifIFD[tagDateTimeOriginal]); original != "" {
+				capturedAt = original
+			}
+			offset = t.string(exifIFD[tagOffsetTimeOriginal])
+		}
+	}
+	metadata.CapturedAt = parseEXIFTime(capturedAt, offset)
+
+	if gpsOffset, ok := t.uint(ifd0[tagGPSIFD]); ok {
+		if gpsIFD, err := t.readIFD(gpsOffset); err == nil {
+			latitude, latOK := t.coordinate(gpsIFD[tagGPSLatitude], t.string(gpsIFD[tagGPSLatitudeRef]), "S")
+			longitude, lonOK := t.coordinate(gpsIFD[tagGPSLongitude], t.string(gpsIFD[tagGPSLongitudeRef]), "W")
+			// 0,0 is what some devices write when they have no fix
+			if latOK && lonOK && (latitude != 0 || longitude != 0) {
+				metadata.Latitude = &latitude
+				metadata.Longitude = &longitude
+			}
+		}
+	}
+
+	return metadata, nil
+}
+
+func (t *tiffReader) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	start := int(offset)
+	if start < 8 || start+2 > len(t.data) {
+		return nil, errors.New("invalid EXIF IFD offset")
+	}
+
+	count := int(t.order.Uint16(t.data[start : start+2]))
+	entries := make(map[uint16]ifdEntry, count)
+
+	for i := 0; i < count; i++ {
+		pos := start + 2 + i*12
+		if pos+12 > len(t.data) {
+			break
+		}
+
+		tag := t.order.Uint16(t.data[pos : pos+2])
+		typ := t.order.Uint16(t.data[pos+2 : pos+4])
+		valueCount := t.order.Uint32(t.data[pos+4 : pos+8])
+
+		size := exifTypeSize(typ) * int(valueCount)
+		if size <= 0 {
+			continue
+		}
+
+		// Values of up to 4 bytes are stored in the entry itself
+		value := t.data[pos+8 : pos+12]
+		if size > 4 {
+			valueOffset := int(t.order.Uint32(t.data[pos+8 : pos+12]))
+			if valueOffset < 0 || valueOffset+size > len(t.data) {
+				continue
+			}
+			value = t.data[valueOffset : valueOffset+size]
+		}
+
+		entries[tag] = ifdEntry{typ: typ, count: valueCount, value: value[:min(size, len(value))]}
+	}
+
+	return entries, nil
+}
+
+func (t *tiffReader) uint(entry ifdEntry) (uint32, bool) {
+	switch {
+	case entry.typ == 3 && len(entry.value) >= 2:
+		return uint32(t.order.Uint16(entry.value)), true
+	case entry.typ == 4 && len(entry.value) >= 4:
+		return t.order.Uint32(entry.value), true
+	default:
+		return 0, false
+	}
+}
+
+func (t *tiffReader) string(entry ifdEntry) string {
+	if entry.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+}
+
+// coordinate converts degrees, minutes and seconds rationals to decimal degrees
+func (t *tiffReader) coordinate(entry ifdEntry, ref string, negativeRef string) (float64, bool) {
+	if entry.typ != 5 || entry.count != 3 || len(entry.value) < 24 {
+		return 0, false
+	}
+
+	var parts [3]float64
+	for i := range parts {
+		numerator := t.order.Uint32(entry.value[i*8 : i*8+4])
+		denominator := t.order.Uint32(entry.value[i*8+4 : i*8+8])
+		if denominator == 0 {
+			return 0, false
+		}
+		parts[i] = float64(numerator) / float64(denominator)
+	}
+
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+	if value > 180 {
+		return 0, false
+	}
+	if strings.EqualFold(ref, negativeRef) {
+		value = -value
+	}
+	return value, true
+}
+
+// parseEXIFTime reads EXIF timestamps, which are in the camera's local time.
+// Without an offset tag the time is kept as is, in UTC.
+func parseEXIFTime(value string, offset string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	if offset != "" {
+		if parsed, err := time.Parse(exifDateLayout+"-07:00", value+offset); err == nil {
+			return &parsed
+		}
+	}
+
+	parsed, err := time.Parse(exifDateLayout, value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+func exifTypeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9, 11:
+		return 4
+	case 5, 10, 12:
+		return 8
+	default:
+		return 0
+	}
+}
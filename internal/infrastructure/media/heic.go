@@ -0,0 +1,377 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// HEIC files are ISO base media files: a sequence of boxes, each starting with
+// a 32-bit size and a four-character type. The image size is stored in "ispe"
+// properties under meta/iprp/ipco.
+
+// isHEIC tells whether a file is a HEIF image coded in HEVC. The generic HEIF
+// brands (mif1, msf1) are not enough: they are also used by AVIF and other
+// codecs that cannot be handled as HEIC.
+func isHEIC(header []byte) bool {
+	if len(header) < 12 || string(header[4:8]) != "ftyp" {
+		return false
+	}
+
+	size := int(binary.BigEndian.Uint32(header[0:4]))
+	if size < 16 || size > len(header) {
+		size = len(header)
+	}
+
+	// Major brand, then compatible brands after the minor version
+	if heicBrands[string(header[8:12])] {
+		return true
+	}
+	for offset := 16; offset+4 <= size; offset += 4 {
+		if heicBrands[string(header[offset:offset+4])] {
+			return true
+		}
+	}
+	return false
+}
+
+func heicDimensions(data []byte) (int, int, error) {
+	meta := findBox(data, "meta")
+	if meta == nil || len(meta) < 4 {
+		return 0, 0, errors.New("HEIC metadata not found")
+	}
+
+	// meta is a full box: skip version and flags
+	iprp := findBox(meta[4:], "iprp")
+	ipco := findBox(iprp, "ipco")
+	if ipco == nil {
+		return 0, 0, errors.New("HEIC image properties not found")
+	}
+
+	// Several ispe boxes exist when the file holds thumbnails or tiles;
+	// the largest one is the primary image
+	width, height := 0, 0
+	for _, ispe := range findBoxes(ipco, "ispe") {
+		if len(ispe) < 12 {
+			continue
+		}
+		w := int(binary.BigEndian.Uint32(ispe[4:8]))
+		h := int(binary.BigEndian.Uint32(ispe[8:12]))
+		if w*h > width*height {
+			width, height = w, h
+		}
+	}
+
+	if width == 0 || height == 0 {
+		return 0, 0, errors.New("HEIC image size not found")
+	}
+	return width, height, nil
+}
+
+func findBox(data []byte, boxType string) []byte {
+	boxes := findBoxes(data, boxType)
+	if len(boxes) == 0 {
+		return nil
+	}
+	return boxes[0]
+}
+
+// findBoxes returns the payload of the direct children of data with the given type
+func findBoxes(data []byte, boxType string) [][]byte {
+	var boxes [][]byte
+
+	for offset := 0; offset+8 <= len(data); {
+		// Sizes are compared as uint64: 64-bit sizes do not fit an int
+		remaining := uint64(len(data) - offset)
+		size := uint64(binary.BigEndian.Uint32(data[offset : offset+4]))
+		typ := string(data[offset+4 : offset+8])
+		headerSize := uint64(8)
+
+		switch size {
+		case 0:
+			size = remaining
+		case 1:
+			if remaining < 16 {
+				return boxes
+			}
+			size = binary.BigEndian.Uint64(data[offset+8 : offset+16])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > remaining {
+			// Truncated box: keep what is available
+			if typ == boxType && headerSize <= remaining {
+				boxes = append(boxes, data[offset+int(headerSize):])
+			}
+			return boxes
+		}
+
+		if typ == boxType {
+			boxes = append(boxes, data[offset+int(headerSize):offset+int(size)])
+		}
+		offset += int(size)
+	}
+
+	return boxes
+}
+
+// heicExtent is a byte range of an item's data. In idat extents are relative
+// to the payload of the idat box, otherwise to the start of the file.
+type heicExtent struct {
+	offset uint64
+	length uint64
+	inIdat bool
+}
+
+// heicMetadataItems returns the extents of the Exif and XMP items of a HEIC
+// file, listed in meta/iinf and located by meta/iloc
+func heicMetadataItems(data []byte) (exif [][]heicExtent, xmp [][]heicExtent, err error) {
+	meta := findBox(data, "meta")
+	if meta == nil || len(meta) < 4 {
+		return nil, nil, errors.New("HEIC metadata not found")
+	}
+
+	exifIDs, xmpIDs, err := heicItemTypes(findBox(meta[4:], "iinf"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(exifIDs) == 0 && len(xmpIDs) == 0 {
+		return nil, nil, nil
+	}
+
+	locations, err := heicItemLocations(findBox(meta[4:], "iloc"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for id := range exifIDs {
+		exif = append(exif, locations[id])
+	}
+	for id := range xmpIDs {
+		xmp = append(xmp, locations[id])
+	}
+	return exif, xmp, nil
+}
+
+// heicItemTypes reads the item infos of an iinf box and returns the IDs of
+// the Exif items and of the XMP items, stored as application/rdf+xml
+func heicItemTypes(iinf []byte) (exif map[uint32]bool, xmp map[uint32]bool, err error) {
+	exif, xmp = map[uint32]bool{}, map[uint32]bool{}
+	if iinf == nil {
+		return exif, xmp, nil
+	}
+
+	reader := &boxReader{data: iinf}
+	version := reader.uint(1)
+	reader.skip(3)
+	if version == 0 {
+		reader.skip(2)
+	} else {
+		reader.skip(4)
+	}
+	if reader.err != nil {
+		return nil, nil, errors.New("invalid HEIC item info")
+	}
+
+	for _, infe := range findBoxes(iinf[reader.offset:], "infe") {
+		entry := &boxReader{data: infe}
+		version := entry.uint(1)
+		entry.skip(3)
+		if version < 2 {
+			// Versions 0 and 1 predate item types and cannot hold metadata items
+			continue
+		}
+		idSize := 2
+		if version >= 3 {
+			idSize = 4
+		}
+		id := uint32(entry.uint(idSize))
+		entry.skip(2)
+		itemType := string(entry.bytes(4))
+		entry.cstring()
+		if entry.err != nil {
+			return nil, nil, errors.New("invalid HEIC item info entry")
+		}
+
+		switch itemType {
+		case "Exif":
+			exif[id] = true
+		case "mime":
+			if entry.cstring() == "application/rdf+xml" {
+				xmp[id] = true
+			}
+		}
+	}
+	return exif, xmp, nil
+}
+
+// heicItemLocations reads an iloc box and returns the extents of each item
+func heicItemLocations(iloc []byte) (map[uint32][]heicExtent, error) {
+	if iloc == nil {
+		return nil, errors.New("HEIC item locations not found")
+	}
+
+	reader := &boxReader{data: iloc}
+	version := reader.uint(1)
+	reader.skip(3)
+	sizes := reader.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = reader.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+	countSize := 2
+	if version == 2 {
+		countSize = 4
+	}
+	itemCount := reader.uint(countSize)
+
+	locations := make(map[uint32][]heicExtent)
+	for i := uint64(0); i < itemCount && reader.err == nil; i++ {
+		idSize := 2
+		if version == 2 {
+			idSize = 4
+		}
+		id := uint32(reader.uint(idSize))
+		constructionMethod := uint64(0)
+		if version > 0 {
+			constructionMethod = reader.uint(2) & 0x0F
+		}
+		reader.skip(2)
+		baseOffset := reader.uint(baseOffsetSize)
+
+		extentCount := reader.uint(2)
+		for j := uint64(0); j < extentCount && reader.err == nil; j++ {
+			reader.skip(indexSize)
+			offset := reader.uint(offsetSize)
+			length := reader.uint(lengthSize)
+			locations[id] = append(locations[id], heicExtent{
+				offset: baseOffset + offset,
+				length: length,
+				inIdat: constructionMethod == 1,
+			})
+		}
+		if constructionMethod > 1 {
+			// Items built from other items reference no bytes of their own
+			delete(locations, id)
+		}
+	}
+	if reader.err != nil {
+		return nil, errors.New("invalid HEIC item locations")
+	}
+	return locations, nil
+}
+
+// heicEXIF returns the TIFF data of the first Exif item of a HEIC file
+func heicEXIF(data []byte) []byte {
+	exif, _, err := heicMetadataItems(data)
+	if err != nil || len(exif) == 0 {
+		return nil
+	}
+
+	var payload []byte
+	for _, extent := range exif[0] {
+		content, err := heicExtentBytes(data, extent)
+		if err != nil {
+			return nil
+		}
+		payload = append(payload, content...)
+	}
+
+	// The item starts with the offset of the TIFF header
+	if len(payload) < 4 {
+		return nil
+	}
+	offset := uint64(binary.BigEndian.Uint32(payload[0:4]))
+	if offset > uint64(len(payload)-4) {
+		return nil
+	}
+	return payload[4+offset:]
+}
+
+// stripHEIC returns a copy of a HEIC file with the content of its Exif and XMP
+// items zeroed. The items themselves are kept, so that no box or offset of
+// the file has to be rewritten.
+func stripHEIC(data []byte) ([]byte, error) {
+	out := bytes.Clone(data)
+	exif, xmp, err := heicMetadataItems(out)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, extents := range append(exif, xmp...) {
+		for _, extent := range extents {
+			content, err := heicExtentBytes(out, extent)
+			if err != nil {
+				return nil, err
+			}
+			clear(content)
+		}
+	}
+	return out, nil
+}
+
+// heicExtentBytes returns the bytes of an extent, which share the memory of
+// data
+func heicExtentBytes(data []byte, extent heicExtent) ([]byte, error) {
+	base := data
+	if extent.inIdat {
+		meta := findBox(data, "meta")
+		if len(meta) < 4 {
+			return nil, errors.New("HEIC metadata not found")
+		}
+		base = findBox(meta[4:], "idat")
+	}
+
+	// A zero length means "up to the end", never used by metadata items
+	size := uint64(len(base))
+	if extent.length == 0 || extent.offset > size || extent.length > size-extent.offset {
+		return nil, errors.New("HEIC item data out of bounds")
+	}
+	return base[extent.offset : extent.offset+extent.length], nil
+}
+
+// boxReader reads big-endian fields of a box payload, remembering the first
+// read past its end
+type boxReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *boxReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.offset+n > len(r.data) {
+		r.err = errors.New("truncated box")
+		return nil
+	}
+	value := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return value
+}
+
+func (r *boxReader) skip(n int) {
+	r.bytes(n)
+}
+
+// uint reads an unsigned integer of 0, 1, 2, 4 or 8 bytes
+func (r *boxReader) uint(n int) uint64 {
+	value := uint64(0)
+	for _, b := range r.bytes(n) {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func (r *boxReader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.offset:], 0)
+	if end < 0 {
+		r.err = errors.New("unterminated string")
+		return ""
+	}
+	value := string(r.data[r.offset : r.offset+end])
+	r.offset += end + 1
+	return value
+}
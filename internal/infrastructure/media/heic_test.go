@@ -0,0 +1,53 @@
+package media
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestIsHEICRequiresAnHEVCBrand(t *testing.T) {
+	ftyp := func(brands string) []byte {
+		header := binary.BigEndian.AppendUint32(nil, uint32(8+len(brands)))
+		return append(append(header, "ftyp"...), brands...)
+	}
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   bool
+	}{
+		{"heic major brand", ftyp("heic\x00\x00\x00\x00mif1heic"), true},
+		{"heic compatible brand", ftyp("mif1\x00\x00\x00\x00mif1heic"), true},
+		{"generic HEIF only", ftyp("mif1\x00\x00\x00\x00mif1msf1"), false},
+		{"AVIF", ftyp("avif\x00\x00\x00\x00avifmif1miaf"), false},
+		{"not ftyp", []byte("\x00\x00\x00\x10moovheic\x00\x00\x00\x00"), false},
+	}
+	for _, test := range tests {
+		if got := isHEIC(test.header); got != test.want {
+			t.Errorf("%s: isHEIC = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFindBoxesWithOversizedLargeSize(t *testing.T) {
+	box := func(typ string, payload string) []byte {
+		out := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+		return append(append(out, typ...), payload...)
+	}
+
+	// A 64-bit size past the end of the data, which overflows an int
+	large := binary.BigEndian.AppendUint32(nil, 1)
+	large = append(large, "ispe"...)
+	large = binary.BigEndian.AppendUint64(large, 1<<63+8)
+	large = append(large, "rest"...)
+
+	data := append(box("ispe", "first"), large...)
+	boxes := findBoxes(data, "ispe")
+	if len(boxes) != 2 || string(boxes[0]) != "first" || string(boxes[1]) != "rest" {
+		t.Errorf("findBoxes = %q, want the first box and what is left of the truncated one", boxes)
+	}
+
+	if boxes := findBoxes(large[:12], "ispe"); len(boxes) != 0 {
+		t.Errorf("findBoxes on a cut large size = %q, want none", boxes)
+	}
+}
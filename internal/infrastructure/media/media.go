@@ -0,0 +1,156 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+
+	// Register the decoders used by image.DecodeConfig
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// SniffSize is how much of a file is inspected to detect its type and read
+// image headers. JPEG metadata segments can push the frame header far from
+// the start of the file, hence the generous size.
+const SniffSize = 512 * 1024
+
+var ErrInvalidFile = errors.New("invalid file")
+
+type ImageInfo struct {
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// heicBrands are the ftyp brands of HEVC-coded HEIF files
+var heicBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true,
+}
+
+// Detect returns the content type of a file from its leading bytes, or an
+// empty string when it is not one of the supported types.
+func Detect(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return "image/gif"
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return "image/webp"
+	case isHEIC(header):
+		return "image/heic"
+	case bytes.Contains(firstBytes(header, 1024), []byte("%PDF-")):
+		return "application/pdf"
+	default:
+		return ""
+	}
+}
+
+// InspectImage detects the type of an image from its leading bytes, checks it
+// against the declared content type and reads its dimensions.
+func InspectImage(header []byte, declaredType string) (*ImageInfo, error) {
+	detectedType := Detect(header)
+	if detectedType == "" || detectedType == "application/pdf" {
+		return nil, fmt.Errorf("%w: only image files are allowed (JPEG, PNG, GIF, WebP, HEIC)", ErrInvalidFile)
+	}
+
+	if err := checkDeclaredType(declaredType, detectedType); err != nil {
+		return nil, err
+	}
+
+	info := &ImageInfo{ContentType: detectedType}
+
+	if detectedType == "image/heic" {
+		width, height, err := heicDimensions(header)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		info.Width, info.Height = width, height
+		if err := checkPixels(width, height); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		return info, nil
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(header))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decode image: %v", ErrInvalidFile, err)
+	}
+	if config.Width == 0 || config.Height == 0 {
+		return nil, fmt.Errorf("%w: image has no dimensions", ErrInvalidFile)
+	}
+
+	info.Width, info.Height = config.Width, config.Height
+	if err := checkPixels(config.Width, config.Height); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return info, nil
+}
+
+// CheckPDFHeader checks that a file starts like a PDF document and matches
+// the declared content type.
+func CheckPDFHeader(header []byte, declaredType string) error {
+	if Detect(header) != "application/pdf" {
+		return fmt.Errorf("%w: only PDF files are allowed", ErrInvalidFile)
+	}
+
+	if err := checkDeclaredType(declaredType, "application/pdf"); err != nil {
+		return err
+	}
+
+	version := header[bytes.Index(header, []byte("%PDF-"))+5:]
+	if len(version) < 3 || version[0] < '1' || version[0] > '2' || version[1] != '.' {
+		return fmt.Errorf("%w: unsupported PDF version", ErrInvalidFile)
+	}
+
+	return nil
+}
+
+// CheckPDFTrailer checks that the last bytes of a PDF contain the
+// cross-reference pointer and end-of-file marker of a complete document.
+func CheckPDFTrailer(tail []byte) error {
+	if !bytes.Contains(tail, []byte("startxref")) || !bytes.Contains(tail, []byte("%%EOF")) {
+		return fmt.Errorf("%w: PDF is truncated or malformed", ErrInvalidFile)
+	}
+	return nil
+}
+
+func checkDeclaredType(declaredType string, detectedType string) error {
+	declared := normalizeContentType(declaredType)
+	if declared != "" && declared != detectedType {
+		return fmt.Errorf("%w: declared content type %s does not match detected type %s", ErrInvalidFile, declaredType, detectedType)
+	}
+	return nil
+}
+
+func normalizeContentType(contentType string) string {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	switch contentType {
+	case "image/jpg", "image/pjpeg":
+		return "image/jpeg"
+	case "image/heif", "image/heic-sequence", "image/heif-sequence":
+		return "image/heic"
+	case "application/octet-stream":
+		// Generic type sent by some clients: rely on detection alone
+		return ""
+	default:
+		return contentType
+	}
+}
+
+func firstBytes(data []byte, n int) []byte {
+	if len(data) < n {
+		return data
+	}
+	return data[:n]
+}
@@ -0,0 +1,33 @@
+package media
+
+import (
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// PerceptualHash computes a 64-bit difference hash: the image is shrunk to
+// 9x8 grayscale pixels and each bit tells whether a pixel is brighter than its
+// right neighbour. Resized, recompressed or slightly edited copies of a photo
+// get hashes within a few bits of each other.
+func PerceptualHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.CatmullRom.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashDistance is the number of differing bits between two perceptual hashes
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
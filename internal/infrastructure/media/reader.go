@@ -0,0 +1,42 @@
+package media
+
+import (
+	"bufio"
+	"io"
+)
+
+// Peek wraps r so its first SniffSize bytes can be inspected without
+// consuming them. The returned reader yields the whole content.
+func Peek(r io.Reader) ([]byte, io.Reader, error) {
+	buffered := bufio.NewReaderSize(r, SniffSize)
+
+	header, err := buffered.Peek(SniffSize)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	return header, buffered, nil
+}
+
+// TailBuffer is a writer keeping only the last bytes written to it, used to
+// inspect the end of a stream.
+type TailBuffer struct {
+	size int
+	data []byte
+}
+
+func NewTailBuffer(size int) *TailBuffer {
+	return &TailBuffer{size: size}
+}
+
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.data = append(t.data, p...)
+	if len(t.data) > t.size {
+		t.data = t.data[len(t.data)-t.size:]
+	}
+	return len(p), nil
+}
+
+func (t *TailBuffer) Bytes() []byte {
+	return t.data
+}
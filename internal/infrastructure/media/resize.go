@@ -0,0 +1,72 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxPixels is the size of the largest image decoded, about 200MB of RGBA
+// pixels. Headers claiming more are rejected before any pixel is read.
+const MaxPixels = 50_000_000
+
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// Decode reads a full image after checking its size against MaxPixels. HEIC
+// images cannot be decoded and return image.ErrFormat.
+func Decode(r io.Reader) (image.Image, error) {
+	header, r, err := Peek(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(header))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPixels(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+func checkPixels(width int, height int) error {
+	if int64(width)*int64(height) > MaxPixels {
+		return fmt.Errorf("%w: %dx%d", ErrTooManyPixels, width, height)
+	}
+	return nil
+}
+
+// Resize scales an image down so that it fits in a maxSize x maxSize box,
+// keeping its aspect ratio. Smaller images are returned unchanged.
+func Resize(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Src, nil)
+	return resized
+}
+
+// EncodeJPEG writes an image as JPEG with the given quality (1-100)
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
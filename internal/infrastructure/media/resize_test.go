@@ -0,0 +1,54 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// testPNGClaiming returns a small PNG whose header claims the given size
+func testPNGClaiming(t *testing.T, width uint32, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// Signature, then the IHDR chunk: length, type, width, height...
+	binary.BigEndian.PutUint32(data[16:20], width)
+	binary.BigEndian.PutUint32(data[20:24], height)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestDecodeRejectsTooManyPixels(t *testing.T) {
+	_, err := Decode(bytes.NewReader(testPNGClaiming(t, 10000, 10000)))
+	if !errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("err = %v, want ErrTooManyPixels", err)
+	}
+
+	_, err = InspectImage(testPNGClaiming(t, 10000, 10000), "image/png")
+	if !errors.Is(err, ErrInvalidFile) {
+		t.Fatalf("err = %v, want ErrInvalidFile", err)
+	}
+}
+
+func TestDecodeSmallImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 3 || img.Bounds().Dy() != 2 {
+		t.Errorf("size = %v", img.Bounds())
+	}
+}
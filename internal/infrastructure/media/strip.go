@@ -0,0 +1,424 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// Quality used when an image has to be re-encoded as JPEG to apply its
+// orientation
+const rotatedJPEGQuality = 92
+
+// SanitizedImage is a photo with its metadata removed and its pixels turned
+// upright according to the EXIF orientation. ContentType differs from the
+// original one for rotated WebP images, which are re-encoded as JPEG or PNG.
+type SanitizedImage struct {
+	Data        []byte
+	ContentType string
+	Metadata    *Metadata
+	Width       int
+	Height      int
+}
+
+// Sanitize extracts the EXIF metadata of an image and returns a copy without
+// it. JPEG, PNG and WebP images with a non-default orientation are re-encoded
+// with the rotation applied; otherwise metadata is removed without touching
+// the image data. GIF images carry no EXIF data. The Exif and XMP items of
+// HEIC images are zeroed in place, as HEIC cannot be decoded here.
+func Sanitize(data []byte, info *ImageInfo) (*SanitizedImage, error) {
+	metadata, err := ReadMetadata(data, info.ContentType)
+	if err != nil {
+		// Corrupt metadata is dropped along with the rest
+		metadata = &Metadata{Orientation: 1}
+	}
+
+	result := &SanitizedImage{
+		Data:        data,
+		ContentType: info.ContentType,
+		Metadata:    metadata,
+		Width:       info.Width,
+		Height:      info.Height,
+	}
+
+	rotate := metadata.Orientation > 1
+	switch {
+	case rotate && (info.ContentType == "image/jpeg" || info.ContentType == "image/png" || info.ContentType == "image/webp"):
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		img = applyOrientation(img, metadata.Orientation)
+
+		// There is no WebP encoder: WebP images become PNG when they have
+		// transparency and JPEG otherwise
+		if info.ContentType == "image/webp" {
+			result.ContentType = "image/jpeg"
+			if opaque, ok := img.(interface{ Opaque() bool }); ok && !opaque.Opaque() {
+				result.ContentType = "image/png"
+			}
+		}
+
+		var buf bytes.Buffer
+		if result.ContentType == "image/png" {
+			err = png.Encode(&buf, img)
+		} else {
+			err = EncodeJPEG(&buf, img, rotatedJPEGQuality)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result.Data = buf.Bytes()
+		result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	case info.ContentType == "image/jpeg":
+		result.Data, err = stripJPEG(data)
+	case info.ContentType == "image/png":
+		result.Data, err = stripPNG(data)
+	case info.ContentType == "image/webp":
+		result.Data, err = stripWebP(data)
+	case info.ContentType == "image/heic":
+		result.Data, err = stripHEIC(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// applyOrientation turns an image upright given its EXIF orientation (2-8)
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight := w, h
+	if orientation >= 5 {
+		dstWidth, dstHeight = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, w-1-x
+			default:
+				return img
+			}
+
+			srcOffset := src.PixOffset(x, y)
+			dstOffset := dst.PixOffset(dx, dy)
+			copy(dst.Pix[dstOffset:dstOffset+4], src.Pix[srcOffset:srcOffset+4])
+		}
+	}
+
+	return dst
+}
+
+// JPEG segments start with 0xFF and a marker; all segments before the image
+// data (SOS) have a 16-bit length that includes itself
+const (
+	jpegMarkerSOF0 = 0xC0
+	jpegMarkerSOFF = 0xCF
+	jpegMarkerDHT  = 0xC4
+	jpegMarkerJPG  = 0xC8
+	jpegMarkerDAC  = 0xCC
+	jpegMarkerRST0 = 0xD0
+	jpegMarkerRST7 = 0xD7
+	jpegMarkerEOI  = 0xD9
+	jpegMarkerSOS  = 0xDA
+	jpegMarkerDQT  = 0xDB
+	jpegMarkerDNL  = 0xDC
+	jpegMarkerDRI  = 0xDD
+	jpegMarkerAPP0 = 0xE0 // JFIF
+	jpegMarkerAPP1 = 0xE1 // EXIF and XMP
+	jpegMarkerAPP2 = 0xE2 // ICC profile and MPF
+	jpegMarkerAPPE = 0xEE // Adobe color transform
+	jpegMarkerCOM  = 0xFE
+)
+
+func jpegEXIF(data []byte) []byte {
+	var payload []byte
+	walkJPEG(data, 2, func(marker byte, segment []byte) bool {
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(segment, exifPrefix) {
+			payload = segment
+			return false
+		}
+		return true
+	})
+	return payload
+}
+
+// keepJPEGSegment tells whether a segment is needed to decode and display the
+// image: tables, frame headers and the APPn segments describing colors. All
+// other segments, such as EXIF, XMP, MPF, maker notes and comments, are
+// dropped.
+func keepJPEGSegment(marker byte, segment []byte) bool {
+	switch marker {
+	case jpegMarkerDQT, jpegMarkerDHT, jpegMarkerDAC, jpegMarkerDRI, jpegMarkerDNL:
+		return true
+	case jpegMarkerJPG:
+		return false
+	case jpegMarkerAPP0:
+		return bytes.HasPrefix(segment, []byte("JFIF\x00"))
+	case jpegMarkerAPP2:
+		return bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00"))
+	case jpegMarkerAPPE:
+		return bytes.HasPrefix(segment, []byte("Adobe"))
+	default:
+		return marker >= jpegMarkerSOF0 && marker <= jpegMarkerSOFF
+	}
+}
+
+// stripJPEG rebuilds a JPEG from its SOI marker, the segments it needs, its
+// scans and its EOI marker. Anything after EOI, such as the extra images of
+// MPF files, is dropped.
+func stripJPEG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+
+	// Progressive images have several scans, with tables in between
+	scans := 0
+	for offset := 2; ; {
+		end, err := walkJPEG(data, offset, func(marker byte, segment []byte) bool {
+			if keepJPEGSegment(marker, segment) {
+				length := len(segment) + 2
+				out = append(out, 0xFF, marker, byte(length>>8), byte(length))
+				out = append(out, segment...)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if data[end+1] == jpegMarkerEOI {
+			if scans == 0 {
+				return nil, errors.New("JPEG image data not found")
+			}
+			break
+		}
+		scans++
+
+		// SOS header, then the entropy-coded data as is
+		if end+4 > len(data) {
+			return nil, errors.New("truncated JPEG scan")
+		}
+		headerEnd := end + 2 + int(binary.BigEndian.Uint16(data[end+2:end+4]))
+		if headerEnd > len(data) {
+			return nil, errors.New("truncated JPEG scan")
+		}
+		offset = jpegScanEnd(data, headerEnd)
+		out = append(out, data[end:offset]...)
+
+		if offset >= len(data) {
+			// Some encoders omit EOI
+			break
+		}
+	}
+
+	return append(out, 0xFF, jpegMarkerEOI), nil
+}
+
+// jpegScanEnd returns the offset of the first marker after the entropy-coded
+// data starting at offset, skipping stuffed bytes and restart markers
+func jpegScanEnd(data []byte, offset int) int {
+	for i := offset; i+1 < len(data); i++ {
+		if data[i] != 0xFF {
+			continue
+		}
+		next := data[i+1]
+		if next == 0x00 || next == 0xFF || (next >= jpegMarkerRST0 && next <= jpegMarkerRST7) {
+			continue
+		}
+		return i
+	}
+	return len(data)
+}
+
+// walkJPEG calls fn with the payload of each segment from offset until the
+// image data and returns the offset of the next SOS or EOI marker
+func walkJPEG(data []byte, offset int, fn func(marker byte, segment []byte) bool) (int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, errors.New("invalid JPEG")
+	}
+
+	for offset+2 <= len(data) {
+		if data[offset] != 0xFF {
+			return 0, errors.New("invalid JPEG segment")
+		}
+
+		marker := data[offset+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			offset++
+			continue
+		case marker == jpegMarkerSOS, marker == jpegMarkerEOI:
+			return offset, nil
+		case marker >= jpegMarkerRST0 && marker <= jpegMarkerRST7:
+			// Restart markers have no payload
+			offset += 2
+			continue
+		}
+
+		if offset+4 > len(data) {
+			return 0, errors.New("truncated JPEG segment")
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		if length < 2 || offset+2+length > len(data) {
+			return 0, errors.New("truncated JPEG segment")
+		}
+
+		if !fn(marker, data[offset+4:offset+2+length]) {
+			return offset, nil
+		}
+		offset += 2 + length
+	}
+
+	return 0, errors.New("JPEG image data not found")
+}
+
+// PNG chunks: length, type, data and CRC. Text chunks may hold metadata too.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true,
+}
+
+func pngEXIF(data []byte) []byte {
+	var payload []byte
+	walkPNG(data, func(typ string, chunk []byte, body []byte) {
+		if typ == "eXIf" && payload == nil {
+			payload = body
+		}
+	})
+	return payload
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:8]...)
+
+	err := walkPNG(data, func(typ string, chunk []byte, body []byte) {
+		if !pngMetadataChunks[typ] {
+			out = append(out, chunk...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func walkPNG(data []byte, fn func(typ string, chunk []byte, body []byte)) error {
+	if len(data) < 8 {
+		return errors.New("invalid PNG")
+	}
+
+	for offset := 8; offset < len(data); {
+		if offset+12 > len(data) {
+			return errors.New("truncated PNG chunk")
+		}
+
+		length := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		end := offset + 12 + length
+		if length < 0 || end > len(data) {
+			return errors.New("truncated PNG chunk")
+		}
+
+		typ := string(data[offset+4 : offset+8])
+		fn(typ, data[offset:end], data[offset+8:offset+8+length])
+		offset = end
+
+		if typ == "IEND" {
+			break
+		}
+	}
+
+	return nil
+}
+
+// WebP is a RIFF container: "RIFF", size, "WEBP", then chunks padded to an
+// even size. The VP8X chunk flags which metadata chunks are present.
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+func webpEXIF(data []byte) []byte {
+	var payload []byte
+	walkWebP(data, func(fourCC string, chunk []byte, body []byte) {
+		if fourCC == "EXIF" && payload == nil {
+			payload = body
+		}
+	})
+	return payload
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+
+	err := walkWebP(data, func(fourCC string, chunk []byte, body []byte) {
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, chunk...)
+			if len(body) > 0 {
+				out[start+8] &^= webpFlagEXIF | webpFlagXMP
+			}
+		default:
+			out = append(out, chunk...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+func walkWebP(data []byte, fn func(fourCC string, chunk []byte, body []byte)) error {
+	if len(data) < 12 {
+		return errors.New("invalid WebP")
+	}
+
+	for offset := 12; offset < len(data); {
+		if offset+8 > len(data) {
+			return errors.New("truncated WebP chunk")
+		}
+
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		end := offset + 8 + size + size%2
+		if size < 0 || offset+8+size > len(data) {
+			return errors.New("truncated WebP chunk")
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+
+		fn(string(data[offset:offset+4]), data[offset:end], data[offset+8:offset+8+size])
+		offset = end
+	}
+
+	return nil
+}
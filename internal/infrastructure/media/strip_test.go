@@ -0,0 +1,144 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testEXIF returns TIFF data with an IFD0 holding only the orientation
+func testEXIF(orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, tagOrientation)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	return binary.LittleEndian.AppendUint32(tiff, 0)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func testJPEG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width/2; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.White)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSanitizeJPEGDropsMetadataAndTrailingImages(t *testing.T) {
+	original := testJPEG(t, 16, 8)
+
+	// EXIF, XMP-like APP1, MPF and a comment after SOI, a second image after EOI
+	var data []byte
+	data = append(data, original[:2]...)
+	data = append(data, jpegSegment(jpegMarkerAPP1, append(append([]byte{}, exifPrefix...), testEXIF(1)...))...)
+	data = append(data, jpegSegment(jpegMarkerAPP2, []byte("MPF\x00secret"))...)
+	data = append(data, jpegSegment(0xE5, []byte("maker notes"))...)
+	data = append(data, jpegSegment(jpegMarkerCOM, []byte("comment"))...)
+	data = append(data, original[2:]...)
+	data = append(data, original...)
+
+	sanitized, err := Sanitize(data, &ImageInfo{ContentType: "image/jpeg", Width: 16, Height: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, leaked := range []string{"Exif", "MPF", "maker notes", "comment"} {
+		if bytes.Contains(sanitized.Data, []byte(leaked)) {
+			t.Errorf("sanitized JPEG still contains %q", leaked)
+		}
+	}
+	if bytes.Count(sanitized.Data, []byte{0xFF, 0xD8}) != 1 || !bytes.HasSuffix(sanitized.Data, []byte{0xFF, jpegMarkerEOI}) {
+		t.Error("sanitized JPEG must hold a single image ending with EOI")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(sanitized.Data)); err != nil {
+		t.Fatalf("sanitized JPEG does not decode: %v", err)
+	}
+}
+
+func TestSanitizeJPEGAppliesOrientation(t *testing.T) {
+	original := testJPEG(t, 16, 8)
+	data := append(append([]byte{}, original[:2]...), jpegSegment(jpegMarkerAPP1, append(append([]byte{}, exifPrefix...), testEXIF(6)...))...)
+	data = append(data, original[2:]...)
+
+	sanitized, err := Sanitize(data, &ImageInfo{ContentType: "image/jpeg", Width: 16, Height: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sanitized.Width != 8 || sanitized.Height != 16 {
+		t.Errorf("size = %dx%d, want 8x16", sanitized.Width, sanitized.Height)
+	}
+	if sanitized.Metadata.Orientation != 6 || bytes.Contains(sanitized.Data, []byte("Exif")) {
+		t.Error("orientation must be read and the EXIF segment dropped")
+	}
+}
+
+// testHEIC builds the boxes of a HEIC file whose Exif item is stored in mdat
+func testHEIC(exif []byte) []byte {
+	box := func(typ string, payload ...[]byte) []byte {
+		content := bytes.Join(payload, nil)
+		out := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+		return append(append(out, typ...), content...)
+	}
+
+	ftyp := box("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	infe := box("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif"), []byte{0})
+	iinf := box("iinf", []byte{0, 0, 0, 0, 0, 1}, infe)
+
+	// The Exif item starts with the offset of the TIFF header
+	item := append([]byte{0, 0, 0, 6}, append(append([]byte{}, exifPrefix...), exif...)...)
+	ilocSize := 8 + 4 + 2 + 2 + 2 + 2 + 2 + 4 + 4
+	metaSize := 8 + 4 + len(iinf) + ilocSize
+	itemOffset := len(ftyp) + metaSize + 8
+
+	iloc := []byte{0, 0, 0, 0, 0x44, 0x00, 0, 1, 0, 1, 0, 0, 0, 1}
+	iloc = binary.BigEndian.AppendUint32(iloc, uint32(itemOffset))
+	iloc = binary.BigEndian.AppendUint32(iloc, uint32(len(item)))
+
+	meta := box("meta", []byte{0, 0, 0, 0}, iinf, box("iloc", iloc))
+	return bytes.Join([][]byte{ftyp, meta, box("mdat", item, []byte("image data"))}, nil)
+}
+
+func TestSanitizeHEICZeroesEXIF(t *testing.T) {
+	data := testHEIC(testEXIF(3))
+
+	metadata, err := ReadMetadata(data, "image/heic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Orientation != 1 {
+		t.Errorf("HEIC orientation = %d, want 1", metadata.Orientation)
+	}
+
+	sanitized, err := Sanitize(data, &ImageInfo{ContentType: "image/heic", Width: 1, Height: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sanitized.Data) != len(data) || bytes.Contains(sanitized.Data, []byte("II*")) {
+		t.Error("the Exif item must be zeroed in place")
+	}
+	if !bytes.HasSuffix(sanitized.Data, []byte("image data")) {
+		t.Error("the image data must be kept")
+	}
+	if !bytes.Contains(data, []byte("II*")) {
+		t.Error("the original must not be modified")
+	}
+}
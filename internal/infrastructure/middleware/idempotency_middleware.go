@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"indicar-api/internal/application/services"
+	"indicar-api/internal/domain/entities"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+
+	// DefaultMaxIdempotentBodySize is the body limit of JSON requests sent
+	// with an idempotency key
+	DefaultMaxIdempotentBodySize = 1024 * 1024
+	// Bodies up to this size are kept in memory while the request runs;
+	// larger ones, such as file uploads, are spooled to a temporary file
+	maxInMemoryBodySize = 1024 * 1024
+)
+
+// IdempotencyMiddleware makes POST and PATCH requests sent with an
+// Idempotency-Key header safe to retry: the response to the first request is
+// stored per key and user and replayed for retries of the same request. A key
+// reused for a different request, or while the first one is still running, is
+// refused with 409. Server errors are not stored, so that they can be retried.
+// Bodies larger than maxBodySize are refused with 413. It must run after
+// AuthMiddleware.
+func IdempotencyMiddleware(idempotencyService *services.IdempotencyService, maxBodySize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		method := c.Request.Method
+		userID := c.GetInt("user_id")
+		if key == "" || userID == 0 || (method != http.MethodPost && method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			c.Abort()
+			return
+		}
+
+		hash := sha256.New()
+		io.WriteString(hash, method+" "+c.Request.URL.RequestURI()+"\n")
+
+		body, err := spoolBody(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize), hash)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			}
+			c.Abort()
+			return
+		}
+		defer body.Close()
+		c.Request.Body = body
+
+		record, err := idempotencyService.Begin(userID, key, hex.EncodeToString(hash.Sum(nil)))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrIdempotencyKeyReused) || errors.Is(err, services.ErrIdempotencyKeyInProgress) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if record.Status == entities.IdempotencyKeyStatusCompleted {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.ResponseStatus, record.ResponseContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		stored := false
+		defer func() {
+			if stored {
+				return
+			}
+			if err := idempotencyService.Release(record); err != nil {
+				log.Printf("failed to release idempotency key %d: %v", record.ID, err)
+			}
+		}()
+
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		if err := idempotencyService.Complete(record, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			log.Printf("failed to store response of idempotency key %d: %v", record.ID, err)
+			return
+		}
+		stored = true
+	}
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// spoolBody reads a request body, writing it to hash as well, and returns a
+// reader replaying it. Closing the reader removes its temporary file, if any.
+func spoolBody(body io.Reader, hash io.Writer) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.TeeReader(io.LimitReader(body, maxInMemoryBodySize+1), hash))
+	if err != nil {
+		return nil, err
+	}
+	if n <= maxInMemoryBodySize {
+		return io.NopCloser(&buf), nil
+	}
+
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &spooledBody{File: file}
+
+	if _, err := buf.WriteTo(file); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// spooledBody is a request body stored in a temporary file, removed once
+// closed. Handlers may close the body too, so closing twice is harmless.
+type spooledBody struct {
+	*os.File
+	once sync.Once
+}
+
+func (b *spooledBody) Close() error {
+	var err error
+	b.once.Do(func() {
+		err = b.File.Close()
+		os.Remove(b.File.Name())
+	})
+	return err
+}
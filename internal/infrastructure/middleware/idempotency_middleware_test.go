@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"indicar-api/internal/application/services"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyMiddlewareRejectsLargeBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", 1) })
+	// The body is refused before the service is used
+	router.Use(IdempotencyMiddleware(services.NewIdempotencyService(nil), 16))
+	router.POST("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 17)))
+	request.Header.Set(IdempotencyKeyHeader, "key")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", response.Code)
+	}
+}
+
+func TestSpoolBody(t *testing.T) {
+	for _, size := range []int{0, 10, maxInMemoryBodySize, maxInMemoryBodySize + 10} {
+		content := bytes.Repeat([]byte("a"), size)
+		hash := sha256.New()
+
+		body, err := spoolBody(bytes.NewReader(content), hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replayed, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(replayed, content) {
+			t.Errorf("size %d: replayed body differs", size)
+		}
+		if sum := sha256.Sum256(content); !bytes.Equal(hash.Sum(nil), sum[:]) {
+			t.Errorf("size %d: hash differs", size)
+		}
+
+		spooled, isFile := body.(*spooledBody)
+		if isFile != (size > maxInMemoryBodySize) {
+			t.Errorf("size %d: spooled to a file = %v", size, isFile)
+		}
+		body.Close()
+		body.Close()
+		if isFile {
+			if _, err := os.Stat(spooled.Name()); !os.IsNotExist(err) {
+				t.Errorf("temporary file %s was not removed", spooled.Name())
+			}
+		}
+	}
+}
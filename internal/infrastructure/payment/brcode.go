@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// BRCode is the payload of a Pix QR code ("copia e cola"), in the EMV
+// merchant-presented format defined by the Brazilian Central Bank: a list of
+// ID/length/value fields ending with a CRC16 checksum.
+type BRCode struct {
+	Key          string
+	MerchantName string
+	MerchantCity string
+	AmountCents  int
+	// TxID identifies the charge: up to 25 letters and digits
+	TxID string
+}
+
+// Payload encodes the BR Code
+func (c BRCode) Payload() string {
+	var b strings.Builder
+
+	b.WriteString(emvField("00", "01"))
+	// Point of initiation 12: the code is meant to be paid once
+	b.WriteString(emvField("01", "12"))
+	b.WriteString(emvField("26", emvField("00", "br.gov.bcb.pix")+emvField("01", c.Key)))
+	b.WriteString(emvField("52", "0000"))
+	b.WriteString(emvField("53", "986")) // BRL
+	if c.AmountCents > 0 {
+		b.WriteString(emvField("54", fmt.Sprintf("%d.%02d", c.AmountCents/100, c.AmountCents%100)))
+	}
+	b.WriteString(emvField("58", "BR"))
+	b.WriteString(emvField("59", emvText(c.MerchantName, 25)))
+	b.WriteString(emvField("60", emvText(c.MerchantCity, 15)))
+
+	txID := pixTxID(c.TxID)
+	if txID == "" {
+		txID = "***"
+	}
+	b.WriteString(emvField("62", emvField("05", txID)))
+
+	// The checksum covers everything up to and including its own ID and length
+	b.WriteString("6304")
+	return b.String() + fmt.Sprintf("%04X", crc16CCITT([]byte(b.String())))
+}
+
+func emvField(id string, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// emvText keeps the ASCII letters, digits and spaces of a name, without
+// accents, as most bank apps reject anything else
+func emvText(value string, maxLength int) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(value) {
+		switch {
+		case r > unicode.MaxASCII:
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == ' ':
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+
+	text := strings.TrimSpace(b.String())
+	if len(text) > maxLength {
+		text = strings.TrimSpace(text[:maxLength])
+	}
+	return text
+}
+
+func pixTxID(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+
+	txID := b.String()
+	if len(txID) > 25 {
+		txID = txID[:25]
+	}
+	return txID
+}
+
+// crc16CCITT is CRC-16/CCITT-FALSE: polynomial 0x1021, initial value 0xFFFF
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,44 @@
+package payment
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCRC16CCITT(t *testing.T) {
+	// Check value of CRC-16/CCITT-FALSE
+	if got := crc16CCITT([]byte("123456789")); got != 0x29B1 {
+		t.Fatalf("crc16CCITT(123456789) = %04X, want 29B1", got)
+	}
+}
+
+func TestBRCodePayload(t *testing.T) {
+	payload := BRCode{
+		Key:          "financeiro@indicar.com.br",
+		MerchantName: "Indicar Avaliações",
+		MerchantCity: "São Paulo",
+		AmountCents:  19900,
+		TxID:         "evaluation-42-1",
+	}.Payload()
+
+	body, checksum := payload[:len(payload)-4], payload[len(payload)-4:]
+	if !strings.HasSuffix(body, "6304") {
+		t.Fatalf("payload does not end with the CRC field: %s", payload)
+	}
+	if want := fmt.Sprintf("%04X", crc16CCITT([]byte(body))); checksum != want {
+		t.Errorf("checksum = %s, want %s", checksum, want)
+	}
+
+	for _, field := range []string{
+		"0014br.gov.bcb.pix0125financeiro@indicar.com.br",
+		"5406199.00",
+		"5918INDICAR AVALIACOES",
+		"6009SAO PAULO",
+		"0513evaluation421",
+	} {
+		if !strings.Contains(payload, field) {
+			t.Errorf("payload %s is missing %s", payload, field)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const cnab240RecordSize = 240
+
+// CNAB240Remittance is a payment remittance file in the FEBRABAN CNAB 240
+// layout, paying each transfer by Pix to the recipient's key: a file header,
+// one batch (header, segments A and B per transfer, trailer) and a file
+// trailer, each a 240-character record ending with CRLF.
+type CNAB240Remittance struct {
+	// Paying company and its account at the bank receiving the file
+	CompanyName     string
+	CompanyDocument string
+	BankCode        string
+	BankName        string
+	// Agreement ("convênio") of the company with the bank
+	Agreement    string
+	Agency       string
+	AgencyDigit  string
+	Account      string
+	AccountDigit string
+
+	// Sequence is the file number (NSA); banks reject a number already received
+	Sequence    int
+	CreatedAt   time.Time
+	PaymentDate time.Time
+	Transfers   []PixTransfer
+}
+
+// PixTransfer is a payment to a Pix key
+type PixTransfer struct {
+	RecipientName     string
+	RecipientDocument string
+	PixKey            string
+	AmountCents       int
+	// Reference identifies the transfer in the bank's return file: up to 20 characters
+	Reference string
+}
+
+// Encode writes the remittance file
+func (r CNAB240Remittance) Encode() ([]byte, error) {
+	companyType, companyDocument := cnabDocument(r.CompanyDocument)
+	if companyType == "0" || len(r.BankCode) != 3 || r.Agency == "" || r.Account == "" {
+		return nil, errors.New("company document, bank code, agency and account are required")
+	}
+
+	var records []string
+	account := cnabNumber(r.Agency, 5) + cnabAlpha(r.AgencyDigit, 1) +
+		cnabNumber(r.Account, 12) + cnabAlpha(r.AccountDigit, 1) + " "
+
+	// File header
+	records = append(records, r.BankCode+"0000"+"0"+cnabBlank(9)+
+		companyType+companyDocument+cnabAlpha(r.Agreement, 20)+account+
+		cnabAlpha(r.CompanyName, 30)+cnabAlpha(r.BankName, 30)+cnabBlank(10)+
+		"1"+r.CreatedAt.Format("02012006")+r.CreatedAt.Format("150405")+
+		cnabInt(r.Sequence, 6)+"103"+"00000"+cnabBlank(20)+cnabBlank(20)+cnabBlank(29))
+
+	if len(r.Transfers) > 0 {
+		const batch = "0001"
+
+		// Batch header: service 20 (payments to suppliers), method 45 (Pix transfer)
+		records = append(records, r.BankCode+batch+"1"+"C"+"20"+"45"+"046"+" "+
+			companyType+companyDocument+cnabAlpha(r.Agreement, 20)+account+
+			cnabAlpha(r.CompanyName, 30)+cnabBlank(40)+
+			cnabBlank(30)+cnabBlank(5)+cnabBlank(15)+cnabBlank(20)+cnabBlank(5)+cnabBlank(3)+cnabBlank(2)+
+			cnabBlank(2)+cnabBlank(6)+cnabBlank(10))
+
+		total := 0
+		for i, transfer := range r.Transfers {
+			if transfer.AmountCents <= 0 {
+				return nil, fmt.Errorf("invalid amount for transfer %s", transfer.Reference)
+			}
+			keyType := pixKeyType(transfer.PixKey)
+			if keyType == "" {
+				return nil, fmt.Errorf("invalid Pix key for transfer %s", transfer.Reference)
+			}
+			recipientType, recipientDocument := cnabDocument(transfer.RecipientDocument)
+			total += transfer.AmountCents
+
+			// Segment A: clearing 009 (SPI); the recipient's bank account is
+			// not needed when paying to a key
+			records = append(records, r.BankCode+batch+"3"+cnabInt(2*i+1, 5)+"A"+"0"+"00"+"009"+
+				cnabInt(0, 3)+cnabInt(0, 5)+" "+cnabInt(0, 12)+" "+" "+
+				cnabAlpha(transfer.RecipientName, 30)+cnabAlpha(transfer.Reference, 20)+
+				r.PaymentDate.Format("02012006")+"BRL"+cnabInt(0, 15)+cnabInt(transfer.AmountCents, 15)+
+				cnabBlank(20)+cnabInt(0, 8)+cnabInt(0, 15)+cnabBlank(40)+
+				cnabBlank(2)+cnabBlank(5)+cnabBlank(2)+cnabBlank(3)+"0"+cnabBlank(10))
+
+			// Segment B: how the Pix transfer is initiated and the key
+			records = append(records, r.BankCode+batch+"3"+cnabInt(2*i+2, 5)+"B"+
+				cnabAlpha(keyType, 3)+recipientType+recipientDocument+cnabBlank(35)+
+				cnabBlank(60)+cnabKey(transfer.PixKey, 99)+cnabBlank(6)+cnabBlank(8))
+		}
+
+		// Batch trailer: the count includes the batch header and trailer
+		records = append(records, r.BankCode+batch+"5"+cnabBlank(9)+
+			cnabInt(2*len(r.Transfers)+2, 6)+cnabInt(total, 18)+cnabInt(0, 18)+cnabInt(0, 6)+
+			cnabBlank(165)+cnabBlank(10))
+	}
+
+	// File trailer
+	batches := 0
+	if len(r.Transfers) > 0 {
+		batches = 1
+	}
+	records = append(records, r.BankCode+"9999"+"9"+cnabBlank(9)+
+		cnabInt(batches, 6)+cnabInt(len(records)+1, 6)+cnabInt(0, 6)+cnabBlank(205))
+
+	var b strings.Builder
+	for _, record := range records {
+		if len(record) != cnab240RecordSize {
+			return nil, fmt.Errorf("CNAB record of %d characters: %q", len(record), record)
+		}
+		b.WriteString(record)
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// pixKeyType returns the initiation code of a Pix key in segment B: 01 phone,
+// 02 e-mail, 03 CPF/CNPJ and 04 random key
+func pixKeyType(key string) string {
+	switch {
+	case strings.HasPrefix(key, "+") && len(key) > 1 && isDigits(key[1:]):
+		return "01"
+	case strings.Contains(key, "@"):
+		return "02"
+	case (len(key) == 11 || len(key) == 14) && isDigits(key):
+		return "03"
+	case len(key) == 36 && strings.Count(key, "-") == 4:
+		return "04"
+	default:
+		return ""
+	}
+}
+
+// cnabDocument returns the registration type (1 CPF, 2 CNPJ, 0 none) and the
+// 14-digit number of a document
+func cnabDocument(document string) (string, string) {
+	digits := onlyDigits(document)
+	switch len(digits) {
+	case 11:
+		return "1", cnabNumber(digits, 14)
+	case 14:
+		return "2", digits
+	default:
+		return "0", cnabInt(0, 14)
+	}
+}
+
+// cnabAlpha writes an alphanumeric field: uppercase ASCII without accents,
+// padded with spaces on the right
+func cnabAlpha(value string, width int) string {
+	return cnabPad(strings.ToUpper(value), width)
+}
+
+// cnabKey writes a Pix key keeping its case, which matters to some banks for
+// e-mail keys
+func cnabKey(value string, width int) string {
+	return cnabPad(value, width)
+}
+
+func cnabPad(value string, width int) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(value) {
+		if r >= ' ' && r < unicode.MaxASCII {
+			b.WriteRune(r)
+		}
+	}
+
+	text := b.String()
+	if len(text) > width {
+		return text[:width]
+	}
+	return text + strings.Repeat(" ", width-len(text))
+}
+
+func cnabBlank(width int) string {
+	return strings.Repeat(" ", width)
+}
+
+// cnabNumber writes the digits of a numeric field, padded with zeros on the left
+func cnabNumber(value string, width int) string {
+	digits := onlyDigits(value)
+	if len(digits) > width {
+		digits = digits[len(digits)-width:]
+	}
+	return strings.Repeat("0", width-len(digits)) + digits
+}
+
+func cnabInt(value int, width int) string {
+	return cnabNumber(fmt.Sprint(value), width)
+}
+
+func onlyDigits(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isDigits(value string) bool {
+	return value != "" && onlyDigits(value) == value
+}
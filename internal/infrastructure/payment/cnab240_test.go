@@ -0,0 +1,133 @@
+package payment
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCNAB240RemittanceEncode(t *testing.T) {
+	date := time.Date(2026, 10, 19, 14, 30, 5, 0, time.UTC)
+	content, err := CNAB240Remittance{
+		CompanyName:     "Indicar Avaliações Ltda",
+		CompanyDocument: "12.345.678/0001-95",
+		BankCode:        "341",
+		BankName:        "Banco Itaú",
+		Agreement:       "123456",
+		Agency:          "1234",
+		AgencyDigit:     "5",
+		Account:         "98765",
+		AccountDigit:    "4",
+		Sequence:        42,
+		CreatedAt:       date,
+		PaymentDate:     date,
+		Transfers: []PixTransfer{
+			{RecipientName: "João da Silva", RecipientDocument: "529.982.247-25", PixKey: "Joao@example.com", AmountCents: 12345, Reference: "REPASSE-1"},
+			{RecipientName: "Maria Souza", PixKey: "+5511987654321", AmountCents: 5000, Reference: "REPASSE-2"},
+		},
+	}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(string(content), "\r\n") {
+		t.Fatal("file does not end with CRLF")
+	}
+	records := strings.Split(strings.TrimSuffix(string(content), "\r\n"), "\r\n")
+	if len(records) != 8 {
+		t.Fatalf("got %d records, want 8", len(records))
+	}
+
+	for i, record := range records {
+		if len(record) != 240 {
+			t.Errorf("record %d has %d characters", i+1, len(record))
+		}
+	}
+
+	// Record type (position 8) and segment (position 14)
+	types := []string{"0", "1", "3A", "3B", "3A", "3B", "5", "9"}
+	for i, want := range types {
+		got := records[i][7:8]
+		if got == "3" {
+			got += records[i][13:14]
+		}
+		if got != want {
+			t.Errorf("record %d is of type %s, want %s", i+1, got, want)
+		}
+	}
+
+	fields := []struct {
+		record     int
+		start, end int
+		want       string
+	}{
+		{0, 1, 3, "341"},
+		{0, 18, 32, "212345678000195"},
+		{0, 73, 102, "INDICAR AVALIACOES LTDA       "},
+		{0, 144, 157, "19102026143005"},
+		{0, 158, 163, "000042"},
+		{1, 12, 13, "45"},
+		{2, 18, 20, "009"},
+		{2, 44, 73, "JOAO DA SILVA                 "},
+		{2, 74, 93, "REPASSE-1           "},
+		{2, 120, 134, "000000000012345"},
+		{3, 15, 17, "02 "},
+		{3, 18, 32, "100052998224725"},
+		{3, 128, 143, "Joao@example.com"},
+		{5, 15, 17, "01 "},
+		{5, 18, 18, "0"},
+		{6, 18, 23, "000006"},
+		{6, 24, 41, "000000000000017345"},
+		{7, 18, 23, "000001"},
+		{7, 24, 29, "000008"},
+	}
+	for _, field := range fields {
+		if got := records[field.record][field.start-1 : field.end]; got != field.want {
+			t.Errorf("record %d, positions %d-%d = %q, want %q", field.record+1, field.start, field.end, got, field.want)
+		}
+	}
+}
+
+func TestCNAB240RemittanceEncodeEmpty(t *testing.T) {
+	content, err := CNAB240Remittance{
+		CompanyDocument: "12345678000195",
+		BankCode:        "001",
+		Agency:          "1",
+		Account:         "2",
+	}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := strings.Split(strings.TrimSuffix(string(content), "\r\n"), "\r\n")
+	if len(records) != 2 || records[1][17:29] != "000000000002" {
+		t.Errorf("records = %q", records)
+	}
+}
+
+func TestCNAB240RemittanceEncodeInvalid(t *testing.T) {
+	remittance := CNAB240Remittance{CompanyDocument: "12345678000195", BankCode: "001", Agency: "1", Account: "2"}
+	if _, err := (CNAB240Remittance{BankCode: "001", Agency: "1", Account: "2"}).Encode(); err == nil {
+		t.Error("Encode accepted a remittance without the company document")
+	}
+
+	remittance.Transfers = []PixTransfer{{RecipientName: "X", PixKey: "not a key", AmountCents: 100}}
+	if _, err := remittance.Encode(); err == nil {
+		t.Error("Encode accepted an invalid Pix key")
+	}
+}
+
+func TestPixKeyType(t *testing.T) {
+	for key, want := range map[string]string{
+		"+5511987654321":                       "01",
+		"pix@example.com":                      "02",
+		"52998224725":                          "03",
+		"12345678000195":                       "03",
+		"123e4567-e89b-12d3-a456-426614174000": "04",
+		"5511987654321":                        "",
+	} {
+		if got := pixKeyType(key); got != want {
+			t.Errorf("pixKeyType(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Amounts ending in these cents make the fake provider fail, like the test
+// cards of real providers
+const (
+	FakeDeclinedCents = 2  // e.g. 100.02: the charge is declined
+	FakePendingCents  = 99 // e.g. 100.99: the charge stays pending
+)
+
+// FakeProvider is a deterministic provider for development and tests. Charge
+// IDs are derived from the reference, charges are authorized immediately
+// unless the amount says otherwise, and nothing leaves the machine: charges
+// are kept in a state file, so that they survive restarts. It also stands in
+// for a Pix PSP: its BR Codes are valid but are only marked paid through
+// PayPix.
+type FakeProvider struct {
+	mu        sync.Mutex
+	charges   map[string]*Charge
+	pix       map[string]*PixCode
+	merchant  PixMerchant
+	stateFile string
+}
+
+// fakeState is the content of the state file of the fake provider
+type fakeState struct {
+	Charges map[string]*Charge  `json:"charges"`
+	Pix     map[string]*PixCode `json:"pix"`
+}
+
+// PixMerchant is the receiving account written into BR Codes
+type PixMerchant struct {
+	Key  string
+	Name string
+	City string
+}
+
+// NewFakeProvider creates a fake provider keeping its charges in stateFile,
+// loaded if it exists. Charges are only kept in memory when stateFile is
+// empty.
+func NewFakeProvider(merchant PixMerchant, stateFile string) (*FakeProvider, error) {
+	p := &FakeProvider{
+		charges:   make(map[string]*Charge),
+		pix:       make(map[string]*PixCode),
+		merchant:  merchant,
+		stateFile: stateFile,
+	}
+	if stateFile == "" {
+		return p, nil
+	}
+
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := fakeState{Charges: p.charges, Pix: p.pix}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.Charges != nil {
+		p.charges = state.Charges
+	}
+	if state.Pix != nil {
+		p.pix = state.Pix
+	}
+	return p, nil
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateCharge(input ChargeInput) (*Charge, error) {
+	if input.AmountCents <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := "fake_ch_" + input.Reference
+	if charge, ok := p.charges[id]; ok {
+		copied := *charge
+		return &copied, nil
+	}
+
+	charge := &Charge{
+		ID:          id,
+		Status:      ChargeStatusAuthorized,
+		AmountCents: input.AmountCents,
+		Currency:    input.Currency,
+	}
+
+	switch input.AmountCents % 100 {
+	case FakeDeclinedCents:
+		charge.Status = ChargeStatusFailed
+	case FakePendingCents:
+		charge.Status = ChargeStatusPending
+	}
+
+	p.charges[id] = charge
+	if err := p.save(); err != nil {
+		delete(p.charges, id)
+		return nil, err
+	}
+
+	copied := *charge
+	return &copied, nil
+}
+
+func (p *FakeProvider) CreatePixCharge(input ChargeInput, expiresIn time.Duration) (*Charge, *PixCode, error) {
+	if input.AmountCents <= 0 {
+		return nil, nil, ErrInvalidAmount
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := "fake_pix_" + input.Reference
+	if charge, ok := p.charges[id]; ok {
+		copied, code := *charge, *p.pix[id]
+		return &copied, &code, nil
+	}
+
+	charge := &Charge{
+		ID:          id,
+		Status:      ChargeStatusPending,
+		AmountCents: input.AmountCents,
+		Currency:    input.Currency,
+	}
+
+	txID := pixTxID(input.Reference)
+	code := &PixCode{
+		TxID: txID,
+		Payload: BRCode{
+			Key:          p.merchant.Key,
+			MerchantName: p.merchant.Name,
+			MerchantCity: p.merchant.City,
+			AmountCents:  input.AmountCents,
+			TxID:         txID,
+		}.Payload(),
+		ExpiresAt: time.Now().Add(expiresIn),
+	}
+
+	p.charges[id] = charge
+	p.pix[id] = code
+	if err := p.save(); err != nil {
+		delete(p.charges, id)
+		delete(p.pix, id)
+		return nil, nil, err
+	}
+
+	copied, copiedCode := *charge, *code
+	return &copied, &copiedCode, nil
+}
+
+// PayPix simulates the customer paying a Pix charge
+func (p *FakeProvider) PayPix(chargeID string) (*Charge, error) {
+	return p.update(chargeID, func(charge *Charge) error {
+		if _, ok := p.pix[chargeID]; !ok || charge.Status != ChargeStatusPending {
+			return ErrInvalidState
+		}
+		charge.Status = ChargeStatusPaid
+		return nil
+	})
+}
+
+func (p *FakeProvider) Capture(chargeID string) (*Charge, error) {
+	return p.update(chargeID, func(charge *Charge) error {
+		switch charge.Status {
+		case ChargeStatusPaid:
+			return nil
+		case ChargeStatusAuthorized:
+			charge.Status = ChargeStatusPaid
+			return nil
+		default:
+			return ErrInvalidState
+		}
+	})
+}
+
+func (p *FakeProvider) Cancel(chargeID string) (*Charge, error) {
+	return p.update(chargeID, func(charge *Charge) error {
+		switch charge.Status {
+		case ChargeStatusCanceled:
+			return nil
+		case ChargeStatusAuthorized, ChargeStatusPending:
+			charge.Status = ChargeStatusCanceled
+			return nil
+		default:
+			return ErrInvalidState
+		}
+	})
+}
+
+func (p *FakeProvider) Refund(chargeID string, amountCents int) (*Charge, error) {
+	return p.update(chargeID, func(charge *Charge) error {
+		if charge.Status != ChargeStatusPaid {
+			return ErrInvalidState
+		}
+		if amountCents <= 0 || charge.RefundedCents+amountCents > charge.AmountCents {
+			return ErrInvalidAmount
+		}
+
+		charge.RefundedCents += amountCents
+		if charge.RefundedCents == charge.AmountCents {
+			charge.Status = ChargeStatusRefunded
+		}
+		return nil
+	})
+}
+
+func (p *FakeProvider) GetCharge(chargeID string) (*Charge, error) {
+	return p.update(chargeID, func(charge *Charge) error { return nil })
+}
+
+func (p *FakeProvider) update(chargeID string, fn func(charge *Charge) error) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[chargeID]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+
+	// Changes are made to a copy, kept only once saved
+	updated := *charge
+
+	// Unpaid Pix codes expire
+	if code, ok := p.pix[chargeID]; ok && updated.Status == ChargeStatusPending && time.Now().After(code.ExpiresAt) {
+		updated.Status = ChargeStatusExpired
+	}
+
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+
+	if updated != *charge {
+		previous := *charge
+		*charge = updated
+		if err := p.save(); err != nil {
+			*charge = previous
+			return nil, err
+		}
+	}
+
+	return &updated, nil
+}
+
+// save writes the charges to the state file, through a temporary file so
+// that a crash never leaves it partially written
+func (p *FakeProvider) save() error {
+	if p.stateFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(fakeState{Charges: p.charges, Pix: p.pix})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(p.stateFile)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".fake-psp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p.stateFile)
+}
@@ -0,0 +1,173 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFakeProvider(t *testing.T, stateFile string) *FakeProvider {
+	t.Helper()
+	provider, err := NewFakeProvider(PixMerchant{Key: "financeiro@indicar.com.br", Name: "INDICAR", City: "SAO PAULO"}, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestFakeProviderChargeStatusFromAmount(t *testing.T) {
+	provider := newTestFakeProvider(t, "")
+
+	tests := []struct {
+		amount int
+		want   ChargeStatus
+	}{
+		{19900, ChargeStatusAuthorized},
+		{10002, ChargeStatusFailed},
+		{10099, ChargeStatusPending},
+	}
+	for i, test := range tests {
+		charge, err := provider.CreateCharge(ChargeInput{Reference: fmt.Sprintf("evaluation-%d-1", i), AmountCents: test.amount})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if charge.Status != test.want {
+			t.Errorf("charge of %d cents is %s, want %s", test.amount, charge.Status, test.want)
+		}
+	}
+}
+
+func TestFakeProviderIsIdempotentByReference(t *testing.T) {
+	provider := newTestFakeProvider(t, "")
+
+	first, err := provider.CreateCharge(ChargeInput{Reference: "evaluation-1-1", AmountCents: 19900})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Capture(first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := provider.CreateCharge(ChargeInput{Reference: "evaluation-1-1", AmountCents: 19900})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || again.Status != ChargeStatusPaid {
+		t.Errorf("repeated charge = %+v, want the captured charge %s", again, first.ID)
+	}
+}
+
+func TestFakeProviderCaptureAndRefund(t *testing.T) {
+	provider := newTestFakeProvider(t, "")
+
+	charge, err := provider.CreateCharge(ChargeInput{Reference: "evaluation-1-1", AmountCents: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Refund(charge.ID, 1000); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("refund of an authorized charge: err = %v, want ErrInvalidState", err)
+	}
+
+	if _, err := provider.Capture(charge.ID); err != nil {
+		t.Fatal(err)
+	}
+	if charge, err = provider.Refund(charge.ID, 4000); err != nil || charge.Status != ChargeStatusPaid || charge.RefundedCents != 4000 {
+		t.Fatalf("partial refund = %+v, %v", charge, err)
+	}
+	if _, err := provider.Refund(charge.ID, 6001); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("refund above the amount left: err = %v, want ErrInvalidAmount", err)
+	}
+	if charge, err = provider.Refund(charge.ID, 6000); err != nil || charge.Status != ChargeStatusRefunded {
+		t.Fatalf("full refund = %+v, %v", charge, err)
+	}
+}
+
+func TestFakeProviderCancel(t *testing.T) {
+	provider := newTestFakeProvider(t, "")
+
+	card, err := provider.CreateCharge(ChargeInput{Reference: "evaluation-1-1", AmountCents: 19900})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card, err = provider.Cancel(card.ID); err != nil || card.Status != ChargeStatusCanceled {
+		t.Fatalf("canceled authorized charge = %+v, %v", card, err)
+	}
+	if _, err := provider.Capture(card.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("capture of a canceled charge: err = %v, want ErrInvalidState", err)
+	}
+
+	pix, _, err := provider.CreatePixCharge(ChargeInput{Reference: "evaluation-2-1", AmountCents: 19900}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Cancel(pix.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.PayPix(pix.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("payment of a canceled Pix charge: err = %v, want ErrInvalidState", err)
+	}
+
+	paid, err := provider.CreateCharge(ChargeInput{Reference: "evaluation-3-1", AmountCents: 19900})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Capture(paid.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Cancel(paid.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("cancel of a captured charge: err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestFakeProviderPixExpires(t *testing.T) {
+	provider := newTestFakeProvider(t, "")
+
+	charge, code, err := provider.CreatePixCharge(ChargeInput{Reference: "evaluation-1-1", AmountCents: 19900}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.TxID != "evaluation11" {
+		t.Errorf("txid = %s, want evaluation11", code.TxID)
+	}
+
+	if charge, err = provider.GetCharge(charge.ID); err != nil || charge.Status != ChargeStatusExpired {
+		t.Fatalf("expired Pix charge = %+v, %v", charge, err)
+	}
+	if _, err := provider.PayPix(charge.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("paying an expired Pix charge: err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestFakeProviderKeepsChargesAcrossRestarts(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "fake-psp.json")
+
+	provider := newTestFakeProvider(t, stateFile)
+	charge, err := provider.CreateCharge(ChargeInput{Reference: "evaluation-1-1", AmountCents: 19900})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pix, _, err := provider.CreatePixCharge(ChargeInput{Reference: "evaluation-2-1", AmountCents: 19900}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Capture(charge.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newTestFakeProvider(t, stateFile)
+	if charge, err = restarted.Refund(charge.ID, 900); err != nil || charge.RefundedCents != 900 {
+		t.Fatalf("refund after restart = %+v, %v", charge, err)
+	}
+	if pix, err = restarted.PayPix(pix.ID); err != nil || pix.Status != ChargeStatusPaid {
+		t.Fatalf("Pix payment after restart = %+v, %v", pix, err)
+	}
+}
+
+func TestFakeProviderUnknownCharge(t *testing.T) {
+	provider := newTestFakeProvider(t, "")
+	if _, err := provider.GetCharge("fake_ch_missing"); !errors.Is(err, ErrChargeNotFound) {
+		t.Errorf("err = %v, want ErrChargeNotFound", err)
+	}
+}
@@ -0,0 +1,74 @@
+package payment
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrChargeNotFound = errors.New("charge not found")
+	ErrInvalidState   = errors.New("operation not allowed in the charge's current state")
+	ErrInvalidAmount  = errors.New("invalid amount")
+)
+
+type ChargeStatus string
+
+const (
+	ChargeStatusPending     ChargeStatus = "pending"
+	ChargeStatusAuthorized  ChargeStatus = "authorized"
+	ChargeStatusPaid        ChargeStatus = "paid"
+	ChargeStatusFailed      ChargeStatus = "failed"
+	ChargeStatusCanceled    ChargeStatus = "canceled"
+	ChargeStatusRefunded    ChargeStatus = "refunded"
+	ChargeStatusExpired     ChargeStatus = "expired"
+	ChargeStatusDisputed    ChargeStatus = "disputed"
+	ChargeStatusChargedBack ChargeStatus = "charged_back"
+)
+
+// Provider is a payment service provider able to charge customers. Charges
+// are created authorized or pending and captured once the service is
+// confirmed.
+type Provider interface {
+	// Name identifies the provider, as persisted with each payment
+	Name() string
+	// CreateCharge creates a charge. Reference is our own identifier for the
+	// charge and makes the call idempotent.
+	CreateCharge(input ChargeInput) (*Charge, error)
+	Capture(chargeID string) (*Charge, error)
+	// Cancel voids a charge that was not captured, releasing the amount
+	// authorized on the customer's card or the Pix code not paid yet
+	Cancel(chargeID string) (*Charge, error)
+	// Refund returns amountCents of a paid charge to the customer
+	Refund(chargeID string, amountCents int) (*Charge, error)
+	// GetCharge fetches the current state of a charge from the provider
+	GetCharge(chargeID string) (*Charge, error)
+}
+
+// PixProvider is implemented by providers that accept Pix payments. Pix
+// charges are created pending and become paid once the customer pays the
+// QR code, or expire.
+type PixProvider interface {
+	CreatePixCharge(input ChargeInput, expiresIn time.Duration) (*Charge, *PixCode, error)
+}
+
+type PixCode struct {
+	TxID string
+	// Payload is the BR Code, also known as "copia e cola"
+	Payload   string
+	ExpiresAt time.Time
+}
+
+type ChargeInput struct {
+	Reference   string
+	AmountCents int
+	Currency    string
+	Description string
+}
+
+type Charge struct {
+	ID            string
+	Status        ChargeStatus
+	AmountCents   int
+	Currency      string
+	RefundedCents int
+}
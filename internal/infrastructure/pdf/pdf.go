@@ -0,0 +1,115 @@
+// Package pdf writes simple single-page PDF documents, such as payment
+// receipts, using the standard Helvetica fonts
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+// Document is a single A4 page. Coordinates are in points from the top-left
+// corner of the page.
+type Document struct {
+	content bytes.Buffer
+}
+
+func NewDocument() *Document {
+	return &Document{}
+}
+
+// Text writes a line of text with its baseline at y. Characters outside
+// Windows-1252, which covers Portuguese, are replaced with "?".
+func (d *Document) Text(x, y, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&d.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, PageHeight-y, escape(text))
+}
+
+// TextRight writes a line of text ending at x, e.g. for amounts in a column
+func (d *Document) TextRight(x, y, size float64, bold bool, text string) {
+	d.Text(x-TextWidth(text, size, bold), y, size, bold, text)
+}
+
+// Line draws a thin horizontal or vertical rule
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&d.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// Bytes returns the complete PDF file
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	offsets := make([]int, 0, 6)
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+		"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", PageWidth, PageHeight))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", d.content.Len(), d.content.String()))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// TextWidth estimates the width of a text in points, with the widths of
+// Helvetica for digits and punctuation and an average for other glyphs
+func TextWidth(text string, size float64, bold bool) float64 {
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9', r == '$':
+			width += 0.556
+		case r == ' ', r == '.', r == ',', r == ':', r == '/':
+			width += 0.278
+		case r == '-':
+			width += 0.333
+		case bold:
+			width += 0.61
+		default:
+			width += 0.56
+		}
+	}
+	return width * size
+}
+
+// escape encodes text in Windows-1252 and escapes it for a PDF string
+func escape(text string) string {
+	var b strings.Builder
+	encoder := charmap.Windows1252.NewEncoder()
+	for _, r := range text {
+		encoded, err := encoder.String(string(r))
+		if err != nil {
+			encoded = "?"
+		}
+		switch encoded {
+		case "(", ")", "\\":
+			b.WriteByte('\\')
+		}
+		b.WriteString(encoded)
+	}
+	return b.String()
+}
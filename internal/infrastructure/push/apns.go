@@ -0,0 +1,171 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	APNsProductionURL = "https://api.push.apple.com"
+	APNsSandboxURL    = "https://api.sandbox.push.apple.com"
+
+	// Apple rejects provider tokens older than an hour and refreshed more
+	// often than every 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsProvider sends notifications through the Apple Push Notification
+// service, authenticated with a signing key (token-based authentication).
+type APNsProvider struct {
+	baseURL string
+	keyID   string
+	teamID  string
+	// Bundle ID of the app
+	topic      string
+	privateKey *ecdsa.PrivateKey
+	client     *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNsProvider creates a provider from a .p8 signing key, its key ID, the
+// developer team ID and the app's bundle ID. baseURL is APNsProductionURL or
+// APNsSandboxURL.
+func NewAPNsProvider(key []byte, keyID string, teamID string, topic string, baseURL string) (*APNsProvider, error) {
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, fmt.Errorf("invalid APNs configuration: key ID, team ID and topic are required")
+	}
+
+	privateKey, err := jwt.ParseECPrivateKeyFromPEM(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs key: %w", err)
+	}
+
+	return &APNsProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		keyID:      keyID,
+		teamID:     teamID,
+		topic:      topic,
+		privateKey: privateKey,
+		client:     newHTTPClient(),
+	}, nil
+}
+
+func (p *APNsProvider) Name() string {
+	return "apns"
+}
+
+func (p *APNsProvider) Send(token string, message Message) error {
+	providerToken, err := p.getProviderToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": message.Title,
+				"body":  message.Body,
+			},
+			"sound": "default",
+		},
+	}
+	for key, value := range message.Data {
+		if key != "aps" {
+			payload[key] = value
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, p.baseURL+"/3/device/"+url.PathEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "bearer "+providerToken)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("apns-topic", p.topic)
+	request.Header.Set("apns-push-type", "alert")
+	request.Header.Set("apns-priority", "10")
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("apns request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+	return p.responseError(response)
+}
+
+// responseError maps an APNs error response to ErrUnregistered, ErrRejected
+// or a temporary error
+func (p *APNsProvider) responseError(response *http.Response) error {
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	content, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	json.Unmarshal(content, &payload)
+
+	switch {
+	case response.StatusCode == http.StatusGone, payload.Reason == "Unregistered":
+		return fmt.Errorf("%w: %s", ErrUnregistered, payload.Reason)
+	case payload.Reason == "BadDeviceToken", payload.Reason == "DeviceTokenNotForTopic":
+		// Usually a sandbox token sent to production, or a wrong topic: a
+		// configuration problem that must not wipe the user's devices
+		return fmt.Errorf("%w: apns %d: %s", ErrRejected, response.StatusCode, payload.Reason)
+	case payload.Reason == "ExpiredProviderToken":
+		// Sign a new token next time
+		p.mu.Lock()
+		p.token = ""
+		p.mu.Unlock()
+		return fmt.Errorf("apns provider token expired")
+	case response.StatusCode == http.StatusTooManyRequests, response.StatusCode >= 500:
+		return fmt.Errorf("apns unavailable (%d): %s", response.StatusCode, payload.Reason)
+	default:
+		return fmt.Errorf("%w: apns %d: %s", ErrRejected, response.StatusCode, payload.Reason)
+	}
+}
+
+// getProviderToken returns the signed JWT sent with every request, signing a
+// new one when it gets old
+func (p *APNsProvider) getProviderToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if p.token != "" && now.Sub(p.issuedAt) < apnsTokenLifetime {
+		return p.token, nil
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = p.keyID
+
+	signed, err := token.SignedString(p.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	p.token = signed
+	p.issuedAt = now
+	return p.token, nil
+}
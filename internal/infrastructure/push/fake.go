@@ -0,0 +1,212 @@
+package push
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Device tokens starting with this prefix are reported as unregistered by the
+// fake server, like tokens of uninstalled apps
+const FakeUnregisteredPrefix = "unregistered-"
+
+// FakeMessage is a message delivered to the fake server
+type FakeMessage struct {
+	Provider    string            `json:"provider"`
+	Token       string            `json:"token"`
+	Title       string            `json:"title"`
+	Body        string            `json:"body"`
+	Data        map[string]string `json:"data,omitempty"`
+	DeliveredAt time.Time         `json:"delivered_at"`
+}
+
+// FakeServer is a local stand-in for the FCM and APNs APIs, for development
+// and tests. Point FCMProvider (and the token_uri of its credentials) and
+// APNsProvider at it: any credentials are accepted, and messages are kept in
+// memory instead of reaching devices.
+//
+//   - POST /token: OAuth token endpoint for FCM service accounts
+//   - POST /v1/projects/{project}/messages:send: FCM HTTP v1
+//   - POST /3/device/{token}: APNs
+//   - GET /messages: messages delivered so far
+//   - DELETE /devices/{token}: report a token as unregistered from now on
+type FakeServer struct {
+	mu           sync.Mutex
+	messages     []FakeMessage
+	unregistered map[string]bool
+	mux          *http.ServeMux
+}
+
+func NewFakeServer() *FakeServer {
+	s := &FakeServer{
+		unregistered: make(map[string]bool),
+		mux:          http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /token", s.handleToken)
+	s.mux.HandleFunc("POST /v1/projects/{project}/messages:send", s.handleFCMSend)
+	s.mux.HandleFunc("POST /3/device/{token}", s.handleAPNsSend)
+	s.mux.HandleFunc("GET /messages", s.handleMessages)
+	s.mux.HandleFunc("DELETE /devices/{token}", s.handleUnregister)
+	return s
+}
+
+func (s *FakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Messages returns the messages delivered so far
+func (s *FakeServer) Messages() []FakeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FakeMessage(nil), s.messages...)
+}
+
+// Unregister makes the server report a token as unregistered
+func (s *FakeServer) Unregister(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unregistered[token] = true
+}
+
+func (s *FakeServer) isUnregistered(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unregistered[token] || strings.HasPrefix(token, FakeUnregisteredPrefix)
+}
+
+func (s *FakeServer) record(message FakeMessage) {
+	message.DeliveredAt = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+func (s *FakeServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("assertion") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "fake-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *FakeServer) handleFCMSend(w http.ResponseWriter, r *http.Request) {
+	fcmError := func(status int, code string, errorCode string, message string) {
+		payload := map[string]interface{}{"code": status, "status": code, "message": message}
+		if errorCode != "" {
+			payload["details"] = []map[string]string{{
+				"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+				"errorCode": errorCode,
+			}}
+		}
+		writeJSON(w, status, map[string]interface{}{"error": payload})
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		fcmError(http.StatusUnauthorized, "UNAUTHENTICATED", "", "missing access token")
+		return
+	}
+
+	var request struct {
+		Message struct {
+			Token        string `json:"token"`
+			Notification struct {
+				Title string `json:"title"`
+				Body  string `json:"body"`
+			} `json:"notification"`
+			Data map[string]string `json:"data"`
+		} `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Message.Token == "" {
+		fcmError(http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT", "invalid message")
+		return
+	}
+
+	message := request.Message
+	if s.isUnregistered(message.Token) {
+		fcmError(http.StatusNotFound, "NOT_FOUND", "UNREGISTERED", "Requested entity was not found.")
+		return
+	}
+
+	s.record(FakeMessage{
+		Provider: "fcm",
+		Token:    message.Token,
+		Title:    message.Notification.Title,
+		Body:     message.Notification.Body,
+		Data:     message.Data,
+	})
+	writeJSON(w, http.StatusOK, map[string]string{
+		"name": "projects/" + r.PathValue("project") + "/messages/fake-" + time.Now().Format("150405.000000000"),
+	})
+}
+
+func (s *FakeServer) handleAPNsSend(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "bearer ") {
+		writeJSON(w, http.StatusForbidden, map[string]string{"reason": "MissingProviderToken"})
+		return
+	}
+	if r.Header.Get("apns-topic") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"reason": "MissingTopic"})
+		return
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload["aps"] == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"reason": "PayloadEmpty"})
+		return
+	}
+
+	token := r.PathValue("token")
+	if s.isUnregistered(token) {
+		writeJSON(w, http.StatusGone, map[string]interface{}{
+			"reason":    "Unregistered",
+			"timestamp": time.Now().UnixMilli(),
+		})
+		return
+	}
+
+	var aps struct {
+		Alert struct {
+			Title string `json:"title"`
+			Body  string `json:"body"`
+		} `json:"alert"`
+	}
+	json.Unmarshal(payload["aps"], &aps)
+
+	data := make(map[string]string)
+	for key, value := range payload {
+		var text string
+		if key != "aps" && json.Unmarshal(value, &text) == nil {
+			data[key] = text
+		}
+	}
+
+	s.record(FakeMessage{
+		Provider: "apns",
+		Token:    token,
+		Title:    aps.Alert.Title,
+		Body:     aps.Alert.Body,
+		Data:     data,
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *FakeServer) handleMessages(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Messages())
+}
+
+func (s *FakeServer) handleUnregister(w http.ResponseWriter, r *http.Request) {
+	s.Unregister(r.PathValue("token"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
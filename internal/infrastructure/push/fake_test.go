@@ -0,0 +1,100 @@
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testPrivateKeyPEM(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestFakeServerWithFCM(t *testing.T) {
+	server := NewFakeServer()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, _ := json.Marshal(serviceAccount{
+		ProjectID:   "indicar",
+		ClientEmail: "push@indicar.iam.gserviceaccount.com",
+		PrivateKey:  string(testPrivateKeyPEM(t, key)),
+		TokenURI:    httpServer.URL + "/token",
+	})
+	provider, err := NewFCMProvider(credentials, httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := Message{Title: "Avaliação concluída", Body: "O relatório está pronto", Data: map[string]string{"evaluation_id": "42"}}
+	if err := provider.Send("device-1", message); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Send(FakeUnregisteredPrefix+"device-2", message); !errors.Is(err, ErrUnregistered) {
+		t.Errorf("send to an unregistered token: err = %v, want ErrUnregistered", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("delivered %d messages, want 1", len(messages))
+	}
+	if got := messages[0]; got.Provider != "fcm" || got.Token != "device-1" || got.Title != message.Title || got.Data["evaluation_id"] != "42" {
+		t.Errorf("delivered %+v", got)
+	}
+}
+
+func TestFakeServerWithAPNs(t *testing.T) {
+	server := NewFakeServer()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewAPNsProvider(testPrivateKeyPEM(t, key), "KEYID", "TEAMID", "br.com.indicar.app", httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := Message{Title: "Avaliação concluída", Body: "O relatório está pronto", Data: map[string]string{"evaluation_id": "42"}}
+	if err := provider.Send("device-1", message); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens reported through the API are unregistered from then on
+	request, _ := http.NewRequest(http.MethodDelete, httpServer.URL+"/devices/device-1", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if err := provider.Send("device-1", message); !errors.Is(err, ErrUnregistered) {
+		t.Errorf("send after unregistering: err = %v, want ErrUnregistered", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("delivered %d messages, want 1", len(messages))
+	}
+	if got := messages[0]; got.Provider != "apns" || got.Token != "device-1" || got.Body != message.Body || got.Data["evaluation_id"] != "42" {
+		t.Errorf("delivered %+v", got)
+	}
+}
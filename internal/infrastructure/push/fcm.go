@@ -0,0 +1,216 @@
+package push
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmScope           = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmDefaultTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// FCMProvider sends notifications through the Firebase Cloud Messaging HTTP
+// v1 API, authenticated as a service account. Access tokens are obtained
+// with a signed JWT and reused until they expire.
+type FCMProvider struct {
+	baseURL     string
+	projectID   string
+	clientEmail string
+	tokenURL    string
+	privateKey  *rsa.PrivateKey
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// serviceAccount holds the fields used from a Google service account key file
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// NewFCMProvider creates a provider from the JSON key of a service account
+// allowed to send messages. baseURL is the FCM API, e.g.
+// https://fcm.googleapis.com.
+func NewFCMProvider(credentials []byte, baseURL string) (*FCMProvider, error) {
+	var account serviceAccount
+	if err := json.Unmarshal(credentials, &account); err != nil {
+		return nil, fmt.Errorf("invalid FCM credentials: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" {
+		return nil, errors.New("invalid FCM credentials: project_id and client_email are required")
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FCM credentials: %w", err)
+	}
+
+	tokenURL := account.TokenURI
+	if tokenURL == "" {
+		tokenURL = fcmDefaultTokenURL
+	}
+
+	return &FCMProvider{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		projectID:   account.ProjectID,
+		clientEmail: account.ClientEmail,
+		tokenURL:    tokenURL,
+		privateKey:  privateKey,
+		client:      newHTTPClient(),
+	}, nil
+}
+
+func (p *FCMProvider) Name() string {
+	return "fcm"
+}
+
+func (p *FCMProvider) Send(token string, message Message) error {
+	accessToken, err := p.getAccessToken()
+	if err != nil {
+		return err
+	}
+
+	type notification struct {
+		Title string `json:"title,omitempty"`
+		Body  string `json:"body,omitempty"`
+	}
+	type fcmMessage struct {
+		Token        string            `json:"token"`
+		Notification notification      `json:"notification"`
+		Data         map[string]string `json:"data,omitempty"`
+	}
+	body, err := json.Marshal(map[string]fcmMessage{
+		"message": {
+			Token:        token,
+			Notification: notification{Title: message.Title, Body: message.Body},
+			Data:         message.Data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("%s/v1/projects/%s/messages:send", p.baseURL, url.PathEscape(p.projectID)),
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("fcm request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+	return p.responseError(response)
+}
+
+// responseError maps an FCM error response to ErrUnregistered, ErrRejected or
+// a temporary error
+func (p *FCMProvider) responseError(response *http.Response) error {
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	content, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	json.Unmarshal(content, &payload)
+
+	errorCode := payload.Error.Status
+	for _, detail := range payload.Error.Details {
+		if detail.ErrorCode != "" {
+			errorCode = detail.ErrorCode
+		}
+	}
+
+	switch {
+	case errorCode == "UNREGISTERED":
+		// Other 404s, such as a wrong project, say nothing about the device
+		return fmt.Errorf("%w: %s", ErrUnregistered, payload.Error.Message)
+	case response.StatusCode == http.StatusUnauthorized:
+		// The access token may have been revoked: get a new one next time
+		p.mu.Lock()
+		p.accessToken = ""
+		p.mu.Unlock()
+		return fmt.Errorf("fcm authentication failed: %s", payload.Error.Message)
+	case response.StatusCode == http.StatusTooManyRequests, response.StatusCode >= 500:
+		return fmt.Errorf("fcm unavailable (%d %s): %s", response.StatusCode, errorCode, payload.Error.Message)
+	default:
+		return fmt.Errorf("%w: fcm %d %s: %s", ErrRejected, response.StatusCode, errorCode, payload.Error.Message)
+	}
+}
+
+// getAccessToken returns a cached OAuth 2.0 access token, exchanging a new
+// signed assertion for one when it is about to expire
+func (p *FCMProvider) getAccessToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if p.accessToken != "" && now.Before(p.expiresAt.Add(-time.Minute)) {
+		return p.accessToken, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.clientEmail,
+		"scope": fcmScope,
+		"aud":   p.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(p.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := p.client.PostForm(p.tokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("fcm token request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if response.StatusCode != http.StatusOK {
+		content, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+		return "", fmt.Errorf("fcm token request failed (%d): %s", response.StatusCode, content)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid fcm token response: %w", err)
+	}
+
+	p.accessToken = token.AccessToken
+	p.expiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return p.accessToken, nil
+}
@@ -0,0 +1,37 @@
+package push
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+const requestTimeout = 10 * time.Second
+
+var (
+	// ErrUnregistered means the device token is no longer valid, e.g. because
+	// the app was uninstalled, and the device should be forgotten
+	ErrUnregistered = errors.New("device token is not registered")
+	// ErrRejected means the provider refused the message for a reason that
+	// retrying will not fix
+	ErrRejected = errors.New("push message rejected by the provider")
+)
+
+// Provider delivers push notifications to devices of one platform
+type Provider interface {
+	// Name identifies the provider in logs
+	Name() string
+	// Send delivers a message to the device with the given token
+	Send(token string, message Message) error
+}
+
+type Message struct {
+	Title string
+	Body  string
+	// Custom key-value pairs handled by the app
+	Data map[string]string
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
@@ -0,0 +1,72 @@
+package push
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testResponse(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestFCMResponseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"unregistered", http.StatusNotFound, `{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`, ErrUnregistered},
+		{"other not found", http.StatusNotFound, `{"error":{"status":"NOT_FOUND","message":"project not found"}}`, ErrRejected},
+		{"invalid argument", http.StatusBadRequest, `{"error":{"status":"INVALID_ARGUMENT"}}`, ErrRejected},
+		{"unavailable", http.StatusServiceUnavailable, `{"error":{"status":"UNAVAILABLE"}}`, nil},
+	}
+
+	provider := &FCMProvider{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := provider.responseError(testResponse(test.status, test.body))
+			checkResponseError(t, err, test.want)
+		})
+	}
+}
+
+func TestAPNsResponseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"gone", http.StatusGone, `{"reason":"Unregistered"}`, ErrUnregistered},
+		{"unregistered", http.StatusBadRequest, `{"reason":"Unregistered"}`, ErrUnregistered},
+		{"bad device token", http.StatusBadRequest, `{"reason":"BadDeviceToken"}`, ErrRejected},
+		{"wrong topic", http.StatusBadRequest, `{"reason":"DeviceTokenNotForTopic"}`, ErrRejected},
+		{"unavailable", http.StatusServiceUnavailable, `{"reason":"ServiceUnavailable"}`, nil},
+	}
+
+	provider := &APNsProvider{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := provider.responseError(testResponse(test.status, test.body))
+			checkResponseError(t, err, test.want)
+		})
+	}
+}
+
+// checkResponseError checks that err wraps want, or neither sentinel when
+// want is nil, as for errors worth retrying
+func checkResponseError(t *testing.T, err error, want error) {
+	t.Helper()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, sentinel := range []error{ErrUnregistered, ErrRejected} {
+		if errors.Is(err, sentinel) != (sentinel == want) {
+			t.Fatalf("error %q: errors.Is(%v) = %v", err, sentinel, !(sentinel == want))
+		}
+	}
+}
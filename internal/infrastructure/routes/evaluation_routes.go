@@ -1,37 +1,71 @@
 package routes
 
 import (
-	"fmt"
 	"indicar-api/configs"
 	"indicar-api/internal/application/controllers"
 	"indicar-api/internal/application/services"
 	"indicar-api/internal/infrastructure/middleware"
+	"indicar-api/internal/infrastructure/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupEvaluationRoutes(router *gin.Engine, db *gorm.DB) error {
-	evaluationService := services.NewEvaluationService(db)
-	evaluationPhotoService, err := services.NewEvaluationPhotoService(db)
-	if err != nil {
-		return fmt.Errorf("failed to initialize photo service: %w", err)
-	}
+// Photo uploads can be retried with an idempotency key too: 10MB files plus
+// the multipart overhead
+const maxEvaluationRequestSize = 11 * 1024 * 1024
+
+func SetupEvaluationRoutes(router *gin.Engine, db *gorm.DB, store storage.BlobStore, photoVariantService *services.PhotoVariantService, paymentService *services.PaymentService, ledgerService *services.LedgerService, idempotencyService *services.IdempotencyService) error {
+	couponService := services.NewCouponService(db)
+	pricingService := services.NewPricingService(db, couponService)
+	evaluationService := services.NewEvaluationService(db, paymentService, pricingService, couponService, ledgerService)
+	evaluationPhotoService := services.NewEvaluationPhotoService(db, store, photoVariantService)
+
+	photoMatchService := services.NewPhotoMatchService(db, evaluationPhotoService)
 
 	evaluationController := controllers.NewEvaluationController(evaluationService, evaluationPhotoService)
+	photoMatchController := controllers.NewPhotoMatchController(photoMatchService)
+	pricingController := controllers.NewPricingController(pricingService)
+	couponController := controllers.NewCouponController(couponService)
 
 	authMiddleware := middleware.AuthMiddleware([]byte(configs.Get().JWT.Secret))
+	idempotencyMiddleware := middleware.IdempotencyMiddleware(idempotencyService, maxEvaluationRequestSize)
 
 	evaluations := router.Group("/evaluations")
-	evaluations.Use(authMiddleware)
+	evaluations.Use(authMiddleware, idempotencyMiddleware)
 	{
 		evaluations.POST("", evaluationController.Create)
+		evaluations.GET("/quote", pricingController.Quote)
 		evaluations.GET("/:id", evaluationController.GetByID)
 		evaluations.GET("", evaluationController.List)
 		evaluations.PATCH("/:id", evaluationController.Update)
 
 		evaluations.POST("/:id/photos", evaluationController.UploadPhoto)
+		evaluations.POST("/:id/photos/upload-url", evaluationController.CreatePhotoUploadURL)
+		evaluations.POST("/:id/photos/confirm", evaluationController.ConfirmPhotoUpload)
 		evaluations.GET("/:id/photos", evaluationController.ListPhotos)
+		evaluations.GET("/:id/photos/completeness", evaluationController.GetPhotoCompleteness)
+		evaluations.PUT("/:id/photos/order", evaluationController.ReorderPhotos)
+		evaluations.PATCH("/:id/photos/:photoId", evaluationController.UpdatePhoto)
+		evaluations.PUT("/:id/photos/:photoId", evaluationController.ReplacePhoto)
+		evaluations.DELETE("/:id/photos/:photoId", evaluationController.DeletePhoto)
+	}
+
+	admin := router.Group("/admin")
+	admin.Use(authMiddleware, idempotencyMiddleware)
+	{
+		admin.GET("/photo-matches", photoMatchController.List)
+		admin.PATCH("/photo-matches/:id", photoMatchController.Review)
+
+		admin.GET("/pricing-rules", pricingController.ListRules)
+		admin.POST("/pricing-rules", pricingController.CreateRule)
+		admin.PUT("/pricing-rules/:id", pricingController.UpdateRule)
+		admin.DELETE("/pricing-rules/:id", pricingController.DeleteRule)
+
+		admin.GET("/coupons", couponController.List)
+		admin.POST("/coupons", couponController.Create)
+		admin.PUT("/coupons/:id", couponController.Update)
+		admin.GET("/coupons/:id/redemptions", couponController.ListRedemptions)
 	}
 
 	return nil
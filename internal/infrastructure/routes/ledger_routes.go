@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"indicar-api/configs"
+	"indicar-api/internal/application/controllers"
+	"indicar-api/internal/application/services"
+	"indicar-api/internal/infrastructure/middleware"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func SetupLedgerRoutes(router *gin.Engine, db *gorm.DB, ledgerService *services.LedgerService, payoutService *services.PayoutService, idempotencyService *services.IdempotencyService) error {
+	ledgerController := controllers.NewLedgerController(ledgerService, payoutService)
+
+	authMiddleware := middleware.AuthMiddleware([]byte(configs.Get().JWT.Secret))
+	idempotencyMiddleware := middleware.IdempotencyMiddleware(idempotencyService, middleware.DefaultMaxIdempotentBodySize)
+
+	me := router.Group("/me")
+	me.Use(authMiddleware)
+	{
+		me.GET("/earnings", ledgerController.GetEarnings)
+		me.PUT("/payout-details", ledgerController.UpdatePayoutDetails)
+	}
+
+	admin := router.Group("/admin")
+	admin.Use(authMiddleware, idempotencyMiddleware)
+	{
+		admin.POST("/evaluators/:id/penalties", ledgerController.CreatePenalty)
+
+		admin.GET("/payout-batches", ledgerController.ListBatches)
+		admin.POST("/payout-batches", ledgerController.CreateBatch)
+		admin.GET("/payout-batches/:id", ledgerController.GetBatch)
+		admin.GET("/payout-batches/:id/export", ledgerController.ExportBatch)
+	}
+
+	return nil
+}
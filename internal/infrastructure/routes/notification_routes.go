@@ -1,26 +1,80 @@
 package routes
 
 import (
+	"fmt"
 	"indicar-api/configs"
 	"indicar-api/internal/application/controllers"
 	"indicar-api/internal/application/services"
 	"indicar-api/internal/infrastructure/middleware"
+	"indicar-api/internal/infrastructure/push"
+	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupNotificationRoutes(router *gin.Engine, db *gorm.DB) error {
+func SetupNotificationRoutes(router *gin.Engine, db *gorm.DB, idempotencyService *services.IdempotencyService) error {
 	notificationService := services.NewNotificationService(db)
 	notificationController := controllers.NewNotificationController(notificationService)
 
 	authMiddleware := middleware.AuthMiddleware([]byte(configs.Get().JWT.Secret))
+	idempotencyMiddleware := middleware.IdempotencyMiddleware(idempotencyService, middleware.DefaultMaxIdempotentBodySize)
 
 	devices := router.Group("/devices")
-	devices.Use(authMiddleware)
+	devices.Use(authMiddleware, idempotencyMiddleware)
 	{
 		devices.POST("", notificationController.RegisterDevice)
 	}
 
+	// Without real FCM and APNs credentials, push is tested against the fake
+	// server by pointing the providers at it
+	if configs.Get().Push.FakeServer {
+		router.Any("/fake-push/*path", gin.WrapH(http.StripPrefix("/fake-push", push.NewFakeServer())))
+	}
+
 	return nil
 }
+
+// NewPushNotificationSender creates the sender of push notifications with the
+// providers configured for each platform, or nil when none is configured
+func NewPushNotificationSender(db *gorm.DB) (services.NotificationSender, error) {
+	pushConfig := configs.Get().Push
+	providers := make(map[string]push.Provider)
+
+	if pushConfig.FCMCredentialsFile != "" {
+		credentials, err := os.ReadFile(pushConfig.FCMCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read FCM credentials: %w", err)
+		}
+		provider, err := push.NewFCMProvider(credentials, pushConfig.FCMBaseURL)
+		if err != nil {
+			return nil, err
+		}
+		providers["android"] = provider
+	}
+
+	if pushConfig.APNsKeyFile != "" {
+		key, err := os.ReadFile(pushConfig.APNsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read APNs key: %w", err)
+		}
+		baseURL := pushConfig.APNsBaseURL
+		if baseURL == "" {
+			baseURL = push.APNsProductionURL
+			if pushConfig.APNsSandbox {
+				baseURL = push.APNsSandboxURL
+			}
+		}
+		provider, err := push.NewAPNsProvider(key, pushConfig.APNsKeyID, pushConfig.APNsTeamID, pushConfig.APNsTopic, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		providers["ios"] = provider
+	}
+
+	if len(providers) == 0 {
+		return nil, nil
+	}
+	return services.NewPushSender(db, providers), nil
+}
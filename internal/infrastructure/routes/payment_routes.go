@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/application/controllers"
+	"indicar-api/internal/application/services"
+	"indicar-api/internal/infrastructure/invoice"
+	"indicar-api/internal/infrastructure/middleware"
+	"indicar-api/internal/infrastructure/payment"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SetupPaymentRoutes registers the payment and receipt endpoints and returns
+// the payment service, which evaluations use to charge new requests
+func SetupPaymentRoutes(router *gin.Engine, db *gorm.DB, ledgerService *services.LedgerService, receiptService *services.ReceiptService, idempotencyService *services.IdempotencyService) (*services.PaymentService, error) {
+	provider, err := newPaymentProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	paymentService := services.NewPaymentService(db, provider, ledgerService, receiptService)
+	paymentController := controllers.NewPaymentController(paymentService)
+	receiptController := controllers.NewReceiptController(receiptService)
+	paymentWebhookController := controllers.NewPaymentWebhookController(
+		services.NewPaymentWebhookService(db, paymentService),
+	)
+
+	authMiddleware := middleware.AuthMiddleware([]byte(configs.Get().JWT.Secret))
+	idempotencyMiddleware := middleware.IdempotencyMiddleware(idempotencyService, middleware.DefaultMaxIdempotentBodySize)
+
+	evaluations := router.Group("/evaluations")
+	evaluations.Use(authMiddleware, idempotencyMiddleware)
+	{
+		evaluations.GET("/:id/payment", paymentController.Get)
+		evaluations.GET("/:id/payment/pix-qr", paymentController.GetPixQRCode)
+		evaluations.POST("/:id/payment", paymentController.Retry)
+		evaluations.POST("/:id/payment/capture", paymentController.Capture)
+		evaluations.POST("/:id/payment/refund", paymentController.Refund)
+		evaluations.GET("/:id/payment/refunds", paymentController.ListRefunds)
+	}
+
+	payments := router.Group("/payments")
+	payments.Use(authMiddleware, idempotencyMiddleware)
+	{
+		payments.GET("/:id/receipt", receiptController.Get)
+	}
+
+	// Called by providers, authenticated by their signature
+	router.POST("/webhooks/payments/:provider", paymentWebhookController.Receive)
+
+	// Without a real PSP, Pix payments are confirmed by hand. The route is
+	// unauthenticated, so it must be enabled explicitly, for development only.
+	if _, ok := provider.(*payment.FakeProvider); ok && configs.Get().Payment.FakePSP {
+		router.POST("/fake-psp/pix/:chargeId/pay", paymentController.SimulatePixPayment)
+	}
+
+	return paymentService, nil
+}
+
+// newPaymentProvider creates the provider selected by PAYMENT_PROVIDER
+func newPaymentProvider() (payment.Provider, error) {
+	paymentConfig := configs.Get().Payment
+	switch name := paymentConfig.Provider; name {
+	case "fake":
+		// BR Codes without a receiving key cannot be paid
+		if paymentConfig.PixKey == "" {
+			return nil, fmt.Errorf("PAYMENT_PIX_KEY is required by the %s payment provider", name)
+		}
+		return payment.NewFakeProvider(payment.PixMerchant{
+			Key:  paymentConfig.PixKey,
+			Name: paymentConfig.PixMerchantName,
+			City: paymentConfig.PixMerchantCity,
+		}, paymentConfig.FakeStateFile)
+	default:
+		return nil, fmt.Errorf("unknown payment provider: %s", name)
+	}
+}
+
+// NewInvoiceIssuer creates the NFS-e issuer selected by INVOICE_ISSUER
+func NewInvoiceIssuer() (invoice.Issuer, error) {
+	switch name := configs.Get().Invoice.Issuer; name {
+	case "local":
+		return invoice.NewLocalIssuer(), nil
+	default:
+		return nil, fmt.Errorf("unknown invoice issuer: %s", name)
+	}
+}
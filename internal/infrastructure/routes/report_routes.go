@@ -5,29 +5,59 @@ import (
 	"indicar-api/internal/application/controllers"
 	"indicar-api/internal/application/services"
 	"indicar-api/internal/infrastructure/middleware"
+	"indicar-api/internal/infrastructure/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupReportRoutes(router *gin.Engine, db *gorm.DB) error {
-	reportService, err := services.NewReportService(db)
+// Report files can be retried with an idempotency key too: 50MB files plus
+// the multipart overhead
+const maxReportRequestSize = 51 * 1024 * 1024
+
+func SetupReportRoutes(router *gin.Engine, db *gorm.DB, store storage.BlobStore, idempotencyService *services.IdempotencyService) error {
+	reportService, err := services.NewReportService(db, store)
 	if err != nil {
 		return err
 	}
-	reportController := controllers.NewReportController(reportService)
+	reportShareService := services.NewReportShareService(db, reportService)
+	reportController := controllers.NewReportController(reportService, reportShareService)
 
 	authMiddleware := middleware.AuthMiddleware([]byte(configs.Get().JWT.Secret))
+	idempotencyMiddleware := middleware.IdempotencyMiddleware(idempotencyService, maxReportRequestSize)
 
 	reports := router.Group("/reports")
-	reports.Use(authMiddleware)
+	reports.Use(authMiddleware, idempotencyMiddleware)
 	{
 		reports.POST("", reportController.CreateOrUpdate)
+		reports.GET("", reportController.List)
 		reports.GET("/:id", reportController.GetByID)
 		reports.PATCH("/:id", reportController.CreateOrUpdate)
 
 		reports.POST("/:id/file", reportController.UploadFile)
 		reports.GET("/:id/file", reportController.GetFileURL)
+		reports.GET("/:id/files", reportController.ListFiles)
+		reports.GET("/:id/verification-qr", reportController.GetVerificationQRCode)
+
+		reports.POST("/:id/shares", reportController.CreateShare)
+		reports.GET("/:id/shares", reportController.ListShares)
+		reports.DELETE("/:id/shares/:shareId", reportController.RevokeShare)
+	}
+
+	evaluations := router.Group("/evaluations")
+	evaluations.Use(authMiddleware)
+	{
+		evaluations.GET("/:id/report", reportController.GetByEvaluation)
+	}
+
+	shared := router.Group("/shared")
+	{
+		shared.GET("/reports/:token", reportController.AccessShare)
+	}
+
+	verify := router.Group("/verify")
+	{
+		verify.GET("/:code", reportController.Verify)
 	}
 
 	return nil
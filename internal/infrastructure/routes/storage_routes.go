@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"indicar-api/configs"
+	"indicar-api/internal/application/controllers"
+	"indicar-api/internal/infrastructure/aws"
+	"indicar-api/internal/infrastructure/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupStorage creates the blob store selected by STORAGE_DRIVER. The local
+// driver also registers the route serving its pre-signed URLs.
+func SetupStorage(router *gin.Engine) (storage.BlobStore, error) {
+	storageConfig := configs.Get().Storage
+
+	switch storageConfig.Driver {
+	case "s3":
+		store, err := aws.NewS3Service()
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "local":
+		// Anyone knowing the secret can sign URLs for any stored file
+		if storageConfig.SigningSecret == "" {
+			return nil, errors.New("STORAGE_SIGNING_SECRET is required when STORAGE_DRIVER is local")
+		}
+
+		store, err := storage.NewLocalStore(
+			storageConfig.LocalDir,
+			configs.Get().App.BaseURL+"/files",
+			[]byte(storageConfig.SigningSecret),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		fileController := controllers.NewFileController(store)
+		router.GET("/files/*key", fileController.Serve)
+
+		return store, nil
+	case "memory":
+		return storage.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", storageConfig.Driver)
+	}
+}
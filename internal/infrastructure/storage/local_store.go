@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	localMetaDir      = ".meta"
+	localTempFileGlob = ".upload-*"
+)
+
+// LocalStore keeps objects on the local disk. Pre-signed URLs point back to
+// the API, which serves the file after checking the URL signature.
+type LocalStore struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+type localMetadata struct {
+	ContentType string `json:"content_type"`
+}
+
+// NewLocalStore creates a store rooted at dir. baseURL is the public URL of the
+// route serving the files, e.g. "http://localhost:8080/files". The secret
+// signs the URLs and cannot be empty.
+func NewLocalStore(dir string, baseURL string, secret []byte) (*LocalStore, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("a signing secret is required for local storage")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{
+		root:    dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+	}, nil
+}
+
+func (s *LocalStore) Bucket() string {
+	return "local"
+}
+
+func (s *LocalStore) Put(key string, body io.Reader, contentType string) error {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), localTempFileGlob)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := s.writeMetadata(key, localMetadata{ContentType: contentType}); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+func (s *LocalStore) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := s.Head(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePath, _ := s.objectPath(key)
+	file, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	return file, info, nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(s.metadataPath(filePath)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) Head(key string) (*ObjectInfo, error) {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  s.readMetadata(filePath).ContentType,
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Presign(key string, expiration time.Duration) (string, error) {
+	if _, err := s.objectPath(key); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiration).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(key, expires))
+
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// VerifySignature checks the expires and signature parameters of a URL
+// generated by Presign.
+func (s *LocalStore) VerifySignature(key string, expires string, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("invalid expiration")
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return errors.New("signed URL has expired")
+	}
+
+	if !hmac.Equal([]byte(s.sign(key, expires)), []byte(signature)) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+func (s *LocalStore) List(prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+
+	err := filepath.WalkDir(s.root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == localMetaDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matched, _ := filepath.Match(localTempFileGlob, entry.Name()); matched {
+			return nil
+		}
+
+		relative, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := s.Head(key)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, *info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+func (s *LocalStore) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// objectPath maps a key to a path inside the root, rejecting keys that would
+// escape it.
+func (s *LocalStore) objectPath(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned != "/"+key || strings.HasPrefix(key, localMetaDir+"/") {
+		return "", fmt.Errorf("invalid object key: %s", key)
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStore) metadataPath(filePath string) string {
+	relative, _ := filepath.Rel(s.root, filePath)
+	return filepath.Join(s.root, localMetaDir, relative+".json")
+}
+
+func (s *LocalStore) writeMetadata(key string, metadata localMetadata) error {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	metaPath := s.metadataPath(filePath)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, data, 0o644)
+}
+
+func (s *LocalStore) readMetadata(filePath string) localMetadata {
+	var metadata localMetadata
+
+	data, err := os.ReadFile(s.metadataPath(filePath))
+	if err == nil {
+		json.Unmarshal(data, &metadata)
+	}
+	if metadata.ContentType == "" {
+		metadata.ContentType = "application/octet-stream"
+	}
+	return metadata
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "testing"
+
+func TestNewLocalStoreRequiresSecret(t *testing.T) {
+	if _, err := NewLocalStore(t.TempDir(), "http://localhost:8080/files", nil); err == nil {
+		t.Error("NewLocalStore accepted an empty signing secret")
+	}
+
+	if _, err := NewLocalStore(t.TempDir(), "http://localhost:8080/files", []byte("secret")); err != nil {
+		t.Errorf("NewLocalStore = %v", err)
+	}
+}
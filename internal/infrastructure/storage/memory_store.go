@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps objects in memory. It is meant for tests and local runs
+// where nothing needs to survive a restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		objects: make(map[string]memoryObject),
+	}
+}
+
+func (s *MemoryStore) Bucket() string {
+	return "memory"
+}
+
+func (s *MemoryStore) Put(key string, body io.Reader, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[key] = memoryObject{
+		data: data,
+		info: ObjectInfo{
+			Key:          key,
+			Size:         int64(len(data)),
+			ContentType:  contentType,
+			LastModified: time.Now(),
+		},
+	}
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	object, ok := s.objects[key]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	info := object.info
+	return io.NopCloser(bytes.NewReader(object.data)), &info, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *MemoryStore) Head(key string) (*ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	object, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	info := object.info
+	return &info, nil
+}
+
+func (s *MemoryStore) Presign(key string, expiration time.Duration) (string, error) {
+	if _, err := s.Head(key); err != nil {
+		return "", err
+	}
+	return "memory://" + key, nil
+}
+
+func (s *MemoryStore) List(prefix string) ([]ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	objects := make([]ObjectInfo, 0)
+	for key, object := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, object.info)
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
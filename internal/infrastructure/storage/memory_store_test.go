@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	for _, key := range []string{"photos/2/b.jpg", "photos/1/a.jpg", "reports/1/report.pdf"} {
+		if err := store.Put(key, strings.NewReader("content of "+key), "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body, info, err := store.Get("photos/1/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "content of photos/1/a.jpg" || info.Size != int64(len(data)) || info.ContentType != "image/jpeg" {
+		t.Errorf("Get = %q, %+v", data, info)
+	}
+
+	objects, err := store.List("photos/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Key != "photos/1/a.jpg" || objects[1].Key != "photos/2/b.jpg" {
+		t.Errorf("List(photos/) = %+v, want the two photos sorted by key", objects)
+	}
+
+	if url, err := store.Presign("photos/1/a.jpg", 0); err != nil || url != "memory://photos/1/a.jpg" {
+		t.Errorf("Presign = %q, %v", url, err)
+	}
+
+	if err := store.Delete("photos/1/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Head("photos/1/a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Head after Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, _, err := store.Get("photos/1/a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Presign("photos/1/a.jpg", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Presign after Delete: err = %v, want ErrNotFound", err)
+	}
+}
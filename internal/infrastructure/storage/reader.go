@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+)
+
+var ErrTooLarge = errors.New("file exceeds the maximum allowed size")
+
+// LimitReader returns a reader that fails with ErrTooLarge once more than
+// maxSize bytes are read, instead of silently truncating like io.LimitReader.
+func LimitReader(r io.Reader, maxSize int64) io.Reader {
+	return &limitedReader{r: r, remaining: maxSize}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+
+	// Read one byte past the limit so oversized input is detected
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// ChecksumReader computes the size and SHA-256 of the data read through it
+type ChecksumReader struct {
+	r    io.Reader
+	hash hash.Hash
+	size int64
+}
+
+func NewChecksumReader(r io.Reader) *ChecksumReader {
+	return &ChecksumReader{
+		r:    r,
+		hash: sha256.New(),
+	}
+}
+
+func (c *ChecksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	c.size += int64(n)
+	return n, err
+}
+
+// Size returns the number of bytes read so far
+func (c *ChecksumReader) Size() int64 {
+	return c.size
+}
+
+// SHA256 returns the digest of the bytes read so far
+func (c *ChecksumReader) SHA256() []byte {
+	return c.hash.Sum(nil)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// BlobStore is the object storage used for photos and report files. Keys are
+// slash-separated paths such as "reports/1/report_123.pdf".
+type BlobStore interface {
+	// Bucket identifies where objects are stored, as persisted with each file record
+	Bucket() string
+	Put(key string, body io.Reader, contentType string) error
+	// Get returns the object content, which the caller must close
+	Get(key string) (io.ReadCloser, *ObjectInfo, error)
+	Delete(key string) error
+	// Head returns ErrNotFound when the object does not exist
+	Head(key string) (*ObjectInfo, error)
+	// Presign returns a URL granting temporary read access to the object
+	Presign(key string, expiration time.Duration) (string, error)
+	List(prefix string) ([]ObjectInfo, error)
+}
+
+// PostPresigner is implemented by stores that accept uploads sent directly by
+// clients, without going through the API.
+type PostPresigner interface {
+	// PresignPost returns a form upload restricted to the given key, content
+	// type and maximum size
+	PresignPost(key string, contentType string, maxSize int64, expiration time.Duration) (*PresignedPost, error)
+}
+
+type PresignedPost struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// ValidateFileType validates if the file type is allowed
+func ValidateFileType(filename string, allowedTypes []string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	contentType := mime.TypeByExtension(ext)
+
+	if contentType == "" {
+		return fmt.Errorf("unable to determine file type for extension: %s", ext)
+	}
+
+	for _, allowedType := range allowedTypes {
+		if strings.Contains(contentType, allowedType) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("file type %s is not allowed. Allowed types: %v", contentType, allowedTypes)
+}
+
+// ValidateFileSize validates if the file size is within limits
+func ValidateFileSize(size int64, maxSize int64) error {
+	if size > maxSize {
+		return fmt.Errorf("file size %d bytes exceeds maximum allowed size of %d bytes", size, maxSize)
+	}
+	return nil
+}
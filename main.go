@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"indicar-api/configs"
 	"indicar-api/docs"
+	"indicar-api/internal/application/services"
+	"indicar-api/internal/domain/entities"
 	"indicar-api/internal/infrastructure/database"
 	"indicar-api/internal/infrastructure/database/migrations"
 	"indicar-api/internal/infrastructure/routes"
@@ -52,6 +54,9 @@ func init() {
 func main() {
 	migrateFlag := flag.Bool("migrate", false, "Run database migrations")
 	dropTablesFlag := flag.Bool("drop-tables", false, "Drop all database tables")
+	backfillPhotoVariantsFlag := flag.Bool("backfill-photo-variants", false, "Generate missing thumbnail and medium variants of evaluation photos")
+	backfillPhotoHashesFlag := flag.Bool("backfill-photo-hashes", false, "Compute and index the missing checksums and perceptual hashes of evaluation photos")
+	replayPaymentEventsFlag := flag.Bool("replay-payment-events", false, "Apply again the stored payment webhook events that were not processed")
 	flag.Parse()
 
 	if *dropTablesFlag {
@@ -80,6 +85,45 @@ func main() {
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	store, err := routes.SetupStorage(router)
+	if err != nil {
+		log.Fatalf("Failed to setup storage: %v", err)
+	}
+
+	photoVariantService := services.NewPhotoVariantService(DB, store)
+
+	if *backfillPhotoVariantsFlag {
+		fmt.Println("Generating photo variants...")
+		processed, err := photoVariantService.Backfill()
+		if err != nil {
+			log.Fatalf("Failed to backfill photo variants: %v", err)
+		}
+		fmt.Printf("Photo variants generated for %d photos!\n", processed)
+		os.Exit(0)
+	}
+
+	if *backfillPhotoHashesFlag {
+		fmt.Println("Computing photo hashes...")
+		processed, err := services.NewEvaluationPhotoService(DB, store, photoVariantService).BackfillHashes()
+		if err != nil {
+			log.Fatalf("Failed to backfill photo hashes: %v", err)
+		}
+		fmt.Printf("Hashes computed for %d photos!\n", processed)
+		os.Exit(0)
+	}
+
+	photoVariantService.Start()
+
+	ledgerService := services.NewLedgerService(DB)
+	payoutService := services.NewPayoutService(DB, ledgerService)
+
+	invoiceIssuer, err := routes.NewInvoiceIssuer()
+	if err != nil {
+		log.Fatalf("Failed to setup invoice issuer: %v", err)
+	}
+	receiptService := services.NewReceiptService(DB, store, invoiceIssuer)
+	idempotencyService := services.NewIdempotencyService(DB)
+
 	// Setup routes
 	if err := routes.SetupAuthRoutes(router, DB); err != nil {
 		log.Fatalf("Failed to setup auth routes: %v", err)
@@ -87,13 +131,53 @@ func main() {
 	if err := routes.SetupUserRoutes(router, DB); err != nil {
 		log.Fatalf("Failed to setup user routes: %v", err)
 	}
-	if err := routes.SetupEvaluationRoutes(router, DB); err != nil {
+	paymentService, err := routes.SetupPaymentRoutes(router, DB, ledgerService, receiptService, idempotencyService)
+	if err != nil {
+		log.Fatalf("Failed to setup payment routes: %v", err)
+	}
+
+	if *replayPaymentEventsFlag {
+		fmt.Println("Replaying payment events...")
+		processed, err := services.NewPaymentWebhookService(DB, paymentService).Replay()
+		if err != nil {
+			log.Fatalf("Failed to replay payment events: %v", err)
+		}
+		fmt.Printf("%d payment events processed!\n", processed)
+		os.Exit(0)
+	}
+
+	payoutService.Start()
+	receiptService.Start()
+	idempotencyService.Start()
+
+	pushSender, err := routes.NewPushNotificationSender(DB)
+	if err != nil {
+		log.Fatalf("Failed to setup push notifications: %v", err)
+	}
+	// Without a provider, push notifications are not delivered: the
+	// dispatcher marks them dead
+	senders := make(map[string]services.NotificationSender)
+	if pushSender != nil {
+		senders[entities.NotificationChannelPush] = pushSender
+	} else {
+		log.Printf("No push provider configured: push notifications will not be delivered")
+	}
+	notificationDispatcher, err := services.NewNotificationDispatcher(DB, senders)
+	if err != nil {
+		log.Fatalf("Failed to setup notification dispatcher: %v", err)
+	}
+	notificationDispatcher.Start()
+
+	if err := routes.SetupEvaluationRoutes(router, DB, store, photoVariantService, paymentService, ledgerService, idempotencyService); err != nil {
 		log.Fatalf("Failed to setup evaluation routes: %v", err)
 	}
-	if err := routes.SetupReportRoutes(router, DB); err != nil {
+	if err := routes.SetupLedgerRoutes(router, DB, ledgerService, payoutService, idempotencyService); err != nil {
+		log.Fatalf("Failed to setup ledger routes: %v", err)
+	}
+	if err := routes.SetupReportRoutes(router, DB, store, idempotencyService); err != nil {
 		log.Fatalf("Failed to setup report routes: %v", err)
 	}
-	if err := routes.SetupNotificationRoutes(router, DB); err != nil {
+	if err := routes.SetupNotificationRoutes(router, DB, idempotencyService); err != nil {
 		log.Fatalf("Failed to setup notification routes: %v", err)
 	}
 